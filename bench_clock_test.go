@@ -0,0 +1,111 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+)
+
+// The RMW_Clock benchmarks run the blob RMW cycle with each time source, to
+// show how much reading the real clock adds to a realistic operation and how
+// much of that a cached clock wins back.
+
+// cachedResolution is coarse enough that the ticker costs nothing measurable
+// and fine enough for audit timestamps.
+const cachedResolution = time.Millisecond
+
+type benchClock struct {
+	name string
+	new  func(b *testing.B) clock.Clock
+}
+
+var benchClocks = []benchClock{
+	{"time.Now", func(*testing.B) clock.Clock { return clock.Real{} }},
+	{"fake", func(*testing.B) clock.Clock { return clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond) }},
+	{"cached", func(b *testing.B) clock.Clock {
+		c := clock.NewCached(cachedResolution)
+		c.Start()
+		b.Cleanup(c.Stop)
+		return c
+	}},
+}
+
+func BenchmarkDirect_RMW_Clock(b *testing.B) {
+	for _, bc := range benchClocks {
+		b.Run(bc.name, func(b *testing.B) {
+			repo := seedDirectRepo(bc.new(b), nSeed)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				order, err := repo.FindByID(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+					b.Fatal(err)
+				}
+				if err := repo.Save(order); err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = order
+			}
+		})
+	}
+}
+
+func BenchmarkEncap_RMW_Clock(b *testing.B) {
+	for _, bc := range benchClocks {
+		b.Run(bc.name, func(b *testing.B) {
+			repo := seedEncapRepo(bc.new(b), nSeed)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				order, err := repo.FindByID(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+					b.Fatal(err)
+				}
+				if err := repo.Save(order); err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = order
+			}
+		})
+	}
+}
+
+func BenchmarkDirectFlat_RMW_Clock(b *testing.B) {
+	for _, bc := range benchClocks {
+		b.Run(bc.name, func(b *testing.B) {
+			repo := seedDirectFlatRepo(bc.new(b), nSeedJSON)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				rec, err := repo.FindByID(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := applyDirectFlat(rec, mixedStep(i, len(ids)), i); err != nil {
+					b.Fatal(err)
+				}
+				if err := repo.Save(rec); err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = rec
+			}
+		})
+	}
+}
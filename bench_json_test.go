@@ -1,42 +1,31 @@
 package bench
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"testing"
 	"time"
 
 	"github.com/alechenninger/go-ddd-bench/directflat"
 	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
 	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
 )
 
-func randID2() string {
-	var b [16]byte
-	_, _ = rand.Read(b[:])
-	return hex.EncodeToString(b[:])
-}
-
-func seedDirectFlatRepo(n int) *directflat.Repo {
-	repo := directflat.NewRepo()
+func seedDirectFlatRepo(c clock.Clock, n int, opts ...directflat.Option) *directflat.Repo {
+	repo := directflat.NewRepo(append([]directflat.Option{directflat.WithClock(c)}, opts...)...)
+	gen := newWorkload()
 	for i := 0; i < n; i++ {
-		rec := directflat.NewOrderRecord(randID2(), "Ada", "Lovelace", "ada@example.com", "gold", 100)
-		rec.AddItem("A", 1, 1234, "USD", false, false)
-		rec.AddItem("B", 2, 555, "USD", true, false)
+		rec := variant.NewDirectFlatRecord(c, gen.Order())
 		_ = repo.Save(rec)
 	}
 	return repo
 }
 
-func seedEncapRepo2(n int) *encap.Repo {
-	repo := encap.NewRepo()
+func seedEncapRepo2(c clock.Clock, n int) *encap.Repo {
+	repo := encap.NewRepo(encap.WithClock(c))
+	gen := newWorkload()
 	for i := 0; i < n; i++ {
-		cust := encap.SnapshotCustomer{Name: encap.SnapshotName{First: "Ada", Last: "Lovelace"}, Email: "ada@example.com", Loyalty: encap.SnapshotLoyalty{Tier: "gold", Points: 100}}
-		ship := encap.SnapshotAddress{Street: "1 Main", City: "Town", State: "CA", Zip: "94000"}
-		bill := encap.SnapshotAddress{Street: "2 Main", City: "Town", State: "CA", Zip: "94000"}
-		o := encap.NewOrder(randID2(), cust, ship, bill)
-		o.AddItem("A", 1, 1234, "USD", encap.SnapshotItemFlags{})
-		o.AddItem("B", 2, 555, "USD", encap.SnapshotItemFlags{Backorder: true})
+		o := variant.NewEncapOrder(c, gen.Order())
 		_ = repo.Save(o)
 	}
 	return repo
@@ -45,22 +34,21 @@ func seedEncapRepo2(n int) *encap.Repo {
 const nSeedJSON = 1000
 
 func BenchmarkDirectFlat_JSON_RMW(b *testing.B) {
-	restore := clock.UseMonotonicFake(time.Unix(0, 0), time.Nanosecond)
-	defer restore()
-	repo := seedDirectFlatRepo(nSeedJSON)
-	ids := make([]string, 0, nSeedJSON)
-	for id := range repo.DataUnsafeForBench() {
-		ids = append(ids, id)
-	}
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := seedDirectFlatRepo(clk, nSeedJSON)
+	ids := benchIDs(repo.DataUnsafeForBench())
 	b.ReportAllocs()
 	b.ResetTimer()
+	benchgc.Track(b)
 	for i := 0; i < b.N; i++ {
 		id := ids[i%len(ids)]
 		rec, err := repo.FindByID(id)
 		if err != nil {
 			b.Fatal(err)
 		}
-		rec.AddItem("C", 1, 99, "USD", false, true)
+		if err := applyDirectFlat(rec, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
 		if err := repo.Save(rec); err != nil {
 			b.Fatal(err)
 		}
@@ -68,23 +56,113 @@ func BenchmarkDirectFlat_JSON_RMW(b *testing.B) {
 	}
 }
 
+// BenchmarkDirectFlat_JSON_RMW_LoadInto is BenchmarkDirectFlat_JSON_RMW
+// with every order loaded into the same record.
+func BenchmarkDirectFlat_JSON_RMW_LoadInto(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := seedDirectFlatRepo(clk, nSeedJSON)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	var rec directflat.OrderRecord
+	b.ReportAllocs()
+	b.ResetTimer()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		if err := repo.LoadInto(id, &rec); err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirectFlat(&rec, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(&rec); err != nil {
+			b.Fatal(err)
+		}
+	}
+	Blackhole = &rec
+}
+
 func BenchmarkEncap_JSON_RMW(b *testing.B) {
-	restore := clock.UseMonotonicFake(time.Unix(0, 0), time.Nanosecond)
-	defer restore()
-	repo := seedEncapRepo2(nSeedJSON)
-	ids := make([]string, 0, nSeedJSON)
-	for id := range repo.DataUnsafeForBench() {
-		ids = append(ids, id)
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := seedEncapRepo2(clk, nSeedJSON)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	b.ReportAllocs()
+	b.ResetTimer()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		o, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyEncap(o, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(o); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = o
+	}
+}
+
+func BenchmarkDirectFlat_JSON_RMW_Total(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := seedDirectFlatRepo(clk, nSeedJSON)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	buf := make([]byte, 0, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		rec, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirectFlat(rec, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		totals, err := rec.Total()
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf = buf[:0]
+		for _, m := range totals {
+			buf = m.AppendFormat(buf)
+		}
+		if err := repo.Save(rec); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = rec
 	}
+}
+
+// BenchmarkEncap_JSON_RMW_Total is the encapsulated counterpart of
+// BenchmarkDirectFlat_JSON_RMW_Total.
+func BenchmarkEncap_JSON_RMW_Total(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := seedEncapRepo2(clk, nSeedJSON)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	buf := make([]byte, 0, 64)
 	b.ReportAllocs()
 	b.ResetTimer()
+	benchgc.Track(b)
 	for i := 0; i < b.N; i++ {
 		id := ids[i%len(ids)]
 		o, err := repo.FindByID(id)
 		if err != nil {
 			b.Fatal(err)
 		}
-		o.AddItem("C", 1, 99, "USD", encap.SnapshotItemFlags{Digital: true})
+		if err := applyEncap(o, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		totals, err := o.Total()
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf = buf[:0]
+		for _, m := range totals {
+			buf = m.AppendFormat(buf)
+		}
 		if err := repo.Save(o); err != nil {
 			b.Fatal(err)
 		}
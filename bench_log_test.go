@@ -0,0 +1,163 @@
+package bench
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+)
+
+// The Log benches keep JSON blobs in an append-only segment file instead of a
+// map, so every Save is a real file write (optionally fsynced) and every
+// FindByID a checksummed read. They report the bytes appended as disk-B/op.
+
+// openSeededLog seeds a log without fsync, then reopens it with the requested
+// sync mode so only the timed loop pays for it.
+func openSeededLog(b *testing.B, sync bool, seed func(s blobstore.Store)) *blobstore.Log {
+	path := filepath.Join(b.TempDir(), "orders.log")
+	l, err := blobstore.OpenLog(path, blobstore.LogOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	seed(l)
+	if err := l.Close(); err != nil {
+		b.Fatal(err)
+	}
+	if l, err = blobstore.OpenLog(path, blobstore.LogOptions{Sync: sync}); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { l.Close() })
+	return l
+}
+
+func reportDiskWrites(b *testing.B, l *blobstore.Log) {
+	b.ReportMetric(float64(l.Stats().Written)/float64(b.N), "disk-B/op")
+}
+
+func BenchmarkDirect_Log_RMW(b *testing.B) {
+	for _, sync := range []bool{false, true} {
+		b.Run(fmt.Sprintf("fsync=%v", sync), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			l := openSeededLog(b, sync, func(s blobstore.Store) { seedDirectRepo(clk, nSeed, direct.WithStore(s)) })
+			repo := direct.NewDirectRepo(direct.WithStore(l), direct.WithClock(clk))
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				order, err := repo.FindByID(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+					b.Fatal(err)
+				}
+				if err := repo.Save(order); err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = order
+			}
+			b.StopTimer()
+			reportDiskWrites(b, l)
+		})
+	}
+}
+
+func BenchmarkEncap_Log_RMW(b *testing.B) {
+	for _, sync := range []bool{false, true} {
+		b.Run(fmt.Sprintf("fsync=%v", sync), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			l := openSeededLog(b, sync, func(s blobstore.Store) { seedEncapRepo(clk, nSeed, encap.WithStore(s)) })
+			repo := encap.NewRepo(encap.WithStore(l), encap.WithClock(clk))
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				order, err := repo.FindByID(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+					b.Fatal(err)
+				}
+				if err := repo.Save(order); err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = order
+			}
+			b.StopTimer()
+			reportDiskWrites(b, l)
+		})
+	}
+}
+
+func BenchmarkDirectFlat_Log_RMW(b *testing.B) {
+	for _, sync := range []bool{false, true} {
+		b.Run(fmt.Sprintf("fsync=%v", sync), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			l := openSeededLog(b, sync, func(s blobstore.Store) { seedDirectFlatRepo(clk, nSeedJSON, directflat.WithStore(s)) })
+			repo := directflat.NewRepo(directflat.WithStore(l), directflat.WithClock(clk))
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				rec, err := repo.FindByID(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := applyDirectFlat(rec, mixedStep(i, len(ids)), i); err != nil {
+					b.Fatal(err)
+				}
+				if err := repo.Save(rec); err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = rec
+			}
+			b.StopTimer()
+			reportDiskWrites(b, l)
+		})
+	}
+}
+
+// BenchmarkLog_Compact measures rewriting a segment in which every key has
+// been overwritten once.
+func BenchmarkLog_Compact(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	l := openSeededLog(b, false, func(s blobstore.Store) { seedDirectRepo(clk, nSeed, direct.WithStore(s)) })
+	keys := l.Keys()
+	b.ReportAllocs()
+	b.ResetTimer()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for _, k := range keys {
+			blob, err := l.Get(k)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := l.Put(k, blob); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StartTimer()
+		if err := l.Compact(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
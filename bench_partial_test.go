@@ -0,0 +1,103 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/kv"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+)
+
+// The Partial benches store header and item rows under per-table keys and
+// write only changed rows on Save. Compare with Direct_RMW and Encap_RMW,
+// which rewrite the whole aggregate as one blob.
+
+func seedDirectPartialRepo(c clock.Clock, n int) (*direct.PartialRepo, *kv.Store) {
+	store := kv.New()
+	repo := direct.NewPartialRepo(store, c)
+	gen := newWorkload()
+	for i := 0; i < n; i++ {
+		order := variant.NewDirectOrder(c, gen.Order())
+		_ = repo.Save(order)
+	}
+	return repo, store
+}
+
+func seedEncapPartialRepo(c clock.Clock, n int) (*encap.PartialRepo, *kv.Store) {
+	store := kv.New()
+	repo := encap.NewPartialRepo(store, c)
+	gen := newWorkload()
+	for i := 0; i < n; i++ {
+		order := variant.NewEncapOrder(c, gen.Order())
+		_ = repo.Save(order)
+	}
+	return repo, store
+}
+
+// rowStats is implemented by the row-oriented stores.
+type rowStats interface{ Stats() (puts, deletes int64) }
+
+// reportRowWrites reports the row puts and deletes committed since the given
+// baseline as rows/op.
+func reportRowWrites(b *testing.B, store rowStats, puts0, deletes0 int64) {
+	puts, deletes := store.Stats()
+	b.ReportMetric(float64(puts-puts0+deletes-deletes0)/float64(b.N), "rows/op")
+}
+
+func BenchmarkDirect_Partial_RMW(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo, store := seedDirectPartialRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	puts0, deletes0 := store.Stats()
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+	b.StopTimer()
+	reportRowWrites(b, store, puts0, deletes0)
+}
+
+func BenchmarkEncap_Partial_RMW(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo, store := seedEncapPartialRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	puts0, deletes0 := store.Stats()
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+	b.StopTimer()
+	reportRowWrites(b, store, puts0, deletes0)
+}
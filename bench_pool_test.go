@@ -0,0 +1,129 @@
+package bench
+
+import (
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+)
+
+// The Pool benchmarks run each variant's RMW cycle against a repository with
+// and without WithPooling, once from a single goroutine and once from
+// b.RunParallel, where goroutines contend for the pools:
+//
+//	BenchmarkEncap_Pool_RMW/pooled=true/parallel
+
+// rmw is one variant's read-modify-write cycle.
+type rmw[T any] struct {
+	ids   []string
+	load  func(id string) (T, error)
+	apply func(o T, step, i int) error
+	save  func(o T) error
+}
+
+func (c rmw[T]) step(o T, step, i int) error {
+	if err := c.apply(o, step, i); err != nil {
+		return err
+	}
+	return c.save(o)
+}
+
+func (c rmw[T]) serial(b *testing.B) {
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := c.ids[i%len(c.ids)]
+		o, err := c.load(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := c.step(o, mixedStep(i, len(c.ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = o
+	}
+}
+
+// parallel gives each goroutine its own share of the orders, so each order
+// still steps through the mixed commands in sequence.
+func (c rmw[T]) parallel(b *testing.B) {
+	procs := runtime.GOMAXPROCS(0) // RunParallel starts one goroutine per P
+	var next atomic.Int64
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	b.RunParallel(func(pb *testing.PB) {
+		w := int(next.Add(1)-1) % procs
+		var own []string
+		for j := w; j < len(c.ids); j += procs {
+			own = append(own, c.ids[j])
+		}
+		for i := 0; pb.Next(); i++ {
+			id := own[i%len(own)]
+			o, err := c.load(id)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			if err := c.step(o, mixedStep(i, len(own)), i); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// benchPooled runs the serial and parallel cycles for a repository built
+// with and without pooling.
+func benchPooled[T any](b *testing.B, cycle func(pooled bool) rmw[T]) {
+	for _, pooled := range []bool{false, true} {
+		b.Run("pooled="+strconv.FormatBool(pooled), func(b *testing.B) {
+			b.Run("serial", func(b *testing.B) { cycle(pooled).serial(b) })
+			b.Run("parallel", func(b *testing.B) { cycle(pooled).parallel(b) })
+		})
+	}
+}
+
+func BenchmarkDirect_Pool_RMW(b *testing.B) {
+	benchPooled(b, func(pooled bool) rmw[*direct.Order] {
+		clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+		var opts []direct.Option
+		if pooled {
+			opts = append(opts, direct.WithPooling())
+		}
+		repo := seedDirectRepo(clk, nSeed, opts...)
+		return rmw[*direct.Order]{benchIDs(repo.DataUnsafeForBench()), repo.FindByID, applyDirect, repo.Save}
+	})
+}
+
+func BenchmarkEncap_Pool_RMW(b *testing.B) {
+	benchPooled(b, func(pooled bool) rmw[*encap.Order] {
+		clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+		var opts []encap.Option
+		if pooled {
+			opts = append(opts, encap.WithPooling())
+		}
+		repo := seedEncapRepo(clk, nSeed, opts...)
+		return rmw[*encap.Order]{benchIDs(repo.DataUnsafeForBench()), repo.FindByID, applyEncap, repo.Save}
+	})
+}
+
+func BenchmarkDirectFlat_Pool_RMW(b *testing.B) {
+	benchPooled(b, func(pooled bool) rmw[*directflat.OrderRecord] {
+		clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+		var opts []directflat.Option
+		if pooled {
+			opts = append(opts, directflat.WithPooling())
+		}
+		repo := seedDirectFlatRepo(clk, nSeed, opts...)
+		return rmw[*directflat.OrderRecord]{benchIDs(repo.DataUnsafeForBench()), repo.FindByID, applyDirectFlat, repo.Save}
+	})
+}
@@ -0,0 +1,92 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/table"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+)
+
+// The Rows benches persist into the in-process table store as typed header and
+// item rows instead of JSON blobs, writing only changed rows per Save.
+
+func seedDirectRowRepo(c clock.Clock, n int) (*direct.RowRepo, *table.DB) {
+	db := table.NewDB()
+	repo := direct.NewRowRepo(db, c)
+	gen := newWorkload()
+	for i := 0; i < n; i++ {
+		order := variant.NewDirectOrder(c, gen.Order())
+		_ = repo.Save(order)
+	}
+	return repo, db
+}
+
+func seedEncapRowRepo(c clock.Clock, n int) (*encap.RowRepo, *table.DB) {
+	db := table.NewDB()
+	repo := encap.NewRowRepo(db, c)
+	gen := newWorkload()
+	for i := 0; i < n; i++ {
+		order := variant.NewEncapOrder(c, gen.Order())
+		_ = repo.Save(order)
+	}
+	return repo, db
+}
+
+func BenchmarkDirect_Rows_RMW(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo, db := seedDirectRowRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	puts0, deletes0 := db.Stats()
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+	b.StopTimer()
+	reportRowWrites(b, db, puts0, deletes0)
+}
+
+func BenchmarkEncap_Rows_RMW(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo, db := seedEncapRowRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	puts0, deletes0 := db.Stats()
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+	b.StopTimer()
+	reportRowWrites(b, db, puts0, deletes0)
+}
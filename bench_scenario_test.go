@@ -0,0 +1,61 @@
+package bench
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/hist"
+	"github.com/alechenninger/go-ddd-bench/internal/scenario"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+)
+
+// BenchmarkScenario runs every spec in scenarios/ against each variant's blob
+// repository. Each iteration is a whole run, preload included, so use
+// -benchtime=1x or a small count; the latency metrics come from the
+// per-operation histograms and exclude the preload.
+func BenchmarkScenario(b *testing.B) {
+	paths, err := filepath.Glob("scenarios/*.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, path := range paths {
+		spec, err := scenario.Load(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, name := range variant.Names {
+			b.Run(spec.Name+"/"+name, func(b *testing.B) {
+				var lat [scenario.NumOps]hist.Histogram
+				var elapsed time.Duration
+				var ops uint64
+				b.ReportAllocs()
+				benchgc.Track(b)
+				for i := 0; i < b.N; i++ {
+					target, err := scenario.NewTarget(name, clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond))
+					if err != nil {
+						b.Fatal(err)
+					}
+					rep, err := scenario.Run(spec, target)
+					if err != nil {
+						b.Fatal(err)
+					}
+					for op := range lat {
+						lat[op].Merge(&rep.Latency[op])
+					}
+					elapsed += rep.Elapsed
+					ops += rep.Ops()
+				}
+				b.ReportMetric(float64(ops)/elapsed.Seconds(), "ops/s")
+				for op := scenario.Op(0); op < scenario.NumOps; op++ {
+					if h := &lat[op]; h.Count() > 0 {
+						b.ReportMetric(float64(h.Quantile(0.5)), op.String()+"-p50-ns")
+						b.ReportMetric(float64(h.Quantile(0.99)), op.String()+"-p99-ns")
+					}
+				}
+			})
+		}
+	}
+}
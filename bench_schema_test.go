@@ -0,0 +1,238 @@
+package bench
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+)
+
+// The Upcast benches measure the read path for blobs stored at each schema
+// version. At v3, the current version, FindByID only strips the envelope; v2
+// and v1 blobs are decoded generically, migrated and re-encoded before the
+// typed decode.
+//
+// The ReadMostly benches save one in readMostlyEvery reads, starting from v1
+// blobs. Without lazy rewrite, orders that are only read stay at v1 and are
+// upcast on every read; with it, each Save also rewrites the orders upcast
+// since the previous one, well under schema.FlushBatch. Both report the
+// blobs still stale at the end.
+
+const readMostlyEvery = 10
+
+func blobVariantNamed(name string) blobVariant {
+	for _, fv := range blobVariants {
+		if fv.name == name {
+			return fv
+		}
+	}
+	panic("no blob variant " + name)
+}
+
+func downgradeAll(b *testing.B, fv blobVariant, s blobstore.Store, v int) {
+	if v == 3 {
+		return
+	}
+	for _, id := range s.Keys() {
+		downgradeStored(b, fv, s, id, v)
+	}
+}
+
+func reportStale(b *testing.B, s blobstore.Store) {
+	stale := 0
+	for _, id := range s.Keys() {
+		if storedVersion(b, s, id) != 3 {
+			stale++
+		}
+	}
+	b.ReportMetric(float64(stale), "stale-blobs")
+}
+
+func BenchmarkDirect_Upcast_FindByID(b *testing.B) {
+	for _, v := range []int{1, 2, 3} {
+		b.Run(fmt.Sprintf("v%d", v), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			store := blobstore.NewMemory()
+			repo := seedDirectRepo(clk, nSeed, direct.WithStore(store))
+			downgradeAll(b, blobVariantNamed("direct"), store, v)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				order, err := repo.FindByID(ids[i%len(ids)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = order
+			}
+		})
+	}
+}
+
+func BenchmarkEncap_Upcast_FindByID(b *testing.B) {
+	for _, v := range []int{1, 2, 3} {
+		b.Run(fmt.Sprintf("v%d", v), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			store := blobstore.NewMemory()
+			repo := seedEncapRepo(clk, nSeed, encap.WithStore(store))
+			downgradeAll(b, blobVariantNamed("encap"), store, v)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				order, err := repo.FindByID(ids[i%len(ids)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = order
+			}
+		})
+	}
+}
+
+func BenchmarkDirectFlat_Upcast_FindByID(b *testing.B) {
+	for _, v := range []int{1, 2, 3} {
+		b.Run(fmt.Sprintf("v%d", v), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			store := blobstore.NewMemory()
+			repo := seedDirectFlatRepo(clk, nSeed, directflat.WithStore(store))
+			downgradeAll(b, blobVariantNamed("directflat"), store, v)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				rec, err := repo.FindByID(ids[i%len(ids)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				Blackhole = rec
+			}
+		})
+	}
+}
+
+func BenchmarkDirect_Upcast_ReadMostly(b *testing.B) {
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy=%v", lazy), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			store := blobstore.NewMemory()
+			seedDirectRepo(clk, nSeed, direct.WithStore(store))
+			downgradeAll(b, blobVariantNamed("direct"), store, 1)
+			opts := []direct.Option{direct.WithStore(store), direct.WithClock(clk)}
+			if lazy {
+				opts = append(opts, direct.WithLazyRewrite())
+			}
+			repo := direct.NewDirectRepo(opts...)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				order, err := repo.FindByID(ids[i%len(ids)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				if i%readMostlyEvery == 0 {
+					if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+						b.Fatal(err)
+					}
+					if err := repo.Save(order); err != nil {
+						b.Fatal(err)
+					}
+				}
+				Blackhole = order
+			}
+			b.StopTimer()
+			reportStale(b, store)
+		})
+	}
+}
+
+func BenchmarkEncap_Upcast_ReadMostly(b *testing.B) {
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy=%v", lazy), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			store := blobstore.NewMemory()
+			seedEncapRepo(clk, nSeed, encap.WithStore(store))
+			downgradeAll(b, blobVariantNamed("encap"), store, 1)
+			opts := []encap.Option{encap.WithStore(store), encap.WithClock(clk)}
+			if lazy {
+				opts = append(opts, encap.WithLazyRewrite())
+			}
+			repo := encap.NewRepo(opts...)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				order, err := repo.FindByID(ids[i%len(ids)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				if i%readMostlyEvery == 0 {
+					if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+						b.Fatal(err)
+					}
+					if err := repo.Save(order); err != nil {
+						b.Fatal(err)
+					}
+				}
+				Blackhole = order
+			}
+			b.StopTimer()
+			reportStale(b, store)
+		})
+	}
+}
+
+func BenchmarkDirectFlat_Upcast_ReadMostly(b *testing.B) {
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy=%v", lazy), func(b *testing.B) {
+			clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+			store := blobstore.NewMemory()
+			seedDirectFlatRepo(clk, nSeed, directflat.WithStore(store))
+			downgradeAll(b, blobVariantNamed("directflat"), store, 1)
+			opts := []directflat.Option{directflat.WithStore(store), directflat.WithClock(clk)}
+			if lazy {
+				opts = append(opts, directflat.WithLazyRewrite())
+			}
+			repo := directflat.NewRepo(opts...)
+			ids := benchIDs(repo.DataUnsafeForBench())
+			b.ResetTimer()
+			b.ReportAllocs()
+			benchgc.Track(b)
+			for i := 0; i < b.N; i++ {
+				rec, err := repo.FindByID(ids[i%len(ids)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				if i%readMostlyEvery == 0 {
+					if err := applyDirectFlat(rec, mixedStep(i, len(ids)), i); err != nil {
+						b.Fatal(err)
+					}
+					if err := repo.Save(rec); err != nil {
+						b.Fatal(err)
+					}
+				}
+				Blackhole = rec
+			}
+			b.StopTimer()
+			reportStale(b, store)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package bench
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/ordersql"
+	"github.com/alechenninger/go-ddd-bench/internal/sqlfake"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+)
+
+// The SQL benches persist through database/sql and the in-memory sqlfake
+// driver, so each RMW includes placeholder binding, driver.Value conversion
+// and sql.Rows scanning.
+
+func openSQL(tb testing.TB) *sql.DB {
+	db := sqlfake.Open()
+	if err := ordersql.CreateSchema(db); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+func seedDirectSQLRepo(b *testing.B, c clock.Clock, n int) *direct.SQLRepo {
+	repo := direct.NewSQLRepo(openSQL(b), c)
+	gen := newWorkload()
+	for i := 0; i < n; i++ {
+		order := variant.NewDirectOrder(c, gen.Order())
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func seedEncapSQLRepo(b *testing.B, c clock.Clock, n int) *encap.SQLRepo {
+	repo := encap.NewSQLRepo(openSQL(b), c)
+	gen := newWorkload()
+	for i := 0; i < n; i++ {
+		order := variant.NewEncapOrder(c, gen.Order())
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func seedDirectFlatSQLRepo(b *testing.B, c clock.Clock, n int) *directflat.SQLRepo {
+	repo := directflat.NewSQLRepo(openSQL(b), c)
+	gen := newWorkload()
+	for i := 0; i < n; i++ {
+		rec := variant.NewDirectFlatRecord(c, gen.Order())
+		if err := repo.Save(rec); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func BenchmarkDirect_SQL_RMW(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo := seedDirectSQLRepo(b, clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+}
+
+func BenchmarkEncap_SQL_RMW(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo := seedEncapSQLRepo(b, clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+}
+
+func BenchmarkDirectFlat_SQL_RMW(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo := seedDirectFlatSQLRepo(b, clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		rec, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirectFlat(rec, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(rec); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = rec
+	}
+}
@@ -1,54 +1,49 @@
 package bench
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/alechenninger/go-ddd-bench/direct"
 	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
 	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
 )
 
-func randID() string {
-	var b [16]byte
-	_, _ = rand.Read(b[:])
-	return hex.EncodeToString(b[:])
+// newWorkload returns the generator seeders draw orders from. Every seeder
+// starts from the same seed, so each variant and repository holds the same
+// orders on every run.
+func newWorkload() *workload.Generator { return workload.New(workload.Default()) }
+
+// benchIDs returns the IDs in set sorted, so benchmarks visit orders in the
+// same sequence on every run.
+func benchIDs(set map[string]struct{}) []string {
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
 }
 
-func seedDirectRepo(n int) *direct.DirectRepo {
-	repo := direct.NewDirectRepo()
+func seedDirectRepo(c clock.Clock, n int, opts ...direct.Option) *direct.DirectRepo {
+	repo := direct.NewDirectRepo(append([]direct.Option{direct.WithClock(c)}, opts...)...)
+	gen := newWorkload()
 	for i := 0; i < n; i++ {
-		order := &direct.Order{
-			ID: randID(),
-			Customer: direct.Customer{
-				Name:    direct.Name{First: "Ada", Last: "Lovelace"},
-				Email:   "ada@example.com",
-				Loyalty: direct.Loyalty{Tier: "gold", Points: 100},
-			},
-			Shipping:  direct.Address{Street: "1 Main", City: "Town", State: "CA", Zip: "94000"},
-			Billing:   direct.Address{Street: "2 Main", City: "Town", State: "CA", Zip: "94000"},
-			Items:     nil,
-			CreatedAt: clock.Now(),
-			UpdatedAt: clock.Now(),
-		}
-		order.AddItem("A", 1, 1234, "USD", direct.ItemFlags{})
-		order.AddItem("B", 2, 555, "USD", direct.ItemFlags{Backorder: true})
+		order := variant.NewDirectOrder(c, gen.Order())
 		_ = repo.Save(order)
 	}
 	return repo
 }
 
-func seedEncapRepo(n int) *encap.Repo {
-	repo := encap.NewRepo()
+func seedEncapRepo(c clock.Clock, n int, opts ...encap.Option) *encap.Repo {
+	repo := encap.NewRepo(append([]encap.Option{encap.WithClock(c)}, opts...)...)
+	gen := newWorkload()
 	for i := 0; i < n; i++ {
-		cust := encap.SnapshotCustomer{Name: encap.SnapshotName{First: "Ada", Last: "Lovelace"}, Email: "ada@example.com", Loyalty: encap.SnapshotLoyalty{Tier: "gold", Points: 100}}
-		ship := encap.SnapshotAddress{Street: "1 Main", City: "Town", State: "CA", Zip: "94000"}
-		bill := encap.SnapshotAddress{Street: "2 Main", City: "Town", State: "CA", Zip: "94000"}
-		order := encap.NewOrder(randID(), cust, ship, bill)
-		order.AddItem("A", 1, 1234, "USD", encap.SnapshotItemFlags{})
-		order.AddItem("B", 2, 555, "USD", encap.SnapshotItemFlags{Backorder: true})
+		order := variant.NewEncapOrder(c, gen.Order())
 		_ = repo.Save(order)
 	}
 	return repo
@@ -61,23 +56,22 @@ const (
 
 // BenchmarkDirect_RMW simulates read-modify-write via direct (de)serialization.
 func BenchmarkDirect_RMW(b *testing.B) {
-	restore := clock.UseMonotonicFake(time.Unix(0, 0), time.Nanosecond)
-	defer restore()
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
 
-	repo := seedDirectRepo(nSeed)
-	ids := make([]string, 0, nSeed)
-	for id := range repo.DataUnsafeForBench() { // helper method returns map copy
-		ids = append(ids, id)
-	}
+	repo := seedDirectRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
 	b.ResetTimer()
 	b.ReportAllocs()
+	benchgc.Track(b)
 	for i := 0; i < b.N; i++ {
 		id := ids[i%len(ids)]
 		order, err := repo.FindByID(id)
 		if err != nil {
 			b.Fatal(err)
 		}
-		order.AddItem("C", 1, 99, "USD", direct.ItemFlags{Digital: true})
+		if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
 		if err := repo.Save(order); err != nil {
 			b.Fatal(err)
 		}
@@ -87,23 +81,144 @@ func BenchmarkDirect_RMW(b *testing.B) {
 
 // BenchmarkEncap_RMW simulates read-modify-write via snapshot + persistence DTO transforms.
 func BenchmarkEncap_RMW(b *testing.B) {
-	restore := clock.UseMonotonicFake(time.Unix(0, 0), time.Nanosecond)
-	defer restore()
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
 
-	repo := seedEncapRepo(nSeed)
-	ids := make([]string, 0, nSeed)
-	for id := range repo.DataUnsafeForBench() { // helper method returns map copy
-		ids = append(ids, id)
+	repo := seedEncapRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+}
+
+// BenchmarkDirect_RMW_LoadInto is BenchmarkDirect_RMW with every order
+// loaded into the same aggregate, so the allocations left are those the
+// repository needs whatever the caller does.
+func BenchmarkDirect_RMW_LoadInto(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo := seedDirectRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	var order direct.Order
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		if err := repo.LoadInto(id, &order); err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirect(&order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(&order); err != nil {
+			b.Fatal(err)
+		}
 	}
+	Blackhole = &order
+}
+
+// BenchmarkEncap_RMW_LoadInto is the encapsulated counterpart of
+// BenchmarkDirect_RMW_LoadInto.
+func BenchmarkEncap_RMW_LoadInto(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo := seedEncapRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	var order encap.Order
 	b.ResetTimer()
 	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		if err := repo.LoadInto(id, &order); err != nil {
+			b.Fatal(err)
+		}
+		if err := applyEncap(&order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		if err := repo.Save(&order); err != nil {
+			b.Fatal(err)
+		}
+	}
+	Blackhole = &order
+}
+
+// BenchmarkDirect_RMW_Total adds computing and formatting the order total to
+// the RMW cycle, so domain logic is measured alongside mapping.
+func BenchmarkDirect_RMW_Total(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo := seedDirectRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	buf := make([]byte, 0, 64)
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
+	for i := 0; i < b.N; i++ {
+		id := ids[i%len(ids)]
+		order, err := repo.FindByID(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := applyDirect(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		totals, err := order.Total()
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf = buf[:0]
+		for _, m := range totals {
+			buf = m.AppendFormat(buf)
+		}
+		if err := repo.Save(order); err != nil {
+			b.Fatal(err)
+		}
+		Blackhole = order
+	}
+}
+
+// BenchmarkEncap_RMW_Total is the encapsulated counterpart of BenchmarkDirect_RMW_Total.
+func BenchmarkEncap_RMW_Total(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+
+	repo := seedEncapRepo(clk, nSeed)
+	ids := benchIDs(repo.DataUnsafeForBench())
+	buf := make([]byte, 0, 64)
+	b.ResetTimer()
+	b.ReportAllocs()
+	benchgc.Track(b)
 	for i := 0; i < b.N; i++ {
 		id := ids[i%len(ids)]
 		order, err := repo.FindByID(id)
 		if err != nil {
 			b.Fatal(err)
 		}
-		order.AddItem("C", 1, 99, "USD", encap.SnapshotItemFlags{Digital: true})
+		if err := applyEncap(order, mixedStep(i, len(ids)), i); err != nil {
+			b.Fatal(err)
+		}
+		totals, err := order.Total()
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf = buf[:0]
+		for _, m := range totals {
+			buf = m.AppendFormat(buf)
+		}
 		if err := repo.Save(order); err != nil {
 			b.Fatal(err)
 		}
@@ -4,17 +4,22 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
 	"sort"
 	"strconv"
-	"strings"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+	"github.com/alechenninger/go-ddd-bench/internal/benchline"
+	"github.com/alechenninger/go-ddd-bench/internal/loadreport"
 )
 
 type stats struct {
 	ns     []float64
 	bytes  []float64
 	allocs []float64
+	load   []loadreport.Result  // dddload runs, which also carry percentiles
+	gc     map[string][]float64 // benchgc metrics by unit
 }
 
 func (s *stats) add(ns, bytes, allocs float64) {
@@ -23,20 +28,6 @@ func (s *stats) add(ns, bytes, allocs float64) {
 	s.allocs = append(s.allocs, allocs)
 }
 
-func median(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
-	}
-	cp := make([]float64, len(values))
-	copy(cp, values)
-	sort.Float64s(cp)
-	n := len(cp)
-	if n%2 == 1 {
-		return cp[n/2]
-	}
-	return (cp[n/2-1] + cp[n/2]) / 2
-}
-
 func mean(values []float64) float64 {
 	if len(values) == 0 {
 		return 0
@@ -48,47 +39,41 @@ func mean(values []float64) float64 {
 	return sum / float64(len(values))
 }
 
-var (
-	benchLinePrefix = "Benchmark"
-	valueBeforeUnit = regexp.MustCompile(`([0-9]+\.?[0-9]*)\s+(ns/op|B/op|allocs/op)`) // captures value and unit
-)
-
-func parseBenchLine(line string) (name string, ns, bytes, allocs float64, ok bool) {
-	line = strings.TrimSpace(line)
-	if !strings.HasPrefix(line, benchLinePrefix) {
-		return "", 0, 0, 0, false
-	}
-	fields := strings.Fields(line)
-	if len(fields) < 5 {
-		return "", 0, 0, 0, false
+// aggregate groups go test benchmark lines and dddload JSON records in r by
+// benchmark name. Other lines are ignored.
+func aggregate(r io.Reader) (map[string]*stats, error) {
+	byName := make(map[string]*stats)
+	get := func(name string) *stats {
+		st := byName[name]
+		if st == nil {
+			st = &stats{}
+			byName[name] = st
+		}
+		return st
 	}
-	name = fields[0]
-	// Find value preceding units using regex to be robust to spacing
-	matches := valueBeforeUnit.FindAllStringSubmatch(line, -1)
-	// Expect three metrics per line
-	for _, m := range matches {
-		if len(m) != 3 {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if res, ok := loadreport.Parse(line); ok {
+			st := get(res.Name)
+			st.add(float64(res.Latency.Mean), res.GC.BytesPerOp, res.GC.AllocsPerOp)
+			st.load = append(st.load, res)
 			continue
 		}
-		valStr := m[1]
-		unit := m[2]
-		v, err := strconv.ParseFloat(valStr, 64)
-		if err != nil {
+		name, ns, bytes, allocs, ok := benchline.Parse(line)
+		if !ok {
 			continue
 		}
-		switch unit {
-		case "ns/op":
-			ns = v
-		case "B/op":
-			bytes = v
-		case "allocs/op":
-			allocs = v
+		st := get(name)
+		st.add(ns, bytes, allocs)
+		for unit, v := range benchline.Metrics(line, benchgc.Units) {
+			if st.gc == nil {
+				st.gc = make(map[string][]float64)
+			}
+			st.gc[unit] = append(st.gc[unit], v)
 		}
 	}
-	if ns == 0 && bytes == 0 && allocs == 0 {
-		return "", 0, 0, 0, false
-	}
-	return name, ns, bytes, allocs, true
+	return byName, s.Err()
 }
 
 func main() {
@@ -102,26 +87,17 @@ func main() {
 	}
 	defer f.Close()
 
-	byName := make(map[string]*stats)
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		line := s.Text()
-		name, ns, bytes, allocs, ok := parseBenchLine(line)
-		if !ok {
-			continue
-		}
-		st := byName[name]
-		if st == nil {
-			st = &stats{}
-			byName[name] = st
-		}
-		st.add(ns, bytes, allocs)
-	}
-	if err := s.Err(); err != nil {
+	byName, err := aggregate(f)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "scan error: %v\n", err)
 		os.Exit(1)
 	}
+	writeTables(os.Stdout, byName)
+}
 
+// writeTables prints the medians and means of every benchmark. Benchmarks
+// that reported benchgc metrics and dddload runs get a table each of medians.
+func writeTables(w io.Writer, byName map[string]*stats) {
 	// Deterministic output: sort names
 	names := make([]string, 0, len(byName))
 	for name := range byName {
@@ -129,14 +105,66 @@ func main() {
 	}
 	sort.Strings(names)
 
-	fmt.Printf("%-34s  %12s  %12s  %12s  |  %12s  %12s  %12s  |  %s\n", "BENCHMARK", "med ns/op", "med B/op", "med allocs", "mean ns/op", "mean B/op", "mean allocs", "n")
+	fmt.Fprintf(w, "%-34s  %12s  %12s  %12s  |  %12s  %12s  %12s  |  %s\n", "BENCHMARK", "med ns/op", "med B/op", "med allocs", "mean ns/op", "mean B/op", "mean allocs", "n")
 	for _, name := range names {
 		st := byName[name]
-		fmt.Printf("%-34s  %12.3f  %12.0f  %12.0f  |  %12.3f  %12.0f  %12.2f  |  %d\n",
+		fmt.Fprintf(w, "%-34s  %12.3f  %12.0f  %12.0f  |  %12.3f  %12.0f  %12.2f  |  %d\n",
 			name,
-			median(st.ns), median(st.bytes), median(st.allocs),
+			benchline.Median(st.ns), benchline.Median(st.bytes), benchline.Median(st.allocs),
 			mean(st.ns), mean(st.bytes), mean(st.allocs),
 			len(st.ns),
 		)
 	}
+
+	header := false
+	for _, name := range names {
+		gc := byName[name].gc
+		if gc == nil {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\n%-34s  %12s  %12s  %12s  %12s\n", "GC (medians)", "cycles/kop", "cpu %", "pause ns/op", "peak heap KB")
+			header = true
+		}
+		peak := "-" // only sampled with -benchgc.peak
+		if v := gc[benchgc.PeakHeapBytes]; len(v) > 0 {
+			peak = strconv.FormatFloat(benchline.Median(v)/1024, 'f', 0, 64)
+		}
+		fmt.Fprintf(w, "%-34s  %12.3f  %12.2f  %12.1f  %12s\n",
+			name,
+			benchline.Median(gc[benchgc.CyclesPerOp])*1000,
+			benchline.Median(gc[benchgc.CPUFraction])*100,
+			benchline.Median(gc[benchgc.PauseNSPerOp]),
+			peak,
+		)
+	}
+
+	header = false
+	for _, name := range names {
+		runs := byName[name].load
+		if len(runs) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\n%-34s  %12s  %12s  %12s  %12s  %12s  %12s  |  %s\n", "LOAD (medians)", "ops/s", "p50 ns", "p90 ns", "p99 ns", "p99.9 ns", "gc pause ns", "n")
+			header = true
+		}
+		col := func(f func(loadreport.Result) float64) float64 {
+			vs := make([]float64, len(runs))
+			for i, r := range runs {
+				vs[i] = f(r)
+			}
+			return benchline.Median(vs)
+		}
+		fmt.Fprintf(w, "%-34s  %12.0f  %12.0f  %12.0f  %12.0f  %12.0f  %12.0f  |  %d\n",
+			name,
+			col(func(r loadreport.Result) float64 { return r.OpsPerSec }),
+			col(func(r loadreport.Result) float64 { return float64(r.Latency.P50) }),
+			col(func(r loadreport.Result) float64 { return float64(r.Latency.P90) }),
+			col(func(r loadreport.Result) float64 { return float64(r.Latency.P99) }),
+			col(func(r loadreport.Result) float64 { return float64(r.Latency.P999) }),
+			col(func(r loadreport.Result) float64 { return float64(r.GC.PauseTotalNS) }),
+			len(runs),
+		)
+	}
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchline"
+)
+
+func TestAggregate_LoadResults(t *testing.T) {
+	in := `goos: linux
+BenchmarkDirect_RMW-8   	    9000	    120000 ns/op	   31000 B/op	      25 allocs/op
+{"name":"BenchmarkDDDLoad/encap/workers=4","ops":100,"ops_per_sec":1000,"latency_ns":{"mean":500,"p50":400,"p99":900},"gc":{"bytes_per_op":64,"allocs_per_op":2}}
+{"name":"BenchmarkDDDLoad/encap/workers=4","ops":100,"ops_per_sec":3000,"latency_ns":{"mean":700,"p50":600,"p99":1100},"gc":{"bytes_per_op":64,"allocs_per_op":2}}
+{"not":"a result"}
+PASS
+`
+	byName, err := aggregate(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byName) != 2 {
+		t.Fatalf("got %d names, want 2: %v", len(byName), byName)
+	}
+	st := byName["BenchmarkDDDLoad/encap/workers=4"]
+	if st == nil || len(st.load) != 2 || benchline.Median(st.ns) != 600 || benchline.Median(st.allocs) != 2 {
+		t.Fatalf("load stats = %+v", st)
+	}
+	var b strings.Builder
+	writeTables(&b, byName)
+	for _, want := range []string{"BenchmarkDirect_RMW", "LOAD (medians)", "2000", "1000  "} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestAggregate_GCMetrics(t *testing.T) {
+	in := `BenchmarkEncap_RMW-8   	 1000	 16000 ns/op	 0.04 gc-cpu-frac	 0.002 gc-cycles/op	 30.5 gc-pause-ns/op	 5000000 peak-heap-B	 4224 B/op	 21 allocs/op
+BenchmarkEncap_RMW-8   	 1000	 17000 ns/op	 0.06 gc-cpu-frac	 0.002 gc-cycles/op	 40.5 gc-pause-ns/op	 6000000 peak-heap-B	 4224 B/op	 21 allocs/op
+BenchmarkDirect_RMW-8  	 1000	 15000 ns/op	 2080 B/op	 16 allocs/op
+`
+	byName, err := aggregate(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc := byName["BenchmarkDirect_RMW-8"].gc; gc != nil {
+		t.Errorf("benchmark without GC metrics got %v", gc)
+	}
+	gc := byName["BenchmarkEncap_RMW-8"].gc
+	if got := benchline.Median(gc["gc-pause-ns/op"]); got != 35.5 {
+		t.Errorf("median pause = %v, want 35.5", got)
+	}
+	if got := benchline.Median(gc["peak-heap-B"]); got != 5500000 {
+		t.Errorf("median peak heap = %v, want 5500000", got)
+	}
+	var b strings.Builder
+	writeTables(&b, byName)
+	if !strings.Contains(b.String(), "GC (medians)") || strings.Count(b.String(), "BenchmarkEncap_RMW-8") != 2 {
+		t.Errorf("output lacks the GC table:\n%s", b.String())
+	}
+}
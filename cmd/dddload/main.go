@@ -0,0 +1,207 @@
+// Command dddload drives one variant's read-modify-write cycle from several
+// goroutines for a fixed duration or number of operations, and reports
+// latency percentiles, throughput and GC activity. A human-readable summary
+// goes to stderr and a JSON record (internal/loadreport) to stdout or the
+// -o file, which cmd/benchagg aggregates alongside benchmark results:
+//
+//	go run ./cmd/dddload -variant encap -workers 8 -duration 10s -o load.jsonl
+//	go run ./cmd/benchagg -file load.jsonl
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/hist"
+	"github.com/alechenninger/go-ddd-bench/internal/loadreport"
+	"github.com/alechenninger/go-ddd-bench/internal/scenario"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
+)
+
+type config struct {
+	variant  string
+	workers  int
+	duration time.Duration
+	ops      int64 // if positive, overrides duration
+	preload  int
+	seed     uint64
+	clock    string
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.variant, "variant", "direct", "variant to drive: direct, encap or directflat")
+	flag.IntVar(&cfg.workers, "workers", runtime.GOMAXPROCS(0), "concurrent workers")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to run")
+	flag.Int64Var(&cfg.ops, "ops", 0, "total operations across workers; overrides -duration if positive")
+	flag.IntVar(&cfg.preload, "preload", 1000, "orders created before measuring")
+	flag.Uint64Var(&cfg.seed, "seed", 1, "workload seed")
+	flag.StringVar(&cfg.clock, "clock", "fake", "aggregate clock: fake, real or cached")
+	out := flag.String("o", "", "append the JSON result to this file instead of writing it to stdout")
+	flag.Parse()
+
+	res, err := run(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	printSummary(os.Stderr, res)
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newClock(name string) (clock.Clock, func(), error) {
+	switch name {
+	case "fake":
+		return clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond), func() {}, nil
+	case "real":
+		return clock.Real{}, func() {}, nil
+	case "cached":
+		c := clock.NewCached(time.Millisecond)
+		c.Start()
+		return c, c.Stop, nil
+	}
+	return nil, nil, fmt.Errorf("unknown clock %q", name)
+}
+
+func run(cfg config) (loadreport.Result, error) {
+	if cfg.workers < 1 || cfg.preload < 1 {
+		return loadreport.Result{}, errors.New("workers and preload must be positive")
+	}
+	clk, stopClock, err := newClock(cfg.clock)
+	if err != nil {
+		return loadreport.Result{}, err
+	}
+	defer stopClock()
+	target, err := scenario.NewTarget(cfg.variant, clk)
+	if err != nil {
+		return loadreport.Result{}, err
+	}
+	wcfg := workload.Default()
+	wcfg.Seed = cfg.seed
+	gen := workload.New(wcfg)
+	ids := make([]string, cfg.preload)
+	for i := range ids {
+		o := gen.Order()
+		if err := target.Create(o); err != nil {
+			return loadreport.Result{}, fmt.Errorf("preload: %w", err)
+		}
+		ids[i] = o.ID
+	}
+
+	var (
+		stop      atomic.Bool
+		remaining atomic.Int64
+		firstErr  error
+		errOnce   sync.Once
+		wg        sync.WaitGroup
+	)
+	remaining.Store(cfg.ops)
+	hists := make([]hist.Histogram, cfg.workers)
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	if cfg.ops <= 0 {
+		timer := time.AfterFunc(cfg.duration, func() { stop.Store(true) })
+		defer timer.Stop()
+	}
+	for w := 0; w < cfg.workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(cfg.seed, uint64(w)+1))
+			h := &hists[w]
+			for i := w; ; i += cfg.workers {
+				if cfg.ops > 0 {
+					if remaining.Add(-1) < 0 {
+						return
+					}
+				} else if stop.Load() {
+					return
+				}
+				id := ids[r.IntN(len(ids))]
+				began := time.Now()
+				err := target.Update(id, i)
+				h.Record(time.Since(began))
+				if err != nil {
+					errOnce.Do(func() { firstErr = err })
+					stop.Store(true)
+					remaining.Store(0)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	var all hist.Histogram
+	for i := range hists {
+		all.Merge(&hists[i])
+	}
+	if firstErr != nil {
+		return loadreport.Result{}, fmt.Errorf("after %d ops: %w", all.Count(), firstErr)
+	}
+	ops := all.Count()
+	res := loadreport.Result{
+		Name:      fmt.Sprintf("BenchmarkDDDLoad/%s/workers=%d", cfg.variant, cfg.workers),
+		Variant:   cfg.variant,
+		Workers:   cfg.workers,
+		Ops:       ops,
+		ElapsedNS: int64(elapsed),
+		OpsPerSec: float64(ops) / elapsed.Seconds(),
+		Latency:   loadreport.LatencyOf(&all),
+		GC:        gcStats(&before, &after, ops),
+	}
+	return res, nil
+}
+
+func gcStats(before, after *runtime.MemStats, ops uint64) loadreport.GC {
+	g := loadreport.GC{
+		Cycles:       after.NumGC - before.NumGC,
+		PauseTotalNS: after.PauseTotalNs - before.PauseTotalNs,
+	}
+	// PauseNs is a ring of the most recent 256 pauses.
+	for i := uint32(0); i < min(g.Cycles, uint32(len(after.PauseNs))); i++ {
+		g.PauseMaxNS = max(g.PauseMaxNS, after.PauseNs[(after.NumGC-1-i)%uint32(len(after.PauseNs))])
+	}
+	if ops > 0 {
+		g.BytesPerOp = float64(after.TotalAlloc-before.TotalAlloc) / float64(ops)
+		g.AllocsPerOp = float64(after.Mallocs-before.Mallocs) / float64(ops)
+	}
+	return g
+}
+
+func printSummary(w io.Writer, r loadreport.Result) {
+	l := r.Latency
+	d := func(ns int64) time.Duration { return time.Duration(ns) }
+	fmt.Fprintf(w, "%s: %d ops in %v (%.0f ops/s)\n", r.Name, r.Ops, d(r.ElapsedNS).Round(time.Millisecond), r.OpsPerSec)
+	fmt.Fprintf(w, "  latency  mean %v  p50 %v  p90 %v  p99 %v  p99.9 %v  max %v\n",
+		d(l.Mean), d(l.P50), d(l.P90), d(l.P99), d(l.P999), d(l.Max))
+	fmt.Fprintf(w, "  gc       %d cycles  pause total %v  max %v  %.0f B/op  %.1f allocs/op\n",
+		r.GC.Cycles, d(int64(r.GC.PauseTotalNS)), d(int64(r.GC.PauseMaxNS)), r.GC.BytesPerOp, r.GC.AllocsPerOp)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/loadreport"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+)
+
+func TestRun(t *testing.T) {
+	for _, name := range variant.Names {
+		t.Run(name, func(t *testing.T) {
+			res, err := run(config{variant: name, workers: 3, ops: 300, preload: 20, seed: 1, clock: "fake"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Ops != 300 || res.Name != "BenchmarkDDDLoad/"+name+"/workers=3" {
+				t.Fatalf("ran %d ops as %q", res.Ops, res.Name)
+			}
+			l := res.Latency
+			if !(0 < l.P50 && l.P50 <= l.P90 && l.P90 <= l.P99 && l.P99 <= l.P999 && l.P999 <= l.Max) {
+				t.Fatalf("latency percentiles out of order: %+v", l)
+			}
+			line, err := json.Marshal(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := loadreport.Parse(string(line)); !ok || got != res {
+				t.Fatalf("Parse(%s) = %+v, %v", line, got, ok)
+			}
+		})
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	for _, cfg := range []config{
+		{variant: "nope", workers: 1, ops: 1, preload: 1, clock: "fake"},
+		{variant: "direct", workers: 1, ops: 1, preload: 1, clock: "sundial"},
+		{variant: "direct", workers: 0, ops: 1, preload: 1, clock: "fake"},
+	} {
+		if _, err := run(cfg); err == nil {
+			t.Errorf("run(%+v) succeeded", cfg)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Command escapes reports the compiler's inlining and escape-analysis
+// decisions for the model and mapper code, and compares them with a
+// committed baseline:
+//
+//	go run ./cmd/escapes
+//
+// It builds each package with -gcflags=-m=2 and groups the diagnostics by
+// function: whether it can be inlined and at what cost, and which values in
+// it escape to the heap or leak through parameters. A function that is no
+// longer inlinable, or a value that newly escapes, is a regression, and the
+// command exits with status 1 if it finds any. Changes in inlining cost are
+// listed but not counted. After a deliberate change, rewrite the baseline:
+//
+//	go run ./cmd/escapes -update
+//
+// The decisions depend on the Go version, which the baseline records.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchrun"
+)
+
+// errRegressed is returned when the report has regressions; it is not
+// printed, since the report says what they are.
+var errRegressed = errors.New("regressions")
+
+type config struct {
+	pkgs     []string
+	baseline string
+	update   bool
+	json     bool
+}
+
+func main() {
+	var cfg config
+	pkgs := flag.String("pkgs", "./direct,./encap,./directflat", "comma-separated packages to analyze")
+	flag.StringVar(&cfg.baseline, "baseline", "cmd/escapes/baseline.json", "committed report to compare against")
+	flag.BoolVar(&cfg.update, "update", false, "write the current report to -baseline instead of comparing")
+	flag.BoolVar(&cfg.json, "json", false, "print the current report as JSON instead of comparing")
+	flag.Parse()
+	for _, p := range strings.Split(*pkgs, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.pkgs = append(cfg.pkgs, p)
+		}
+	}
+
+	if err := run(cfg, os.Stdout); err != nil {
+		if err != errRegressed {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(cfg config, w io.Writer) error {
+	cur, err := analyze(cfg.pkgs)
+	if err != nil {
+		return err
+	}
+	switch {
+	case cfg.json:
+		return writeJSON(w, cur)
+	case cfg.update:
+		f, err := os.Create(cfg.baseline)
+		if err != nil {
+			return err
+		}
+		if err := writeJSON(f, cur); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	data, err := os.ReadFile(cfg.baseline)
+	if err != nil {
+		return err
+	}
+	var base Report
+	if err := json.Unmarshal(data, &base); err != nil {
+		return fmt.Errorf("%s: %w", cfg.baseline, err)
+	}
+	if base.Go != cur.Go {
+		fmt.Fprintf(w, "note: baseline is from %s, this is %s; inlining costs and decisions may differ\n\n", base.Go, cur.Go)
+	}
+	if d := compare(base, cur); d.write(w) {
+		return errRegressed
+	}
+	return nil
+}
+
+func analyze(pkgs []string) (Report, error) {
+	goVersion, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return Report{}, fmt.Errorf("go env: %w", err)
+	}
+	root, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}").Output()
+	if err != nil {
+		return Report{}, fmt.Errorf("go list -m: %w", err)
+	}
+	r := Report{Go: strings.TrimSpace(string(goVersion))}
+	for _, pkg := range pkgs {
+		p, err := analyzePackage(strings.TrimSpace(string(root)), pkg)
+		if err != nil {
+			return Report{}, err
+		}
+		r.Packages = append(r.Packages, p)
+	}
+	return r, nil
+}
+
+func analyzePackage(root, pkg string) (Package, error) {
+	dir, err := benchrun.Dir(pkg)
+	if err != nil {
+		return Package{}, err
+	}
+	importPath, err := exec.Command("go", "list", pkg).Output()
+	if err != nil {
+		return Package{}, fmt.Errorf("go list %s: %w", pkg, err)
+	}
+	// The compiler writes diagnostics to stderr; a cached build replays them.
+	var out bytes.Buffer
+	cmd := exec.Command("go", "build", "-gcflags=-m=2", pkg)
+	cmd.Dir = root
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		os.Stderr.Write(out.Bytes())
+		return Package{}, fmt.Errorf("go build %s: %w", pkg, err)
+	}
+	funcs, err := parsePackage(&out, root, dir)
+	if err != nil {
+		return Package{}, err
+	}
+	return Package{Path: strings.TrimSpace(string(importPath)), Funcs: funcs}, nil
+}
+
+func writeJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// delta is what changed between two reports, one line per change, each
+// prefixed with the package and function.
+type delta struct {
+	regressions, improvements, costs, other []string
+}
+
+func compare(base, cur Report) delta {
+	var d delta
+	basePkgs := make(map[string]Package)
+	for _, p := range base.Packages {
+		basePkgs[p.Path] = p
+	}
+	for _, p := range cur.Packages {
+		bp, ok := basePkgs[p.Path]
+		if !ok {
+			d.other = append(d.other, p.Path+": not in baseline")
+			continue
+		}
+		delete(basePkgs, p.Path)
+		comparePackage(&d, path.Base(p.Path), bp.Funcs, p.Funcs)
+	}
+	for pkg := range basePkgs {
+		d.other = append(d.other, pkg+": no longer analyzed")
+	}
+	return d
+}
+
+func comparePackage(d *delta, pkg string, base, cur []Func) {
+	baseFuncs := make(map[string]Func)
+	for _, f := range base {
+		baseFuncs[f.Name] = f
+	}
+	for _, f := range cur {
+		id := pkg + " " + f.Name + ": "
+		bf, ok := baseFuncs[f.Name]
+		if !ok {
+			d.other = append(d.other, id+"new function")
+			continue
+		}
+		delete(baseFuncs, f.Name)
+		switch {
+		case bf.Inline && !f.Inline:
+			d.regressions = append(d.regressions, id+"no longer inlinable ("+f.Reason+")")
+		case !bf.Inline && f.Inline:
+			d.improvements = append(d.improvements, id+"now inlinable")
+		}
+		if bf.Cost != f.Cost && bf.Cost != 0 && f.Cost != 0 {
+			d.costs = append(d.costs, fmt.Sprintf("%s%d -> %d", id, bf.Cost, f.Cost))
+		}
+		added, removed := diffMultiset(bf.Escapes, f.Escapes)
+		for _, e := range added {
+			d.regressions = append(d.regressions, id+e)
+		}
+		for _, e := range removed {
+			d.improvements = append(d.improvements, id+"no longer: "+e)
+		}
+	}
+	for name := range baseFuncs {
+		d.other = append(d.other, pkg+" "+name+": removed")
+	}
+}
+
+// diffMultiset returns the elements of b missing from a and those of a
+// missing from b, counting duplicates. Both must be sorted.
+func diffMultiset(a, b []string) (added, removed []string) {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || i < len(a) && a[i] < b[j]:
+			removed = append(removed, a[i])
+			i++
+		case i == len(a) || b[j] < a[i]:
+			added = append(added, b[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// write prints the delta and reports whether it has regressions.
+func (d delta) write(w io.Writer) bool {
+	if len(d.regressions)+len(d.improvements)+len(d.costs)+len(d.other) == 0 {
+		fmt.Fprintln(w, "no changes from baseline")
+		return false
+	}
+	for _, s := range []struct {
+		title string
+		lines []string
+	}{
+		{"REGRESSIONS", d.regressions},
+		{"IMPROVEMENTS", d.improvements},
+		{"INLINING COST", d.costs},
+		{"OTHER", d.other},
+	} {
+		if len(s.lines) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, s.title)
+		for _, l := range s.lines {
+			fmt.Fprintln(w, "  "+l)
+		}
+		fmt.Fprintln(w)
+	}
+	return len(d.regressions) > 0
+}
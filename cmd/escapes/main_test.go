@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const src = `package m
+
+type Order struct{ items []int }
+
+func New(n int) *Order {
+	return &Order{items: make([]int, n)}
+}
+
+func (o *Order) Each(f func(int)) {
+	g := func() {
+		x := 1
+		f(x)
+	}
+	g()
+}
+
+func (o Order) Len() int { return len(o.items) }
+`
+
+func TestParsePackage(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "m")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "m.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join([]string{
+		"# example.com/m",
+		"m/m.go:5:6: cannot inline New: function too complex: cost 90 exceeds budget 80",
+		"m/m.go:6:9: &Order{...} escapes to heap in New:",
+		"m/m.go:6:9:   flow: ~r0 ← &{storage for &Order{...}}:",
+		"m/m.go:6:9: &Order{...} escapes to heap",
+		"m/m.go:6:28: make([]int, n) escapes to heap",
+		"m/m.go:9:6: can inline (*Order).Each with cost 40 as: method(*Order) func(func(int)) { ... }",
+		"m/m.go:9:17: leaking param: f",
+		"m/m.go:9:7: o does not escape",
+		"m/m.go:11:3: moved to heap: x",
+		"m/m.go:10:7: can inline (*Order).Each.func1 with cost 10 as: func() { ... }",
+		"m/m.go:17:6: cannot inline Order.Len: marked go:noinline",
+		"m/m.go:6:9: inlining call to other.F",
+		"./internal/other/other.go:3:6: can inline other.F with cost 2 as: func() {  }",
+		"/usr/local/go/src/cmp/cmp.go:40:6: can inline cmp.Compare[int] with cost 63 as: func() {  }",
+	}, "\n")
+	funcs, err := parsePackage(strings.NewReader(out), root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Func{
+		{Name: "(*Order).Each", Inline: true, Cost: 40, Escapes: []string{"leaking param: f", "moved to heap: x"}},
+		{Name: "(*Order).Each.func1", Inline: true, Cost: 10},
+		{Name: "New", Cost: 90, Reason: "function too complex", Escapes: []string{"&Order{...} escapes to heap", "make([]int, n) escapes to heap"}},
+		{Name: "Order.Len", Reason: "marked go:noinline"},
+	}
+	if len(funcs) != len(want) {
+		t.Fatalf("funcs = %+v", funcs)
+	}
+	for i := range want {
+		g, w := funcs[i], want[i]
+		if g.Name != w.Name || g.Inline != w.Inline || g.Cost != w.Cost || g.Reason != w.Reason || !slices.Equal(g.Escapes, w.Escapes) {
+			t.Errorf("funcs[%d] = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := Report{Packages: []Package{{Path: "x/m", Funcs: []Func{
+		{Name: "A", Inline: true, Cost: 10},
+		{Name: "B", Cost: 100, Escapes: []string{"a escapes to heap", "a escapes to heap", "moved to heap: b"}},
+		{Name: "C", Inline: false, Reason: "marked go:noinline"},
+		{Name: "Gone", Inline: true, Cost: 5},
+	}}}}
+	cur := Report{Packages: []Package{{Path: "x/m", Funcs: []Func{
+		{Name: "A", Cost: 90, Reason: "function too complex"},
+		{Name: "B", Cost: 100, Escapes: []string{"a escapes to heap", "c escapes to heap"}},
+		{Name: "C", Inline: true, Cost: 3},
+		{Name: "New", Inline: true, Cost: 1},
+	}}}}
+	d := compare(base, cur)
+	check := func(what string, got, want []string) {
+		t.Helper()
+		if !slices.Equal(got, want) {
+			t.Errorf("%s = %q, want %q", what, got, want)
+		}
+	}
+	check("regressions", d.regressions, []string{"m A: no longer inlinable (function too complex)", "m B: c escapes to heap"})
+	check("improvements", d.improvements, []string{"m B: no longer: a escapes to heap", "m B: no longer: moved to heap: b", "m C: now inlinable"})
+	check("costs", d.costs, []string{"m A: 10 -> 90"})
+	check("other", d.other, []string{"m New: new function", "m Gone: removed"})
+
+	var b strings.Builder
+	if !d.write(&b) || !strings.Contains(b.String(), "REGRESSIONS\n  m A:") {
+		t.Errorf("write reported no regressions:\n%s", b.String())
+	}
+	b.Reset()
+	if compare(base, base).write(&b) || b.String() != "no changes from baseline\n" {
+		t.Errorf("comparing the baseline with itself printed:\n%s", b.String())
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	data, err := os.ReadFile("baseline.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, p := range r.Packages {
+		if len(p.Funcs) == 0 {
+			t.Errorf("baseline has no functions for %s", p.Path)
+		}
+		paths = append(paths, path.Base(p.Path))
+	}
+	if want := []string{"direct", "encap", "directflat"}; !slices.Equal(paths, want) {
+		t.Errorf("baseline packages = %v, want %v", paths, want)
+	}
+}
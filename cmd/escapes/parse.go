@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Report is the compiler's inlining and escape decisions for a set of
+// packages.
+type Report struct {
+	Go       string    `json:"go"`
+	Packages []Package `json:"packages"`
+}
+
+// Package holds one package's functions, sorted by name.
+type Package struct {
+	Path  string `json:"path"`
+	Funcs []Func `json:"funcs"`
+}
+
+// Func is one function's inlining decision and the values in it that escape.
+// Escapes holds the diagnostic texts without positions, so the report does
+// not change when code moves; a text that occurs twice is listed twice.
+type Func struct {
+	Name    string   `json:"name"`
+	Inline  bool     `json:"inline"`
+	Cost    int      `json:"cost,omitempty"`
+	Reason  string   `json:"reason,omitempty"` // why it cannot be inlined
+	Escapes []string `json:"escapes,omitempty"`
+}
+
+var (
+	diagLine   = regexp.MustCompile(`^(.+\.go):(\d+):(\d+): (.*)$`)
+	canInline  = regexp.MustCompile(`^can inline (\S+) with cost (\d+)`)
+	cantInline = regexp.MustCompile(`^cannot inline (\S+): (.*)$`)
+	tooComplex = regexp.MustCompile(`^function too complex: cost (\d+) exceeds budget \d+$`)
+)
+
+// isEscape reports whether msg is an escape diagnostic worth tracking: a
+// value escaping or moved to the heap, or a parameter leaking. The -m=2 forms
+// ending in "in F:" repeat the plain ones with their data flow.
+func isEscape(msg string) bool {
+	return strings.HasSuffix(msg, " escapes to heap") ||
+		strings.HasPrefix(msg, "moved to heap: ") ||
+		strings.HasPrefix(msg, "leaking param")
+}
+
+// parsePackage reads the -gcflags=-m=2 output of building the package in dir
+// and returns its functions. Paths in the output are relative to root.
+// Diagnostics in other packages, such as inlined generic code, are dropped,
+// and those inside function literals count towards the enclosing function.
+func parsePackage(r io.Reader, root, dir string) ([]Func, error) {
+	decls, err := funcDecls(dir)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*Func)
+	get := func(name string) *Func {
+		f := byName[name]
+		if f == nil {
+			f = &Func{Name: name}
+			byName[name] = f
+		}
+		return f
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20) // inlinable bodies of generic code make long lines
+	for s.Scan() {
+		m := diagLine.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+		file := m[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(root, file)
+		}
+		if filepath.Dir(file) != dir {
+			continue
+		}
+		msg := m[4]
+		if m := canInline.FindStringSubmatch(msg); m != nil {
+			f := get(m[1])
+			f.Inline, f.Reason = true, ""
+			f.Cost, _ = strconv.Atoi(m[2])
+			continue
+		}
+		if m := cantInline.FindStringSubmatch(msg); m != nil {
+			f := get(m[1])
+			f.Inline, f.Cost, f.Reason = false, 0, m[2]
+			if c := tooComplex.FindStringSubmatch(m[2]); c != nil {
+				f.Cost, _ = strconv.Atoi(c[1])
+				f.Reason = "function too complex"
+			}
+			continue
+		}
+		if !isEscape(msg) {
+			continue
+		}
+		line, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		name := decls.enclosing(filepath.Base(file), line, col)
+		if name == "" {
+			name = "init" // package-level variable initializers
+		}
+		f := get(name)
+		f.Escapes = append(f.Escapes, msg)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	funcs := make([]Func, 0, len(byName))
+	for _, f := range byName {
+		sort.Strings(f.Escapes)
+		funcs = append(funcs, *f)
+	}
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].Name < funcs[j].Name })
+	return funcs, nil
+}
+
+// declSpan is the extent of a function declaration in its file.
+type declSpan struct {
+	name       string
+	start, end token.Position
+}
+
+// decls maps file names to the function declarations in them.
+type decls map[string][]declSpan
+
+// funcDecls parses the non-test Go files in dir.
+func funcDecls(dir string) (decls, error) {
+	fset := token.NewFileSet()
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	d := make(decls)
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Base(path)
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			d[base] = append(d[base], declSpan{funcName(fd), fset.Position(fd.Pos()), fset.Position(fd.End())})
+		}
+	}
+	return d, nil
+}
+
+func (d decls) enclosing(file string, line, col int) string {
+	for _, s := range d[file] {
+		if before(s.start, line, col) && !before(s.end, line, col) {
+			return s.name
+		}
+	}
+	return ""
+}
+
+// before reports whether p is at or before line:col.
+func before(p token.Position, line, col int) bool {
+	return p.Line < line || p.Line == line && p.Column <= col
+}
+
+// funcName names a declaration the way the compiler's diagnostics do, such as
+// "FromSnapshot", "Money.Add" or "(*Order).AddItem".
+func funcName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	t := fd.Recv.List[0].Type
+	ptr := false
+	if st, ok := t.(*ast.StarExpr); ok {
+		ptr, t = true, st.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	recv := fmt.Sprint(t)
+	if ptr {
+		return "(*" + recv + ")." + fd.Name.Name
+	}
+	return recv + "." + fd.Name.Name
+}
@@ -0,0 +1,189 @@
+// Command gcmatrix runs the benchmarks once per combination of GOGC and
+// GOMEMLIMIT settings and reports how each benchmark's ns/op moves with GC
+// pressure. The test binary is built once and re-executed with a different
+// environment for every cell, so all cells run the same code:
+//
+//	go run ./cmd/gcmatrix -bench 'RMW$' -gogc 50,100,400,off -memlimit off,64MiB -dir gcmatrix
+//
+// Each cell's raw output is kept in -dir, named after its settings, so it can
+// also be read with cmd/benchagg.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchline"
+	"github.com/alechenninger/go-ddd-bench/internal/benchrun"
+)
+
+// cell is one combination of GC settings. Values are passed to the runtime
+// as they are, so "off" disables the collector or the limit.
+type cell struct {
+	gogc, memlimit string
+}
+
+func (c cell) String() string { return "GOGC=" + c.gogc + " GOMEMLIMIT=" + c.memlimit }
+
+// file is the name of the cell's raw output in -dir.
+func (c cell) file() string { return "gogc-" + c.gogc + "_memlimit-" + c.memlimit + ".txt" }
+
+// env returns base with the cell's settings replacing any inherited ones.
+func (c cell) env(base []string) []string {
+	env := make([]string, 0, len(base)+2)
+	for _, kv := range base {
+		if !strings.HasPrefix(kv, "GOGC=") && !strings.HasPrefix(kv, "GOMEMLIMIT=") {
+			env = append(env, kv)
+		}
+	}
+	return append(env, "GOGC="+c.gogc, "GOMEMLIMIT="+c.memlimit)
+}
+
+func matrix(gogcs, limits []string) []cell {
+	var cells []cell
+	for _, g := range gogcs {
+		for _, m := range limits {
+			cells = append(cells, cell{g, m})
+		}
+	}
+	return cells
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func main() {
+	pkg := flag.String("pkg", ".", "package whose benchmarks to run")
+	bench := flag.String("bench", "RMW$", "benchmarks to run, as for go test -bench")
+	benchtime := flag.String("benchtime", "1s", "go test -benchtime")
+	count := flag.Int("count", 3, "go test -count")
+	gogc := flag.String("gogc", "50,100,400,off", "comma-separated GOGC values")
+	memlimit := flag.String("memlimit", "off,64MiB", "comma-separated GOMEMLIMIT values")
+	dir := flag.String("dir", "gcmatrix", "directory for each cell's raw output")
+	flag.Parse()
+
+	cells := matrix(splitList(*gogc), splitList(*memlimit))
+	if len(cells) == 0 {
+		fmt.Fprintln(os.Stderr, "error: no GOGC or GOMEMLIMIT values")
+		os.Exit(2)
+	}
+	if err := run(*pkg, *bench, *benchtime, *count, cells, *dir, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(pkg, bench, benchtime string, count int, cells []cell, dir string, w io.Writer) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "gcmatrix")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	bin, err := benchrun.Build(pkg, filepath.Join(tmp, "bench.test"))
+	if err != nil {
+		return err
+	}
+
+	results := make([]map[string][]float64, len(cells))
+	for i, c := range cells {
+		fmt.Fprintf(os.Stderr, "== %s\n", c)
+		var out bytes.Buffer
+		err := bin.Bench(io.MultiWriter(&out, os.Stderr), c.env(os.Environ()),
+			"-test.bench="+bench, "-test.benchmem", "-test.benchtime="+benchtime, fmt.Sprintf("-test.count=%d", count))
+		if err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, c.file()), out.Bytes(), 0o644); err != nil {
+			return err
+		}
+		if results[i], err = benchline.NsPerOp(&out); err != nil {
+			return err
+		}
+	}
+	return writeReport(w, cells, results)
+}
+
+// baseline returns the index of the cell results are compared against: the
+// runtime defaults if they are in the matrix, else the first cell.
+func baseline(cells []cell) int {
+	for i, c := range cells {
+		if c.gogc == "100" && c.memlimit == "off" {
+			return i
+		}
+	}
+	return 0
+}
+
+// writeReport prints one row per benchmark with its median ns/op in each
+// cell, then the same medians relative to the baseline cell.
+func writeReport(w io.Writer, cells []cell, results []map[string][]float64) error {
+	if len(results) != len(cells) {
+		return errors.New("gcmatrix: results do not match cells")
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, res := range results {
+		for name := range res {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	base := baseline(cells)
+
+	header := func(title string) {
+		fmt.Fprintf(w, "%-34s", title)
+		for _, c := range cells {
+			fmt.Fprintf(w, "  %20s", c.gogc+"/"+c.memlimit)
+		}
+		fmt.Fprintln(w)
+	}
+	header("med ns/op (GOGC/GOMEMLIMIT)")
+	for _, name := range names {
+		fmt.Fprintf(w, "%-34s", name)
+		for _, res := range results {
+			if vs := res[name]; len(vs) > 0 {
+				fmt.Fprintf(w, "  %20.0f", benchline.Median(vs))
+			} else {
+				fmt.Fprintf(w, "  %20s", "-")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w)
+	header("vs " + cells[base].gogc + "/" + cells[base].memlimit)
+	for _, name := range names {
+		fmt.Fprintf(w, "%-34s", name)
+		ref := benchline.Median(results[base][name])
+		for _, res := range results {
+			vs := res[name]
+			if len(vs) == 0 || ref == 0 {
+				fmt.Fprintf(w, "  %20s", "-")
+				continue
+			}
+			fmt.Fprintf(w, "  %19.2fx", benchline.Median(vs)/ref)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
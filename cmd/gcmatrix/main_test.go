@@ -0,0 +1,46 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatrix(t *testing.T) {
+	cells := matrix(splitList("50, 100,,off"), splitList("off,64MiB"))
+	if len(cells) != 6 || cells[0] != (cell{"50", "off"}) || cells[5] != (cell{"off", "64MiB"}) {
+		t.Fatalf("matrix = %v", cells)
+	}
+	if got := baseline(cells); got != 2 {
+		t.Errorf("baseline = %d, want the GOGC=100 GOMEMLIMIT=off cell", got)
+	}
+	if got := baseline(cells[:1]); got != 0 {
+		t.Errorf("baseline without defaults = %d, want 0", got)
+	}
+}
+
+func TestCellEnv(t *testing.T) {
+	env := cell{"off", "64MiB"}.env([]string{"HOME=/x", "GOGC=25", "GOMEMLIMIT=1GiB", "GOGCX=1"})
+	want := []string{"HOME=/x", "GOGCX=1", "GOGC=off", "GOMEMLIMIT=64MiB"}
+	if !slices.Equal(env, want) {
+		t.Fatalf("env = %v, want %v", env, want)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	cells := []cell{{"50", "off"}, {"100", "off"}}
+	results := []map[string][]float64{
+		{"BenchmarkA-8": {300, 200}},
+		{"BenchmarkA-8": {100}, "BenchmarkB-8": {50}},
+	}
+	var b strings.Builder
+	if err := writeReport(&b, cells, results); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"vs 100/off", "250", "2.50x", "1.00x", "-"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Command layout reports the memory layout of every struct type in the model
+// and persistence packages: its size and alignment, how many of its bytes
+// are padding, how many the garbage collector has to scan for pointers, and
+// the field order that would minimize both:
+//
+//	go run ./cmd/layout
+//
+// Sizes are for -arch, which defaults to the host's. The packages are type
+// checked from source; their imports are read from the compiler's export
+// data, which go list -export builds.
+//
+// A struct's pointer bytes run from its start to the end of its last pointer
+// word, which is the prefix the collector scans. Moving pointer-free fields
+// such as ints, int64s and bools after the strings and slices shortens it
+// even when the size stays the same.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+func main() {
+	pkgs := flag.String("pkgs", "./direct,./encap,./directflat", "comma-separated packages to analyze")
+	arch := flag.String("arch", runtime.GOARCH, "GOARCH whose sizes to report")
+	flag.Parse()
+	var patterns []string
+	for _, p := range strings.Split(*pkgs, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	sizes := types.SizesFor("gc", *arch)
+	if sizes == nil {
+		fmt.Fprintf(os.Stderr, "error: unknown arch %q\n", *arch)
+		os.Exit(2)
+	}
+
+	if err := run(patterns, sizes, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(patterns []string, sizes types.Sizes, w io.Writer) error {
+	pkgs, err := load(patterns)
+	if err != nil {
+		return err
+	}
+	for i, pkg := range pkgs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		writeReport(w, pkg.Path(), structs(pkg, sizes))
+	}
+	return nil
+}
+
+// load type-checks the packages matching patterns.
+func load(patterns []string) ([]*types.Package, error) {
+	args := append([]string{"list", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}\t{{.DepOnly}}\t{{.Dir}}\t{{join .GoFiles \" \"}}"}, patterns...)
+	out, err := exec.Command("go", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+	exports := make(map[string]string)
+	type target struct {
+		path, dir string
+		files     []string
+	}
+	var targets []target
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		f := strings.Split(s.Text(), "\t")
+		if len(f) != 5 {
+			continue
+		}
+		exports[f[0]] = f[1]
+		if f[2] == "false" {
+			targets = append(targets, target{f[0], f[3], strings.Fields(f[4])})
+		}
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok || file == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	})
+	var pkgs []*types.Package
+	for _, t := range targets {
+		var files []*ast.File
+		for _, name := range t.files {
+			f, err := parser.ParseFile(fset, filepath.Join(t.dir, name), nil, parser.SkipObjectResolution)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, f)
+		}
+		conf := types.Config{Importer: imp}
+		pkg, err := conf.Check(t.path, fset, files, nil)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// layout is one struct type's measurements, as declared and with its fields
+// in the optimal order.
+type layout struct {
+	name                 string
+	size, align, padding int64
+	ptrBytes             int64
+	optSize, optPtrBytes int64
+	order, optOrder      []string // field names
+}
+
+// structs measures the package's named struct types, sorted by name.
+// Generic types are skipped, since their layout depends on the type
+// arguments.
+func structs(pkg *types.Package, sizes types.Sizes) []layout {
+	var out []layout
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		fields := make([]*types.Var, st.NumFields())
+		for i := range fields {
+			fields[i] = st.Field(i)
+		}
+		opt := optimalOrder(fields, sizes)
+		l := layout{
+			name:        name,
+			size:        sizes.Sizeof(st),
+			align:       sizes.Alignof(st),
+			ptrBytes:    ptrBytes(st, sizes),
+			order:       fieldNames(fields),
+			optOrder:    fieldNames(opt),
+			optSize:     sizes.Sizeof(types.NewStruct(opt, nil)),
+			optPtrBytes: ptrBytes(types.NewStruct(opt, nil), sizes),
+		}
+		l.padding = l.size
+		for _, f := range fields {
+			l.padding -= sizes.Sizeof(f.Type())
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+func fieldNames(fields []*types.Var) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name()
+	}
+	return names
+}
+
+// optimalOrder sorts fields to minimize first padding and then pointer
+// bytes: zero-sized fields first, then by alignment, largest first; among
+// equal alignments, fields with pointers come before those without, and
+// those with fewer pointer-free trailing bytes first. Ties keep their
+// declared order.
+func optimalOrder(fields []*types.Var, sizes types.Sizes) []*types.Var {
+	opt := append([]*types.Var(nil), fields...)
+	sort.SliceStable(opt, func(i, j int) bool {
+		ti, tj := opt[i].Type(), opt[j].Type()
+		si, sj := sizes.Sizeof(ti), sizes.Sizeof(tj)
+		if (si == 0) != (sj == 0) {
+			return si == 0
+		}
+		if ai, aj := sizes.Alignof(ti), sizes.Alignof(tj); ai != aj {
+			return ai > aj
+		}
+		pi, pj := ptrBytes(ti, sizes), ptrBytes(tj, sizes)
+		if (pi == 0) != (pj == 0) {
+			return pi != 0
+		}
+		if pi != 0 {
+			return si-pi < sj-pj
+		}
+		return false
+	})
+	return opt
+}
+
+// ptrBytes returns the length of the prefix of a value of type t that holds
+// pointers, as the garbage collector sees it.
+func ptrBytes(t types.Type, sizes types.Sizes) int64 {
+	word := sizes.Sizeof(types.Typ[types.Uintptr])
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.String, types.UntypedString, types.UnsafePointer:
+			return word
+		}
+		return 0
+	case *types.Pointer, *types.Map, *types.Chan, *types.Signature, *types.Slice:
+		return word
+	case *types.Interface:
+		return 2 * word
+	case *types.Array:
+		p := ptrBytes(t.Elem(), sizes)
+		if p == 0 || t.Len() == 0 {
+			return 0
+		}
+		return (t.Len()-1)*sizes.Sizeof(t.Elem()) + p
+	case *types.Struct:
+		fields := make([]*types.Var, t.NumFields())
+		for i := range fields {
+			fields[i] = t.Field(i)
+		}
+		offsets := sizes.Offsetsof(fields)
+		var n int64
+		for i, f := range fields {
+			if p := ptrBytes(f.Type(), sizes); p > 0 {
+				n = offsets[i] + p
+			}
+		}
+		return n
+	}
+	return 0
+}
+
+func writeReport(w io.Writer, pkg string, layouts []layout) {
+	fmt.Fprintf(w, "%-40s  %6s  %5s  %7s  %8s  %8s  %12s\n", pkg, "size", "align", "padding", "ptrbytes", "opt size", "opt ptrbytes")
+	for _, l := range layouts {
+		fmt.Fprintf(w, "%-40s  %6d  %5d  %7d  %8d  %8d  %12d\n", l.name, l.size, l.align, l.padding, l.ptrBytes, l.optSize, l.optPtrBytes)
+	}
+	for _, l := range layouts {
+		if l.optSize < l.size || l.optPtrBytes < l.ptrBytes {
+			fmt.Fprintf(w, "  %s: reorder as %s (saves %d bytes, %d pointer bytes)\n",
+				l.name, strings.Join(l.optOrder, ", "), l.size-l.optSize, l.ptrBytes-l.optPtrBytes)
+		}
+	}
+}
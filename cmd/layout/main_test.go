@@ -0,0 +1,97 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const src = `package m
+
+type Row struct {
+	ID    string
+	Qty   int
+	Ok    bool
+	Price int64
+	Cur   string
+	Tags  []string
+}
+
+type Packed struct {
+	A string
+	B int64
+}
+
+type Holes struct {
+	A bool
+	B int64
+	C bool
+}
+
+type Gen[T any] struct{ v T }
+
+type Alias = Packed
+`
+
+func check(t *testing.T) *types.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "m.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("m", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestStructs(t *testing.T) {
+	got := structs(check(t), types.SizesFor("gc", "amd64"))
+	want := []layout{
+		{name: "Holes", size: 24, align: 8, padding: 14, ptrBytes: 0, optSize: 16, optPtrBytes: 0,
+			order: []string{"A", "B", "C"}, optOrder: []string{"B", "A", "C"}},
+		{name: "Packed", size: 24, align: 8, padding: 0, ptrBytes: 8, optSize: 24, optPtrBytes: 8,
+			order: []string{"A", "B"}, optOrder: []string{"A", "B"}},
+		// ID 0-16, Qty 16, Ok 24 (+7), Price 32, Cur 40-56, Tags 56-80.
+		{name: "Row", size: 80, align: 8, padding: 7, ptrBytes: 64, optSize: 80, optPtrBytes: 40,
+			order: []string{"ID", "Qty", "Ok", "Price", "Cur", "Tags"}, optOrder: []string{"ID", "Cur", "Tags", "Qty", "Price", "Ok"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("structs = %+v", got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.name != w.name || g.size != w.size || g.align != w.align || g.padding != w.padding ||
+			g.ptrBytes != w.ptrBytes || g.optSize != w.optSize || g.optPtrBytes != w.optPtrBytes ||
+			!slices.Equal(g.order, w.order) || !slices.Equal(g.optOrder, w.optOrder) {
+			t.Errorf("structs[%d] = %+v, want %+v", i, g, w)
+		}
+	}
+
+	var b strings.Builder
+	writeReport(&b, "m", got)
+	out := b.String()
+	if !strings.Contains(out, "Row: reorder as ID, Cur, Tags, Qty, Price, Ok (saves 0 bytes, 24 pointer bytes)") ||
+		!strings.Contains(out, "Holes: reorder as B, A, C (saves 8 bytes") || strings.Contains(out, "Packed: reorder") {
+		t.Errorf("report:\n%s", out)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	pkgs, err := load([]string{"../../directflat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Path() != "github.com/alechenninger/go-ddd-bench/directflat" {
+		t.Fatalf("load = %v", pkgs)
+	}
+	if pkgs[0].Scope().Lookup("OrderItemRow") == nil {
+		t.Error("directflat has no OrderItemRow")
+	}
+}
@@ -0,0 +1,201 @@
+// Command pgorun measures what profile-guided optimization does for the
+// benchmarks. It collects a CPU profile from the scenario workload, which
+// exercises every variant's create, read, update and delete paths, and then
+// runs the benchmarks from a binary built without PGO and one built with the
+// profile, alternating between them, and reports each benchmark's speedup
+// and how the gap between the variants changes:
+//
+//	go run ./cmd/pgorun -bench 'RMW$' -count 5
+//
+// go test only applies default.pgo to main packages, so the benchmark binary
+// is built with an explicit -pgo flag. To have go build use the profile too,
+// name main packages with -place (for example -place ./cmd/dddload): the
+// profile is copied into each as default.pgo, which every later go build of
+// that package applies until the file is deleted. The files are ignored by
+// git.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchline"
+	"github.com/alechenninger/go-ddd-bench/internal/benchrun"
+)
+
+type config struct {
+	pkg              string
+	profileBench     string // benchmark that produces the profile
+	profileTime      string
+	bench, benchtime string
+	count            int
+	dir              string
+	place            []string // main packages that get default.pgo; none by default
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.pkg, "pkg", ".", "package holding the benchmarks")
+	flag.StringVar(&cfg.profileBench, "profile-bench", "Scenario", "benchmark whose CPU profile guides the build, without the Benchmark prefix")
+	flag.StringVar(&cfg.profileTime, "profile-benchtime", "5x", "-benchtime of the profiling run")
+	flag.StringVar(&cfg.bench, "bench", "RMW$", "benchmarks to compare, as for go test -bench")
+	flag.StringVar(&cfg.benchtime, "benchtime", "1s", "-benchtime of each comparison run")
+	flag.IntVar(&cfg.count, "count", 5, "comparison runs of each binary")
+	flag.StringVar(&cfg.dir, "dir", "pgo", "directory for the binaries, profile and raw results")
+	place := flag.String("place", "", "comma-separated main packages to receive the profile as default.pgo, which later go builds of them apply")
+	flag.Parse()
+	for _, p := range strings.Split(*place, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.place = append(cfg.place, p)
+		}
+	}
+
+	if err := run(cfg, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg config, w io.Writer) error {
+	dir, err := filepath.Abs(cfg.dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	off, err := benchrun.Build(cfg.pkg, filepath.Join(dir, "off.test"), "-pgo=off")
+	if err != nil {
+		return err
+	}
+	prof := filepath.Join(dir, "cpu.pprof")
+	fmt.Fprintf(os.Stderr, "== profiling Benchmark%s\n", cfg.profileBench)
+	err = off.Bench(os.Stderr, nil, "-test.bench=^Benchmark"+cfg.profileBench+"$",
+		"-test.benchtime="+cfg.profileTime, "-test.cpuprofile="+prof)
+	if err != nil {
+		return err
+	}
+	if err := placeProfile(prof, cfg.place); err != nil {
+		return err
+	}
+	pgo, err := benchrun.Build(cfg.pkg, filepath.Join(dir, "pgo.test"), "-pgo="+prof)
+	if err != nil {
+		return err
+	}
+
+	// Alternate the binaries so drift in the machine's speed hits both.
+	var raw [2]bytes.Buffer
+	for i := 0; i < cfg.count; i++ {
+		for j, bin := range []*benchrun.Binary{off, pgo} {
+			fmt.Fprintf(os.Stderr, "== run %d/%d, %s\n", i+1, cfg.count, []string{"without PGO", "with PGO"}[j])
+			err := bin.Bench(io.MultiWriter(&raw[j], os.Stderr), nil,
+				"-test.bench="+cfg.bench, "-test.benchmem", "-test.benchtime="+cfg.benchtime)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for j, name := range []string{"off.txt", "pgo.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), raw[j].Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	offNs, err := benchline.NsPerOp(&raw[0])
+	if err != nil {
+		return err
+	}
+	pgoNs, err := benchline.NsPerOp(&raw[1])
+	if err != nil {
+		return err
+	}
+	writeReport(w, offNs, pgoNs)
+	return nil
+}
+
+// placeProfile copies the profile to default.pgo in each package.
+func placeProfile(prof string, pkgs []string) error {
+	data, err := os.ReadFile(prof)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		dir, err := benchrun.Dir(pkg)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, "default.pgo")
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "== wrote %s\n", dst)
+	}
+	return nil
+}
+
+// variants are the benchmark name prefixes of each variant, longest first
+// so DirectFlat is not taken for Direct.
+var variants = []string{"DirectFlat", "Direct", "Encap"}
+
+// split returns a benchmark's variant and the rest of its name, such as
+// "Encap" and "_RMW" for "BenchmarkEncap_RMW".
+func split(name string) (variant, rest string) {
+	name = strings.TrimPrefix(name, "Benchmark")
+	for _, v := range variants {
+		if strings.HasPrefix(name, v+"_") {
+			return v, name[len(v):]
+		}
+	}
+	return "", name
+}
+
+func writeReport(w io.Writer, off, pgo map[string][]float64) {
+	var names []string
+	for name := range off {
+		if len(pgo[name]) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%-40s  %14s  %14s  %8s\n", "BENCHMARK", "med ns/op off", "med ns/op pgo", "speedup")
+	for _, name := range names {
+		o, p := benchline.Median(off[name]), benchline.Median(pgo[name])
+		fmt.Fprintf(w, "%-40s  %14.0f  %14.0f  %+7.1f%%\n", name, o, p, (o/p-1)*100)
+	}
+
+	// The gap: each variant's ns/op relative to Direct on the same benchmark.
+	type key struct{ variant, rest string }
+	med := make(map[key][2]float64)
+	var rests []string
+	for _, name := range names {
+		v, rest := split(name)
+		if v == "" {
+			continue
+		}
+		if v == "Direct" {
+			rests = append(rests, rest)
+		}
+		med[key{v, rest}] = [2]float64{benchline.Median(off[name]), benchline.Median(pgo[name])}
+	}
+	header := false
+	for _, rest := range rests {
+		d := med[key{"Direct", rest}]
+		for _, v := range variants {
+			m, ok := med[key{v, rest}]
+			if !ok || v == "Direct" {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(w, "\n%-40s  %14s  %14s\n", "VS DIRECT", "off", "pgo")
+				header = true
+			}
+			fmt.Fprintf(w, "%-40s  %13.2fx  %13.2fx\n", v+rest, m[0]/d[0], m[1]/d[1])
+		}
+	}
+}
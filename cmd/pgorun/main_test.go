@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	for _, tt := range []struct{ name, variant, rest string }{
+		{"BenchmarkDirect_RMW", "Direct", "_RMW"},
+		{"BenchmarkDirectFlat_RMW-8", "DirectFlat", "_RMW-8"},
+		{"BenchmarkEncap_Load", "Encap", "_Load"},
+		{"BenchmarkScenario/direct", "", "Scenario/direct"},
+		{"BenchmarkDirectly", "", "Directly"},
+	} {
+		if v, rest := split(tt.name); v != tt.variant || rest != tt.rest {
+			t.Errorf("split(%q) = %q, %q; want %q, %q", tt.name, v, rest, tt.variant, tt.rest)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	off := map[string][]float64{
+		"BenchmarkDirect_RMW": {100, 120, 110},
+		"BenchmarkEncap_RMW":  {200},
+		"BenchmarkOther":      {10},
+		"BenchmarkOnlyOff":    {5},
+	}
+	pgo := map[string][]float64{
+		"BenchmarkDirect_RMW": {100},
+		"BenchmarkEncap_RMW":  {160},
+		"BenchmarkOther":      {10},
+	}
+	var b strings.Builder
+	writeReport(&b, off, pgo)
+	out := b.String()
+	for _, want := range []string{"+10.0%", "+25.0%", "VS DIRECT", "1.82x", "1.60x"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "OnlyOff") {
+		t.Errorf("report lists a benchmark without PGO results:\n%s", out)
+	}
+}
@@ -0,0 +1,195 @@
+// Command profdiff runs two benchmarks with CPU and memory profiling and
+// prints, per function, how their CPU time and allocated bytes per operation
+// differ:
+//
+//	go run ./cmd/profdiff -a Direct_RMW -b Encap_RMW
+//
+// Both benchmarks run from one test binary for a fixed number of operations,
+// so totals divide into per-operation figures. Each runs twice: once with
+// the CPU profiler, and once, for fewer operations, recording every
+// allocation, which would slow the CPU run down many times over. The figures
+// include the benchmark's setup, such as seeding the repository, which is
+// small next to the default operation counts but not zero. The profiles are
+// kept in -dir for go tool pprof.
+//
+// By default only this module's functions are listed. Time and allocations
+// in other code, such as encoding/json or the runtime, are charged to the
+// module function that called it, so each row's flat value is what that
+// function costs including the library calls it makes directly.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchrun"
+	"github.com/alechenninger/go-ddd-bench/internal/profile"
+)
+
+const module = "github.com/alechenninger/go-ddd-bench"
+
+type config struct {
+	pkg, a, b string
+	cpuOps    int
+	memOps    int
+	dir       string
+	filter    string // function name prefix; empty keeps everything
+	top       int
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.pkg, "pkg", ".", "package holding both benchmarks")
+	flag.StringVar(&cfg.a, "a", "Direct_RMW", "first benchmark, without the Benchmark prefix")
+	flag.StringVar(&cfg.b, "b", "Encap_RMW", "second benchmark, compared against the first")
+	flag.IntVar(&cfg.cpuOps, "cpuops", 200000, "operations each benchmark runs for the CPU profile")
+	flag.IntVar(&cfg.memOps, "memops", 20000, "operations each benchmark runs for the memory profile")
+	flag.StringVar(&cfg.dir, "dir", "profdiff", "directory for the test binary and profiles")
+	flag.StringVar(&cfg.filter, "filter", module, "list only functions whose names start with this; empty lists all")
+	flag.IntVar(&cfg.top, "top", 25, "rows per table, largest differences first")
+	flag.Parse()
+
+	if err := run(cfg, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg config, w io.Writer) error {
+	dir, err := filepath.Abs(cfg.dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	bin, err := benchrun.Build(cfg.pkg, filepath.Join(dir, "bench.test"))
+	if err != nil {
+		return err
+	}
+
+	var cpu, mem [2]*profile.Profile
+	bench := func(name string, ops int, flags ...string) error {
+		args := append([]string{"-test.bench=^Benchmark" + name + "$", "-test.benchmem",
+			fmt.Sprintf("-test.benchtime=%dx", ops)}, flags...)
+		return bin.Bench(os.Stderr, nil, args...)
+	}
+	for i, name := range []string{cfg.a, cfg.b} {
+		cpuPath := filepath.Join(dir, name+".cpu.pprof")
+		memPath := filepath.Join(dir, name+".mem.pprof")
+		if err := bench(name, cfg.cpuOps, "-test.cpuprofile="+cpuPath); err != nil {
+			return err
+		}
+		if err := bench(name, cfg.memOps, "-test.memprofile="+memPath, "-test.memprofilerate=1"); err != nil {
+			return err
+		}
+		if cpu[i], err = profile.ReadFile(cpuPath); err != nil {
+			return err
+		}
+		if mem[i], err = profile.ReadFile(memPath); err != nil {
+			return err
+		}
+	}
+
+	var keep func(string) bool
+	if cfg.filter != "" {
+		keep = func(fn string) bool { return strings.HasPrefix(fn, cfg.filter) }
+	}
+	for _, t := range []struct {
+		title, sampleType string
+		profs             [2]*profile.Profile
+		ops               int
+	}{
+		{"CPU ns/op", "cpu", cpu, cfg.cpuOps},
+		{"alloc B/op", "alloc_space", mem, cfg.memOps},
+	} {
+		d, err := diff(t.profs, t.sampleType, keep, float64(t.ops))
+		if err != nil {
+			return err
+		}
+		writeTable(w, t.title, cfg.a, cfg.b, d, cfg.top)
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// row is one function's per-operation values in each profile.
+type row struct {
+	name      string
+	flat, cum [2]float64
+}
+
+// diffs holds the rows of a comparison and each profile's total.
+type diffs struct {
+	total [2]float64
+	rows  []row
+}
+
+func diff(profs [2]*profile.Profile, sampleType string, keep func(string) bool, ops float64) (diffs, error) {
+	var d diffs
+	byName := make(map[string]*row)
+	for i, p := range profs {
+		idx := p.Index(sampleType)
+		if idx < 0 {
+			return diffs{}, fmt.Errorf("profile has no %s samples", sampleType)
+		}
+		d.total[i] = float64(p.Total(idx)) / ops
+		for fn, t := range p.ByFunction(idx, keep) {
+			r := byName[fn]
+			if r == nil {
+				r = &row{name: fn}
+				byName[fn] = r
+			}
+			r.flat[i] = float64(t.Flat) / ops
+			r.cum[i] = float64(t.Cum) / ops
+		}
+	}
+	for _, r := range byName {
+		if max(r.cum[0], r.cum[1]) >= 0.5 { // would print as all zeros
+			d.rows = append(d.rows, *r)
+		}
+	}
+	sort.Slice(d.rows, func(i, j int) bool {
+		ri, rj := d.rows[i], d.rows[j]
+		if fi, fj := math.Abs(ri.flat[1]-ri.flat[0]), math.Abs(rj.flat[1]-rj.flat[0]); fi != fj {
+			return fi > fj
+		}
+		if ci, cj := math.Abs(ri.cum[1]-ri.cum[0]), math.Abs(rj.cum[1]-rj.cum[0]); ci != cj {
+			return ci > cj
+		}
+		return ri.name < rj.name
+	})
+	return d, nil
+}
+
+func writeTable(w io.Writer, title, a, b string, d diffs, top int) {
+	fmt.Fprintf(w, "%-52s  %10s  %10s  %10s  |  %10s  %10s  %10s\n", title, "flat a", "flat b", "delta", "cum a", "cum b", "delta")
+	fmt.Fprintf(w, "%-52s  %10.0f  %10.0f  %+10.0f  |\n", "total ("+a+" vs "+b+")", d.total[0], d.total[1], d.total[1]-d.total[0])
+	for i, r := range d.rows {
+		if i == top {
+			fmt.Fprintf(w, "... %d more\n", len(d.rows)-top)
+			break
+		}
+		fmt.Fprintf(w, "%-52s  %10.0f  %10.0f  %+10.0f  |  %10.0f  %10.0f  %+10.0f\n",
+			shortName(r.name), r.flat[0], r.flat[1], r.flat[1]-r.flat[0], r.cum[0], r.cum[1], r.cum[1]-r.cum[0])
+	}
+}
+
+// shortName drops the directories of a function's import path, leaving
+// package.Function as pprof's -short option would.
+func shortName(fn string) string {
+	path := fn
+	if i := strings.IndexAny(path, "[("); i >= 0 {
+		path = path[:i] // generic arguments and receivers may hold paths too
+	}
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return fn[i+1:]
+	}
+	return fn
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/profile"
+)
+
+func TestShortName(t *testing.T) {
+	for in, want := range map[string]string{
+		module + "/encap.(*Repo).FindByID":                                 "encap.(*Repo).FindByID",
+		module + ".BenchmarkEncap_RMW":                                     "go-ddd-bench.BenchmarkEncap_RMW",
+		"encoding/json.(*decodeState).object":                              "json.(*decodeState).object",
+		module + "/internal/schema.Wrap[go.shape.*" + module + "/encap.X]": "schema.Wrap[go.shape.*" + module + "/encap.X]",
+		"runtime.mallocgc":                                                 "runtime.mallocgc",
+	} {
+		if got := shortName(in); got != want {
+			t.Errorf("shortName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	types := []profile.ValueType{{Type: "alloc_space", Unit: "bytes"}}
+	a := &profile.Profile{SampleTypes: types, Samples: []profile.Sample{
+		{Stack: []string{"runtime.mallocgc", module + "/direct.Save", module + ".Bench"}, Values: []int64{100}},
+	}}
+	b := &profile.Profile{SampleTypes: types, Samples: []profile.Sample{
+		{Stack: []string{"runtime.mallocgc", module + "/encap.Save", module + ".Bench"}, Values: []int64{300}},
+		{Stack: []string{module + "/encap.toRecord", module + "/encap.Save", module + ".Bench"}, Values: []int64{50}},
+		{Stack: []string{"runtime.gcBgMarkWorker"}, Values: []int64{10}},
+	}}
+	keep := func(fn string) bool { return strings.HasPrefix(fn, module) }
+	d, err := diff([2]*profile.Profile{a, b}, "alloc_space", keep, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.total != [2]float64{10, 36} {
+		t.Errorf("totals = %v", d.total)
+	}
+	var names []string
+	for _, r := range d.rows {
+		names = append(names, shortName(r.name))
+	}
+	// encap.Save changed most in flat, then direct.Save, then the rest.
+	if got := strings.Join(names, " "); got != "encap.Save direct.Save encap.toRecord go-ddd-bench.Bench" {
+		t.Fatalf("rows = %s", got)
+	}
+	if r := d.rows[0]; r.flat != [2]float64{0, 30} || r.cum != [2]float64{0, 35} {
+		t.Errorf("encap.Save = %+v", r)
+	}
+	if _, err := diff([2]*profile.Profile{a, b}, "cpu", keep, 1); err == nil {
+		t.Error("diff of a missing sample type succeeded")
+	}
+	var out strings.Builder
+	writeTable(&out, "alloc B/op", "A", "B", d, 2)
+	if !strings.Contains(out.String(), "+26") || !strings.Contains(out.String(), "... 2 more") {
+		t.Errorf("table:\n%s", out.String())
+	}
+}
@@ -0,0 +1,369 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+)
+
+// Mixed-command workload used by the RMW benches. Each order steps through
+// the commands on successive visits: add "C", change its quantity, discount
+// the order, update loyalty points, then remove "C" again. This exercises
+// every mutation while keeping the aggregate size bounded.
+//
+// Only the first cycle over the orders discounts them by 5%. Later cycles
+// apply a 0% discount, which takes the same path, touching and dirtying
+// every item, without lowering prices again. Otherwise prices would keep
+// shrinking towards zero as b.N grows, and a longer run would measure
+// different data.
+const (
+	nMixedSteps    = 5
+	stepDiscount   = 2
+	stepNoDiscount = nMixedSteps // stepDiscount after the first cycle
+)
+
+// mixedStep returns the command step for iteration i over nIDs orders.
+func mixedStep(i, nIDs int) int {
+	step := (i / nIDs) % nMixedSteps
+	if step == stepDiscount && i >= nIDs*nMixedSteps {
+		return stepNoDiscount
+	}
+	return step
+}
+
+// discountPercent is the discount the workload applies at step.
+func discountPercent(step int) int {
+	if step == stepDiscount {
+		return 5
+	}
+	return 0
+}
+
+func applyDirect(o *direct.Order, step, i int) error {
+	switch step {
+	case 0:
+		return o.AddItem("C", 1, 99, "USD", direct.ItemFlags{Digital: true})
+	case 1:
+		return o.ChangeQuantity("C", 3)
+	case stepDiscount, stepNoDiscount:
+		return o.ApplyDiscount(discountPercent(step))
+	case 3:
+		o.UpdateLoyaltyPoints(i)
+		return nil
+	default:
+		return o.RemoveItem("C")
+	}
+}
+
+func applyEncap(o *encap.Order, step, i int) error {
+	switch step {
+	case 0:
+		return o.AddItem("C", 1, 99, "USD", encap.SnapshotItemFlags{Digital: true})
+	case 1:
+		return o.ChangeQuantity("C", 3)
+	case stepDiscount, stepNoDiscount:
+		return o.ApplyDiscount(discountPercent(step))
+	case 3:
+		o.UpdateLoyaltyPoints(i)
+		return nil
+	default:
+		return o.RemoveItem("C")
+	}
+}
+
+func applyDirectFlat(r *directflat.OrderRecord, step, i int) error {
+	switch step {
+	case 0:
+		return r.AddItem("C", 1, 99, "USD", false, true)
+	case 1:
+		return r.ChangeQuantity("C", 3)
+	case stepDiscount, stepNoDiscount:
+		return r.ApplyDiscount(discountPercent(step))
+	case 3:
+		r.UpdateLoyaltyPoints(i)
+		return nil
+	default:
+		return r.RemoveItem("C")
+	}
+}
+
+// Variant-neutral errors the command test compares, since each variant
+// declares its own.
+var (
+	errNotFound        = errors.New("item not found")
+	errInvalidQuantity = errors.New("invalid quantity")
+	errInvalidDiscount = errors.New("invalid discount")
+)
+
+// neutral maps a variant's command error to the matching neutral error.
+func neutral(err, notFound, invalidQty, invalidDiscount error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, notFound):
+		return errNotFound
+	case errors.Is(err, invalidQty):
+		return errInvalidQuantity
+	case errors.Is(err, invalidDiscount):
+		return errInvalidDiscount
+	}
+	return err
+}
+
+// commandTarget runs the commands on one variant's order.
+type commandTarget interface {
+	AddItem(sku string, qty int, priceCents int64, currency string) error
+	RemoveItem(sku string) error
+	ChangeQuantity(sku string, qty int) error
+	ApplyDiscount(percent int) error
+	UpdateLoyaltyPoints(points int)
+	state() (commandState, error)
+}
+
+// commandState is the state the commands change: one "SKU qty@price" per
+// item, loyalty points, and the formatted per-currency totals.
+type commandState struct {
+	Items  []string
+	Points int
+	Totals string
+}
+
+func formatTotals[M interface{ AppendFormat([]byte) []byte }](totals []M) string {
+	var b []byte
+	for i, m := range totals {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = m.AppendFormat(b)
+	}
+	return string(b)
+}
+
+type directTarget struct{ o *direct.Order }
+
+// newDirectTarget returns an empty direct order "o".
+func newDirectTarget() commandTarget { return directTarget{&direct.Order{ID: "o"}} }
+
+func (t directTarget) AddItem(sku string, qty int, priceCents int64, currency string) error {
+	return neutral(t.o.AddItem(sku, qty, priceCents, currency, direct.ItemFlags{}), direct.ErrItemNotFound, direct.ErrInvalidQuantity, direct.ErrInvalidDiscount)
+}
+func (t directTarget) RemoveItem(sku string) error {
+	return neutral(t.o.RemoveItem(sku), direct.ErrItemNotFound, direct.ErrInvalidQuantity, direct.ErrInvalidDiscount)
+}
+func (t directTarget) ChangeQuantity(sku string, qty int) error {
+	return neutral(t.o.ChangeQuantity(sku, qty), direct.ErrItemNotFound, direct.ErrInvalidQuantity, direct.ErrInvalidDiscount)
+}
+func (t directTarget) ApplyDiscount(percent int) error {
+	return neutral(t.o.ApplyDiscount(percent), direct.ErrItemNotFound, direct.ErrInvalidQuantity, direct.ErrInvalidDiscount)
+}
+func (t directTarget) UpdateLoyaltyPoints(points int) { t.o.UpdateLoyaltyPoints(points) }
+func (t directTarget) state() (commandState, error) {
+	s := commandState{Points: t.o.Customer.Loyalty.Points}
+	for _, it := range t.o.Items {
+		s.Items = append(s.Items, fmt.Sprintf("%s %d@%v", it.SKU, it.Quantity, it.Price))
+	}
+	totals, err := t.o.Total()
+	s.Totals = formatTotals(totals)
+	return s, err
+}
+
+type encapTarget struct{ o *encap.Order }
+
+// newEncapTarget returns an empty encap order "o".
+func newEncapTarget() commandTarget {
+	return encapTarget{encap.NewOrder(nil, "o", encap.SnapshotCustomer{}, encap.SnapshotAddress{}, encap.SnapshotAddress{})}
+}
+
+func (t encapTarget) AddItem(sku string, qty int, priceCents int64, currency string) error {
+	return neutral(t.o.AddItem(sku, qty, priceCents, currency, encap.SnapshotItemFlags{}), encap.ErrItemNotFound, encap.ErrInvalidQuantity, encap.ErrInvalidDiscount)
+}
+func (t encapTarget) RemoveItem(sku string) error {
+	return neutral(t.o.RemoveItem(sku), encap.ErrItemNotFound, encap.ErrInvalidQuantity, encap.ErrInvalidDiscount)
+}
+func (t encapTarget) ChangeQuantity(sku string, qty int) error {
+	return neutral(t.o.ChangeQuantity(sku, qty), encap.ErrItemNotFound, encap.ErrInvalidQuantity, encap.ErrInvalidDiscount)
+}
+func (t encapTarget) ApplyDiscount(percent int) error {
+	return neutral(t.o.ApplyDiscount(percent), encap.ErrItemNotFound, encap.ErrInvalidQuantity, encap.ErrInvalidDiscount)
+}
+func (t encapTarget) UpdateLoyaltyPoints(points int) { t.o.UpdateLoyaltyPoints(points) }
+func (t encapTarget) state() (commandState, error) {
+	snap := t.o.ToSnapshot()
+	s := commandState{Points: snap.Customer.Loyalty.Points}
+	for _, it := range snap.Items {
+		s.Items = append(s.Items, fmt.Sprintf("%s %d@%v", it.SKU, it.Quantity, encap.NewMoney(it.Price.Cents, it.Price.Currency)))
+	}
+	totals, err := t.o.Total()
+	s.Totals = formatTotals(totals)
+	return s, err
+}
+
+type directFlatTarget struct{ r *directflat.OrderRecord }
+
+func (t directFlatTarget) AddItem(sku string, qty int, priceCents int64, currency string) error {
+	return neutral(t.r.AddItem(sku, qty, priceCents, currency, false, false), directflat.ErrItemNotFound, directflat.ErrInvalidQuantity, directflat.ErrInvalidDiscount)
+}
+func (t directFlatTarget) RemoveItem(sku string) error {
+	return neutral(t.r.RemoveItem(sku), directflat.ErrItemNotFound, directflat.ErrInvalidQuantity, directflat.ErrInvalidDiscount)
+}
+func (t directFlatTarget) ChangeQuantity(sku string, qty int) error {
+	return neutral(t.r.ChangeQuantity(sku, qty), directflat.ErrItemNotFound, directflat.ErrInvalidQuantity, directflat.ErrInvalidDiscount)
+}
+func (t directFlatTarget) ApplyDiscount(percent int) error {
+	return neutral(t.r.ApplyDiscount(percent), directflat.ErrItemNotFound, directflat.ErrInvalidQuantity, directflat.ErrInvalidDiscount)
+}
+func (t directFlatTarget) UpdateLoyaltyPoints(points int) { t.r.UpdateLoyaltyPoints(points) }
+func (t directFlatTarget) state() (commandState, error) {
+	s := commandState{Points: t.r.Header.LoyaltyPoints}
+	for _, it := range t.r.Items {
+		s.Items = append(s.Items, fmt.Sprintf("%s %d@%v", it.SKU, it.Quantity, it.Price()))
+	}
+	totals, err := t.r.Total()
+	s.Totals = formatTotals(totals)
+	return s, err
+}
+
+// command is one step of a sequence and the error it should fail with.
+type command struct {
+	name string
+	run  func(commandTarget) error
+	err  error
+}
+
+func add(sku string, qty int, cents int64, currency string, err error) command {
+	return command{fmt.Sprintf("AddItem(%q, %d, %d, %q)", sku, qty, cents, currency), func(t commandTarget) error { return t.AddItem(sku, qty, cents, currency) }, err}
+}
+
+func remove(sku string, err error) command {
+	return command{fmt.Sprintf("RemoveItem(%q)", sku), func(t commandTarget) error { return t.RemoveItem(sku) }, err}
+}
+
+func changeQty(sku string, qty int, err error) command {
+	return command{fmt.Sprintf("ChangeQuantity(%q, %d)", sku, qty), func(t commandTarget) error { return t.ChangeQuantity(sku, qty) }, err}
+}
+
+func discount(percent int, err error) command {
+	return command{fmt.Sprintf("ApplyDiscount(%d)", percent), func(t commandTarget) error { return t.ApplyDiscount(percent) }, err}
+}
+
+func points(n int) command {
+	return command{fmt.Sprintf("UpdateLoyaltyPoints(%d)", n), func(t commandTarget) error { t.UpdateLoyaltyPoints(n); return nil }, nil}
+}
+
+// TestCommands_SameAcrossVariants runs each command sequence on a fresh
+// order of every variant and checks that all of them fail the same steps
+// and end in the same state.
+func TestCommands_SameAcrossVariants(t *testing.T) {
+	targets := []struct {
+		name string
+		new  func() commandTarget
+	}{
+		{"direct", newDirectTarget},
+		{"encap", newEncapTarget},
+		{"directflat", func() commandTarget { return directFlatTarget{directflat.NewOrderRecord(nil, "o", "", "", "", "", 0)} }},
+	}
+	for _, tc := range []struct {
+		name string
+		cmds []command
+		want commandState
+	}{
+		{
+			name: "mixed",
+			cmds: []command{add("A", 1, 1000, "USD", nil), add("B", 2, 500, "USD", nil), changeQty("B", 3, nil), discount(10, nil), points(42), remove("A", nil)},
+			want: commandState{Items: []string{"B 3@4.50 USD"}, Points: 42, Totals: "13.50 USD"},
+		},
+		{
+			name: "invalid",
+			cmds: []command{
+				add("A", 0, 100, "USD", errInvalidQuantity), add("A", -1, 100, "USD", errInvalidQuantity),
+				add("A", 1, 100, "USD", nil), changeQty("A", 0, errInvalidQuantity), changeQty("A", -5, errInvalidQuantity),
+				discount(-1, errInvalidDiscount), discount(101, errInvalidDiscount),
+			},
+			want: commandState{Items: []string{"A 1@1.00 USD"}, Totals: "1.00 USD"},
+		},
+		{
+			name: "missing",
+			cmds: []command{remove("A", errNotFound), changeQty("A", 2, errNotFound), add("A", 1, 100, "USD", nil), remove("A", nil), remove("A", errNotFound)},
+			want: commandState{},
+		},
+		{
+			name: "duplicate SKUs",
+			cmds: []command{add("A", 1, 100, "USD", nil), add("A", 2, 200, "USD", nil), changeQty("A", 5, nil), add("B", 1, 1, "USD", nil), remove("A", nil)},
+			want: commandState{Items: []string{"A 2@2.00 USD", "B 1@0.01 USD"}, Totals: "4.01 USD"},
+		},
+		{
+			name: "discounts to zero",
+			cmds: []command{add("A", 1, 99, "USD", nil), add("B", 2, 1999, "EUR", nil), add("C", 3, -10, "USD", nil), discount(5, nil), discount(100, nil), discount(0, nil)},
+			want: commandState{Items: []string{"A 1@0.00 USD", "B 2@0.00 EUR", "C 3@0.00 USD"}, Totals: "0.00 USD, 0.00 EUR"},
+		},
+		{
+			name: "discount extreme prices",
+			cmds: []command{add("A", 1, math.MaxInt64, "USD", nil), add("B", 1, math.MinInt64, "EUR", nil), discount(50, nil)},
+			want: commandState{
+				Items:  []string{"A 1@46116860184273879.04 USD", "B 1@-46116860184273879.04 EUR"},
+				Totals: "46116860184273879.04 USD, -46116860184273879.04 EUR",
+			},
+		},
+		{
+			name: "discount extreme prices to zero",
+			cmds: []command{add("A", 1, math.MaxInt64, "USD", nil), add("B", 1, 1e17, "USD", nil), discount(100, nil)},
+			want: commandState{Items: []string{"A 1@0.00 USD", "B 1@0.00 USD"}, Totals: "0.00 USD"},
+		},
+		{
+			name: "discount rounds down",
+			cmds: []command{add("A", 1, 99, "USD", nil), add("B", 2, 1999, "EUR", nil), add("C", 3, -10, "USD", nil), discount(5, nil)},
+			want: commandState{Items: []string{"A 1@0.95 USD", "B 2@19.00 EUR", "C 3@-0.10 USD"}, Totals: "0.65 USD, 38.00 EUR"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, v := range targets {
+				target := v.new()
+				for _, c := range tc.cmds {
+					if err := c.run(target); err != c.err {
+						t.Errorf("%s: %s = %v, want %v", v.name, c.name, err, c.err)
+					}
+				}
+				got, err := target.state()
+				if err != nil {
+					t.Errorf("%s: Total: %v", v.name, err)
+				}
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("%s: state = %+v, want %+v", v.name, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+// The mixed workload lowers prices once; after that the data every cycle
+// sees stays the same however long a benchmark runs.
+func TestMixedWorkload_PricesStable(t *testing.T) {
+	const nIDs = 1
+	o := &direct.Order{ID: "o"}
+	if err := o.AddItem("A", 1, 10000, "USD", direct.ItemFlags{}); err != nil {
+		t.Fatal(err)
+	}
+	prices := func() string { return fmt.Sprint(o.Items) }
+	var afterFirst string
+	for i := 0; i < 20*nMixedSteps; i++ {
+		if err := applyDirect(o, mixedStep(i, nIDs), i); err != nil {
+			t.Fatal(err)
+		}
+		if i%nMixedSteps == nMixedSteps-1 {
+			if afterFirst == "" {
+				afterFirst = prices()
+			} else if got := prices(); got != afterFirst {
+				t.Fatalf("after cycle %d: items %s, want %s as after the first", i/nMixedSteps, got, afterFirst)
+			}
+		}
+	}
+	if o.Items[0].Price.Cents != 9500 {
+		t.Fatalf("price = %d, want one 5%% discount", o.Items[0].Price.Cents)
+	}
+}
@@ -0,0 +1,38 @@
+package direct
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/allocbudget"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+)
+
+// allocBudgets caps the heap allocations of each mapping of o, an order with
+// line items.
+func allocBudgets(o *Order) []allocbudget.Budget {
+	return []allocbudget.Budget{
+		// The header is a value; the items slice is the only allocation.
+		{Name: "toPersistenceRecord", Allocs: 1, Run: func() { allocsRecord = toPersistenceRecord(o) }},
+		// The items slice and the Order.
+		{Name: "fromPersistenceRecord", Allocs: 2, Run: func() { allocsOrder = fromPersistenceRecord(allocsRecord) }},
+		{Name: "roundTripDirect", Allocs: 3, Run: func() { allocsOrder = roundTripDirect(o) }},
+	}
+}
+
+var (
+	allocsRecord persistenceRecord
+	allocsOrder  *Order
+)
+
+func TestAllocBudgets(t *testing.T) {
+	var o *Order
+	for _, c := range seedDirectOrders(clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond), 64) {
+		if len(c.Items) > 1 {
+			o = c
+			break
+		}
+	}
+	allocsRecord = toPersistenceRecord(o)
+	allocbudget.Check(t, allocBudgets(o))
+}
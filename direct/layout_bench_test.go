@@ -0,0 +1,115 @@
+package direct
+
+import (
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/layoutbench"
+)
+
+// The reordered types are the field orders cmd/layout suggests for the
+// declared ones: the same fields and size, with pointer-free fields last.
+
+type orderItemRowReordered struct {
+	OrderID    string
+	SKU        string
+	Currency   string
+	Quantity   int
+	PriceCents int64
+	Backorder  bool
+	Digital    bool
+}
+
+type orderHeaderReordered struct {
+	ID            string
+	CustomerFirst string
+	CustomerLast  string
+	CustomerEmail string
+	CustomerPhone string
+	LoyaltyTier   string
+	Street1       string
+	Street2       string
+	City          string
+	State         string
+	Zip           string
+	BillStreet1   string
+	BillStreet2   string
+	BillCity      string
+	BillState     string
+	BillZip       string
+	LoyaltyPoints int
+	CreatedAt     int64
+	UpdatedAt     int64
+}
+
+type lineItemReordered struct {
+	SKU      string
+	Price    Money
+	Quantity int
+	Flags    ItemFlags
+}
+
+// The reordered copies are written by hand; this keeps them in step with
+// the declared types.
+func TestLayout_ReorderedSameFields(t *testing.T) {
+	if err := layoutbench.SameFields[OrderItemRow, orderItemRowReordered](); err != nil {
+		t.Error(err)
+	}
+	if err := layoutbench.SameFields[OrderHeader, orderHeaderReordered](); err != nil {
+		t.Error(err)
+	}
+	if err := layoutbench.SameFields[LineItem, lineItemReordered](); err != nil {
+		t.Error(err)
+	}
+}
+
+// layoutStrings supplies field values without allocating.
+var layoutStrings = [...]string{"order-1", "SKU-42", "USD", "Ada", "Lovelace", "ada@example.com", "1 Main St"}
+
+func layoutString(i int) string { return layoutStrings[i%len(layoutStrings)] }
+
+func BenchmarkDirect_Layout_OrderItemRow(b *testing.B) {
+	layoutbench.Compare(b,
+		func(r *OrderItemRow, i int) {
+			*r = OrderItemRow{OrderID: layoutString(i), SKU: layoutString(i + 1), Quantity: i, PriceCents: int64(i), Currency: layoutString(i + 2), Backorder: i%2 == 0, Digital: i%3 == 0}
+		},
+		func(r *orderItemRowReordered, i int) {
+			*r = orderItemRowReordered{OrderID: layoutString(i), SKU: layoutString(i + 1), Quantity: i, PriceCents: int64(i), Currency: layoutString(i + 2), Backorder: i%2 == 0, Digital: i%3 == 0}
+		},
+	)
+}
+
+func BenchmarkDirect_Layout_OrderHeader(b *testing.B) {
+	layoutbench.Compare(b,
+		func(h *OrderHeader, i int) {
+			*h = OrderHeader{
+				ID: layoutString(i), CustomerFirst: layoutString(i + 1), CustomerLast: layoutString(i + 2),
+				CustomerEmail: layoutString(i + 3), CustomerPhone: layoutString(i + 4), LoyaltyTier: layoutString(i + 5),
+				LoyaltyPoints: i, Street1: layoutString(i + 6), Street2: layoutString(i), City: layoutString(i + 1),
+				State: layoutString(i + 2), Zip: layoutString(i + 3), BillStreet1: layoutString(i + 4),
+				BillStreet2: layoutString(i + 5), BillCity: layoutString(i + 6), BillState: layoutString(i),
+				BillZip: layoutString(i + 1), CreatedAt: int64(i), UpdatedAt: int64(i + 1),
+			}
+		},
+		func(h *orderHeaderReordered, i int) {
+			*h = orderHeaderReordered{
+				ID: layoutString(i), CustomerFirst: layoutString(i + 1), CustomerLast: layoutString(i + 2),
+				CustomerEmail: layoutString(i + 3), CustomerPhone: layoutString(i + 4), LoyaltyTier: layoutString(i + 5),
+				LoyaltyPoints: i, Street1: layoutString(i + 6), Street2: layoutString(i), City: layoutString(i + 1),
+				State: layoutString(i + 2), Zip: layoutString(i + 3), BillStreet1: layoutString(i + 4),
+				BillStreet2: layoutString(i + 5), BillCity: layoutString(i + 6), BillState: layoutString(i),
+				BillZip: layoutString(i + 1), CreatedAt: int64(i), UpdatedAt: int64(i + 1),
+			}
+		},
+	)
+}
+
+func BenchmarkDirect_Layout_LineItem(b *testing.B) {
+	layoutbench.Compare(b,
+		func(it *LineItem, i int) {
+			*it = LineItem{SKU: layoutString(i), Quantity: i, Price: Money{Cents: int64(i), Currency: layoutString(i + 2)}, Flags: ItemFlags{Backorder: i%2 == 0}}
+		},
+		func(it *lineItemReordered, i int) {
+			*it = lineItemReordered{SKU: layoutString(i), Quantity: i, Price: Money{Cents: int64(i), Currency: layoutString(i + 2)}, Flags: ItemFlags{Backorder: i%2 == 0}}
+		},
+	)
+}
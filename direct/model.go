@@ -1,9 +1,21 @@
 package direct
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/money"
+)
+
+var (
+	// ErrItemNotFound is returned when no line item has the requested SKU.
+	ErrItemNotFound = errors.New("item not found")
+	// ErrInvalidQuantity is returned for line quantities below one.
+	ErrInvalidQuantity = errors.New("invalid quantity")
+	// ErrInvalidDiscount is returned for discounts outside 0-100 percent.
+	ErrInvalidDiscount = errors.New("invalid discount")
 )
 
 // Name nested under Customer
@@ -22,21 +34,17 @@ type Loyalty struct {
 type Customer struct {
 	Name    Name
 	Email   string
+	Phone   string
 	Loyalty Loyalty
 }
 
 // Address value object
 type Address struct {
-	Street string
-	City   string
-	State  string
-	Zip    string
-}
-
-// Money nested under LineItem
-type Money struct {
-	Cents    int64
-	Currency string
+	Street1 string
+	Street2 string
+	City    string
+	State   string
+	Zip     string
 }
 
 // ItemFlags nested under LineItem
@@ -62,11 +70,20 @@ type Order struct {
 	Items     []LineItem
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Clock stamps UpdatedAt; nil is the real clock.
+	Clock clock.Clock `json:"-"`
 }
 
-func (o *Order) AddItem(sku string, qty int, priceCents int64, currency string, flags ItemFlags) {
+// AddItem appends a line item. Like ChangeQuantity, it rejects quantities
+// below one.
+func (o *Order) AddItem(sku string, qty int, priceCents int64, currency string, flags ItemFlags) error {
+	if qty < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, qty)
+	}
 	o.Items = append(o.Items, LineItem{SKU: sku, Quantity: qty, Price: Money{Cents: priceCents, Currency: currency}, Flags: flags})
 	o.touch()
+	return nil
 }
 
 func (o *Order) UpdateShipping(addr Address) {
@@ -79,4 +96,73 @@ func (o *Order) UpdateBilling(addr Address) {
 	o.touch()
 }
 
-func (o *Order) touch() { o.UpdatedAt = clock.Now() }
+// RemoveItem removes the first line item with the given SKU.
+func (o *Order) RemoveItem(sku string) error {
+	i := o.itemIndex(sku)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrItemNotFound, sku)
+	}
+	o.Items = append(o.Items[:i], o.Items[i+1:]...)
+	o.touch()
+	return nil
+}
+
+// ChangeQuantity sets the quantity of the first line item with the given SKU.
+func (o *Order) ChangeQuantity(sku string, qty int) error {
+	if qty < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, qty)
+	}
+	i := o.itemIndex(sku)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrItemNotFound, sku)
+	}
+	o.Items[i].Quantity = qty
+	o.touch()
+	return nil
+}
+
+// ApplyDiscount reduces every item's unit price by percent, rounding the
+// discount down to whole cents.
+func (o *Order) ApplyDiscount(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("%w: %d%%", ErrInvalidDiscount, percent)
+	}
+	for i := range o.Items {
+		o.Items[i].Price = o.Items[i].Price.Discount(percent)
+	}
+	o.touch()
+	return nil
+}
+
+func (o *Order) UpdateLoyaltyPoints(points int) {
+	o.Customer.Loyalty.Points = points
+	o.touch()
+}
+
+func (o *Order) itemIndex(sku string) int {
+	for i := range o.Items {
+		if o.Items[i].SKU == sku {
+			return i
+		}
+	}
+	return -1
+}
+
+func (o *Order) touch() { o.UpdatedAt = clock.Now(o.Clock) }
+
+// Total returns the order total as one Money per currency, in the order each
+// currency first appears among the items. It fails with money.ErrOverflow if
+// a line or a sum does not fit in int64 cents.
+func (o *Order) Total() ([]Money, error) {
+	var totals []Money
+	for _, it := range o.Items {
+		line, err := it.Price.Multiply(it.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		if totals, err = money.Accumulate(totals, line); err != nil {
+			return nil, err
+		}
+	}
+	return totals, nil
+}
@@ -0,0 +1,6 @@
+package direct
+
+import "github.com/alechenninger/go-ddd-bench/internal/money"
+
+// Money nested under LineItem. Cents are minor units of Currency.
+type Money = money.Money
@@ -0,0 +1,240 @@
+package direct
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/kv"
+)
+
+// Tables used by PartialRepo.
+const (
+	headerTable = "orders"
+	itemTable   = "order_items"
+)
+
+// PartialRepo stores each order as a header row plus one row per line item,
+// keyed per table. Save diffs the order against the record it was loaded with
+// and writes only the header and item rows that were inserted, updated or
+// deleted, rather than rewriting the whole aggregate.
+//
+// Item rows are keyed by a line ID that stays with the item for as long as it
+// is stored, so removing one item does not rewrite those after it. The store
+// cannot scan a key range, so the header row lists the order's line IDs.
+//
+// The loaded record is kept in the repository, one per order ID, until the
+// ID is loaded or saved again.
+type PartialRepo struct {
+	store   *kv.Store
+	clock   clock.Clock // given to loaded orders
+	tracked tracked
+}
+
+// NewPartialRepo returns a repository whose loaded orders stamp their
+// changes with c, or with the real clock if c is nil.
+func NewPartialRepo(store *kv.Store, c clock.Clock) *PartialRepo {
+	return &PartialRepo{store: store, clock: c}
+}
+
+// itemKey is the order_items primary key: the order ID and the item's line ID.
+func itemKey(orderID string, line int) string { return orderID + "/" + strconv.Itoa(line) }
+
+// headerRow is the orders row PartialRepo stores.
+type headerRow struct {
+	OrderHeader
+	Lines []int // line IDs of the item rows, in item order
+}
+
+// partialState is what PartialRepo and RowRepo remember of an order they
+// loaded or saved: the Order it was loaded into or saved from, the record as
+// stored and the line ID of each item row.
+type partialState struct {
+	order *Order
+	rec   persistenceRecord
+	lines []int
+	next  int // line ID for the next inserted item
+}
+
+// tracked holds a repository's partialState per order ID. An Order has no
+// field to carry it, so each state names the Order it belongs to; any other
+// Order with the same ID, such as one loaded earlier or built from scratch,
+// may not match what is stored and gets no state to diff against.
+type tracked struct {
+	mu     sync.Mutex
+	states map[string]*partialState
+}
+
+// get returns the state o was last loaded with or saved with, or nil if the
+// ID has since been loaded or saved through another Order.
+func (t *tracked) get(o *Order) *partialState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s := t.states[o.ID]; s != nil && s.order == o {
+		return s
+	}
+	return nil
+}
+
+// set makes s the state for its order's ID.
+func (t *tracked) set(s *partialState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.states == nil {
+		t.states = make(map[string]*partialState)
+	}
+	t.states[s.order.ID] = s
+}
+
+// itemWrite is an item row to put, or to delete if row is nil.
+type itemWrite struct {
+	line int
+	row  *OrderItemRow
+}
+
+// diffItems returns the line ID of each of cur's item rows and the writes
+// that bring the stored rows in line with them.
+//
+// With state from the saving repository, each row is paired with the next
+// stored row of the same SKU; stored rows skipped over were removed, and a
+// pair is only written if the row changed. Line IDs must increase in item
+// order, so once a row has no pair, it and all rows after it are inserted
+// under new IDs. Without state, every row is written under lines 0 to n-1 and
+// the other stored lines are deleted.
+func diffItems(prev *partialState, stored []int, cur []OrderItemRow) (lines []int, writes []itemWrite, next int) {
+	lines = make([]int, len(cur))
+	if prev == nil {
+		for i := range cur {
+			lines[i] = i
+			writes = append(writes, itemWrite{line: i, row: &cur[i]})
+		}
+		for _, line := range stored {
+			if line < 0 || line >= len(cur) {
+				writes = append(writes, itemWrite{line: line})
+			}
+		}
+		return lines, writes, len(cur)
+	}
+	old, next := prev.rec.Items, prev.next
+	j := 0
+	for i := range cur {
+		k := j
+		for k < len(old) && old[k].SKU != cur[i].SKU {
+			k++
+		}
+		if k == len(old) {
+			old = old[:j] // the rest are replaced by inserted rows
+			lines[i] = next
+			next++
+			writes = append(writes, itemWrite{line: lines[i], row: &cur[i]})
+			continue
+		}
+		for ; j < k; j++ {
+			writes = append(writes, itemWrite{line: prev.lines[j]})
+		}
+		lines[i] = prev.lines[k]
+		if cur[i] != old[k] {
+			writes = append(writes, itemWrite{line: lines[i], row: &cur[i]})
+		}
+		j = k + 1
+	}
+	for ; j < len(prev.lines); j++ {
+		writes = append(writes, itemWrite{line: prev.lines[j]})
+	}
+	return lines, writes, next
+}
+
+// nextLine returns the line ID after the largest of lines.
+func nextLine(lines []int) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	return slices.Max(lines) + 1
+}
+
+func (r *PartialRepo) Save(o *Order) error {
+	cur := toPersistenceRecord(o)
+	prev := r.tracked.get(o)
+	var stored []int
+	if prev == nil {
+		// Nothing to diff against; find the rows of the stored version.
+		if blob, ok := r.store.Get(headerTable, o.ID); ok {
+			var h headerRow
+			if err := json.Unmarshal(blob, &h); err != nil {
+				return err
+			}
+			stored = h.Lines
+		}
+	}
+	lines, writes, next := diffItems(prev, stored, cur.Items)
+	var b kv.Batch
+	if prev == nil || cur.Header != prev.rec.Header || !slices.Equal(lines, prev.lines) {
+		blob, err := json.Marshal(headerRow{OrderHeader: cur.Header, Lines: lines})
+		if err != nil {
+			return err
+		}
+		b.Put(headerTable, cur.Header.ID, blob)
+	}
+	for _, w := range writes {
+		if w.row == nil {
+			b.Delete(itemTable, itemKey(o.ID, w.line))
+			continue
+		}
+		blob, err := json.Marshal(w.row)
+		if err != nil {
+			return err
+		}
+		b.Put(itemTable, itemKey(o.ID, w.line), blob)
+	}
+	r.store.Commit(&b)
+	r.tracked.set(&partialState{order: o, rec: cur, lines: lines, next: next})
+	return nil
+}
+
+func (r *PartialRepo) FindByID(id string) (*Order, error) {
+	var rec persistenceRecord
+	var h headerRow
+	err := r.store.Read(func(kr kv.Reader) error {
+		blob, ok := kr.Get(headerTable, id)
+		if !ok {
+			return errors.New("not found")
+		}
+		if err := json.Unmarshal(blob, &h); err != nil {
+			return err
+		}
+		rec.Header = h.OrderHeader
+		for _, line := range h.Lines {
+			blob, ok := kr.Get(itemTable, itemKey(id, line))
+			if !ok {
+				return fmt.Errorf("item row %s not found", itemKey(id, line))
+			}
+			var row OrderItemRow
+			if err := json.Unmarshal(blob, &row); err != nil {
+				return err
+			}
+			rec.Items = append(rec.Items, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := fromPersistenceRecord(rec)
+	o.Clock = r.clock
+	r.tracked.set(&partialState{order: o, rec: rec, lines: h.Lines, next: nextLine(h.Lines)})
+	return o, nil
+}
+
+// DataUnsafeForBench returns a copy of the keys to iterate in benchmarks.
+func (r *PartialRepo) DataUnsafeForBench() map[string]struct{} {
+	keys := r.store.Keys(headerTable)
+	ids := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		ids[k] = struct{}{}
+	}
+	return ids
+}
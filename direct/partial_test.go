@@ -0,0 +1,82 @@
+package direct
+
+import (
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/kv"
+	"github.com/alechenninger/go-ddd-bench/internal/table"
+)
+
+// The row repositories keep one loaded state per order ID, so only the copy
+// last loaded or saved is diffed. A copy loaded earlier may be out of date
+// and writes every row.
+func TestRowRepos_DiffOnlyLastLoadedCopy(t *testing.T) {
+	type repo struct {
+		save  func(*Order) error
+		find  func(string) (*Order, error)
+		stats func() (puts, deletes int64)
+	}
+	for _, tc := range []struct {
+		name string
+		new  func() repo
+	}{
+		{"partial", func() repo {
+			store := kv.New()
+			r := NewPartialRepo(store, nil)
+			return repo{r.Save, r.FindByID, store.Stats}
+		}},
+		{"rows", func() repo {
+			db := table.NewDB()
+			r := NewRowRepo(db, nil)
+			return repo{r.Save, r.FindByID, db.Stats}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := tc.new()
+			saveCounting := func(o *Order) (puts, deletes int64) {
+				t.Helper()
+				p0, d0 := r.stats()
+				if err := r.save(o); err != nil {
+					t.Fatal(err)
+				}
+				p1, d1 := r.stats()
+				return p1 - p0, d1 - d0
+			}
+			o := &Order{ID: "o"}
+			for _, sku := range []string{"A", "B", "C"} {
+				if err := o.AddItem(sku, 1, 1000, "USD", ItemFlags{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.save(o); err != nil {
+				t.Fatal(err)
+			}
+			older, err := r.find("o")
+			if err != nil {
+				t.Fatal(err)
+			}
+			newer, err := r.find("o")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := newer.RemoveItem("B"); err != nil {
+				t.Fatal(err)
+			}
+			if puts, deletes := saveCounting(newer); puts != 1 || deletes != 1 {
+				t.Errorf("newer copy wrote %d puts, %d deletes; want 1, 1", puts, deletes)
+			}
+			older.UpdateLoyaltyPoints(7)
+			if puts, deletes := saveCounting(older); puts != 4 || deletes != 0 {
+				t.Errorf("older copy wrote %d puts, %d deletes; want 4, 0", puts, deletes)
+			}
+			got, err := r.find("o")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Items) != 3 || got.Customer.Loyalty.Points != 7 {
+				t.Errorf("reloaded %d items, %d points; want the older copy's 3 items, 7 points", len(got.Items), got.Customer.Loyalty.Points)
+			}
+		})
+	}
+}
@@ -1,20 +1,25 @@
 package direct
 
-import "time"
-
 // OrderHeader mirrors an RDBMS-oriented header row shape.
+//
+// Times are stored as Unix nanoseconds with 0 standing for the zero time, so
+// an order stamped exactly at the Unix epoch reads back as zero. Location and
+// monotonic readings are not stored.
 type OrderHeader struct {
 	ID            string
 	CustomerFirst string
 	CustomerLast  string
 	CustomerEmail string
+	CustomerPhone string
 	LoyaltyTier   string
 	LoyaltyPoints int
-	Street        string
+	Street1       string
+	Street2       string
 	City          string
 	State         string
 	Zip           string
-	BillStreet    string
+	BillStreet1   string
+	BillStreet2   string
 	BillCity      string
 	BillState     string
 	BillZip       string
@@ -45,18 +50,21 @@ func toPersistenceRecord(o *Order) persistenceRecord {
 			CustomerFirst: o.Customer.Name.First,
 			CustomerLast:  o.Customer.Name.Last,
 			CustomerEmail: o.Customer.Email,
+			CustomerPhone: o.Customer.Phone,
 			LoyaltyTier:   o.Customer.Loyalty.Tier,
 			LoyaltyPoints: o.Customer.Loyalty.Points,
-			Street:        o.Shipping.Street,
+			Street1:       o.Shipping.Street1,
+			Street2:       o.Shipping.Street2,
 			City:          o.Shipping.City,
 			State:         o.Shipping.State,
 			Zip:           o.Shipping.Zip,
-			BillStreet:    o.Billing.Street,
+			BillStreet1:   o.Billing.Street1,
+			BillStreet2:   o.Billing.Street2,
 			BillCity:      o.Billing.City,
 			BillState:     o.Billing.State,
 			BillZip:       o.Billing.Zip,
-			CreatedAt:     o.CreatedAt.UnixNano(),
-			UpdatedAt:     o.UpdatedAt.UnixNano(),
+			CreatedAt:     timeToUnix(o.CreatedAt),
+			UpdatedAt:     timeToUnix(o.UpdatedAt),
 		},
 	}
 	rec.Items = make([]OrderItemRow, len(o.Items))
@@ -76,12 +84,13 @@ func fromPersistenceRecord(rec persistenceRecord) *Order {
 		Customer: Customer{
 			Name:    Name{First: rec.Header.CustomerFirst, Last: rec.Header.CustomerLast},
 			Email:   rec.Header.CustomerEmail,
+			Phone:   rec.Header.CustomerPhone,
 			Loyalty: Loyalty{Tier: rec.Header.LoyaltyTier, Points: rec.Header.LoyaltyPoints},
 		},
-		Shipping:  Address{Street: rec.Header.Street, City: rec.Header.City, State: rec.Header.State, Zip: rec.Header.Zip},
-		Billing:   Address{Street: rec.Header.BillStreet, City: rec.Header.BillCity, State: rec.Header.BillState, Zip: rec.Header.BillZip},
+		Shipping:  Address{Street1: rec.Header.Street1, Street2: rec.Header.Street2, City: rec.Header.City, State: rec.Header.State, Zip: rec.Header.Zip},
+		Billing:   Address{Street1: rec.Header.BillStreet1, Street2: rec.Header.BillStreet2, City: rec.Header.BillCity, State: rec.Header.BillState, Zip: rec.Header.BillZip},
 		Items:     items,
-		CreatedAt: time.Unix(0, rec.Header.CreatedAt),
-		UpdatedAt: time.Unix(0, rec.Header.UpdatedAt),
+		CreatedAt: unixToTime(rec.Header.CreatedAt),
+		UpdatedAt: unixToTime(rec.Header.UpdatedAt),
 	}
 }
@@ -0,0 +1,12 @@
+package direct
+
+import "testing"
+
+// A missing CreatedAt in the persistence record comes back as the zero time,
+// as in encap, rather than the Unix epoch.
+func TestFromPersistenceRecord_ZeroCreatedAtIsZeroTime(t *testing.T) {
+	o := fromPersistenceRecord(persistenceRecord{})
+	if !o.CreatedAt.IsZero() {
+		t.Fatalf("CreatedAt = %v, want the zero time", o.CreatedAt)
+	}
+}
@@ -2,49 +2,135 @@ package direct
 
 import (
 	"encoding/json"
-	"errors"
-	"sync"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/bufpool"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/schema"
+)
+
+// versions migrates stored orders to the current Order shape:
+//
+//	v2 splits Address.Street into Street1 and Street2
+//	v3 adds Customer.Phone
+var versions = schema.NewChain(
+	schema.Steps(
+		schema.SplitLines("Shipping.Street", "Street1", "Street2"),
+		schema.SplitLines("Billing.Street", "Street1", "Street2"),
+	),
+	schema.Default("Customer.Phone", ""),
 )
 
 // DirectRepo simulates a repository that (de)serializes the model directly.
+// Times keep their zone offset, which JSON writes to the minute: a time at an
+// offset with seconds, such as a historic local mean time, loads back moved
+// by those seconds.
 type DirectRepo struct {
-	mu   sync.RWMutex
-	data map[string][]byte // stores JSON blobs
+	store blobstore.Store // holds JSON blobs
+	stale *schema.Stale   // nil unless WithLazyRewrite
+	clock clock.Clock     // given to loaded orders
+
+	pooled bool // encode through pooled buffers; see WithPooling
 }
 
-func NewDirectRepo() *DirectRepo { return &DirectRepo{data: make(map[string][]byte)} }
+// Option configures a DirectRepo.
+type Option func(*DirectRepo)
+
+// WithStore keeps blobs in s instead of the default in-memory store.
+func WithStore(s blobstore.Store) Option { return func(r *DirectRepo) { r.store = s } }
+
+// WithLazyRewrite makes each Save also rewrite, at the current schema
+// version, up to schema.FlushBatch of the orders FindByID had to upcast,
+// remembering at most schema.MaxPending of them in between. A failed rewrite
+// does not fail the Save that triggered it. Without this option, an old blob
+// is only migrated when its own order is saved.
+func WithLazyRewrite() Option { return func(r *DirectRepo) { r.stale = schema.NewStale() } }
+
+// WithClock makes the orders FindByID returns stamp their changes with c
+// instead of the real clock.
+func WithClock(c clock.Clock) Option { return func(r *DirectRepo) { r.clock = c } }
+
+// WithPooling makes Save encode through a pooled buffer and JSON encoder
+// instead of a fresh json.Marshal buffer per call. It is offered for parity
+// with encap, where it also pools the mapping values, but saves nothing
+// here: json.Marshal already encodes into a pooled buffer and returns a
+// copy, just as bufpool.Marshal does.
+func WithPooling() Option { return func(r *DirectRepo) { r.pooled = true } }
+
+func NewDirectRepo(opts ...Option) *DirectRepo {
+	r := &DirectRepo{store: blobstore.NewMemory()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
 
 func (r *DirectRepo) Save(o *Order) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	blob, err := json.Marshal(o)
+	marshal := json.Marshal
+	if r.pooled {
+		marshal = bufpool.Marshal
+	}
+	blob, err := marshal(schema.Envelope[*Order]{V: versions.Current(), Data: o})
 	if err != nil {
 		return err
 	}
-	r.data[o.ID] = blob
+	if err := r.store.Put(o.ID, blob); err != nil {
+		return err
+	}
+	if r.stale != nil {
+		r.stale.Forget(o.ID)
+		_ = r.stale.Flush(r.store, schema.FlushBatch) // failed rewrites are retried on the next Save
+	}
 	return nil
 }
 
 func (r *DirectRepo) FindByID(id string) (*Order, error) {
-	r.mu.RLock()
-	blob, ok := r.data[id]
-	r.mu.RUnlock()
-	if !ok {
-		return nil, errors.New("not found")
-	}
-	var o Order
-	if err := json.Unmarshal(blob, &o); err != nil {
+	o := new(Order)
+	if err := r.LoadInto(id, o); err != nil {
 		return nil, err
 	}
-	return &o, nil
+	return o, nil
+}
+
+// LoadInto decodes the order with the given ID into dst, replacing all of
+// its state. dst's item array is reused when it has room, so loading into the
+// same order again and again allocates no new aggregate. dst is unchanged if
+// the order cannot be read, and unspecified if it cannot be decoded.
+func (r *DirectRepo) LoadInto(id string, dst *Order) error {
+	blob, err := r.store.Get(id)
+	if err != nil {
+		return err
+	}
+	data, from, err := versions.Upgrade(blob)
+	if err != nil {
+		return err
+	}
+	items := dst.Items[:cap(dst.Items)]
+	clear(items) // json fills elements in place, keeping fields the blob lacks
+	*dst = Order{Items: items[:0], Clock: r.clock}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return err
+	}
+	if r.stale != nil && from != versions.Current() {
+		r.stale.Add(id, blob, versions.Wrap(nil, data))
+	}
+	return nil
+}
+
+// Delete removes the order with the given ID. Deleting an order that does
+// not exist is not an error.
+func (r *DirectRepo) Delete(id string) error {
+	if r.stale != nil {
+		r.stale.Forget(id)
+	}
+	return r.store.Delete(id)
 }
 
 // DataUnsafeForBench returns a copy of the keys to iterate in benchmarks.
 func (r *DirectRepo) DataUnsafeForBench() map[string]struct{} {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	ids := make(map[string]struct{}, len(r.data))
-	for k := range r.data {
+	keys := r.store.Keys()
+	ids := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
 		ids[k] = struct{}{}
 	}
 	return ids
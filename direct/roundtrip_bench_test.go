@@ -1,37 +1,39 @@
 package direct
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
 	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
 )
 
 var sinkDirect any
 
-func randID() string {
-	var b [16]byte
-	_, _ = rand.Read(b[:])
-	return hex.EncodeToString(b[:])
-}
-
-func seedDirectOrders(n int) []*Order {
+func seedDirectOrders(c clock.Clock, n int) []*Order {
 	orders := make([]*Order, 0, n)
-	for i := 0; i < n; i++ {
+	for _, w := range workload.New(workload.Default()).Orders(n) {
 		o := &Order{
-			ID:        randID(),
-			Customer:  Customer{Name: Name{First: "Ada", Last: "Lovelace"}, Email: "ada@example.com", Loyalty: Loyalty{Tier: "gold", Points: 100}},
-			Shipping:  Address{Street: "1 Main", City: "Town", State: "CA", Zip: "94000"},
-			Billing:   Address{Street: "2 Main", City: "Town", State: "CA", Zip: "94000"},
-			Items:     nil,
-			CreatedAt: clock.Now(),
-			UpdatedAt: clock.Now(),
+			ID: w.ID,
+			Customer: Customer{
+				Name:    Name{First: w.Customer.First, Last: w.Customer.Last},
+				Email:   w.Customer.Email,
+				Phone:   w.Customer.Phone,
+				Loyalty: Loyalty{Tier: w.Customer.LoyaltyTier, Points: w.Customer.LoyaltyPoints},
+			},
+			Shipping:  Address(w.Shipping),
+			Billing:   Address(w.Billing),
+			CreatedAt: clock.Now(c),
+			UpdatedAt: clock.Now(c),
+			Clock:     c,
+		}
+		for _, it := range w.Items {
+			if err := o.AddItem(it.SKU, it.Quantity, it.PriceCents, it.Currency, ItemFlags{Backorder: it.Backorder, Digital: it.Digital}); err != nil {
+				panic(err)
+			}
 		}
-		o.AddItem("A", 1, 1234, "USD", ItemFlags{})
-		o.AddItem("B", 2, 555, "USD", ItemFlags{Backorder: true})
 		orders = append(orders, o)
 	}
 	return orders
@@ -44,11 +46,11 @@ func roundTripDirect(o *Order) *Order {
 }
 
 func BenchmarkDirect_RoundTrip_NoJSON(b *testing.B) {
-	restore := clock.UseMonotonicFake(time.Unix(0, 0), time.Nanosecond)
-	defer restore()
-	orders := seedDirectOrders(1024)
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	orders := seedDirectOrders(clk, 1024)
 	b.ReportAllocs()
 	b.ResetTimer()
+	benchgc.Track(b)
 	for i := 0; i < b.N; i++ {
 		res := roundTripDirect(orders[i%len(orders)])
 		sinkDirect = res
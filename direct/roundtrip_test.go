@@ -0,0 +1,173 @@
+package direct
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/ordergen"
+)
+
+const propertyRuns = 500
+
+func fromGen(g ordergen.Order) *Order {
+	o := &Order{
+		ID: g.ID,
+		Customer: Customer{
+			Name:    Name{First: g.First, Last: g.Last},
+			Email:   g.Email,
+			Phone:   g.Phone,
+			Loyalty: Loyalty{Tier: g.LoyaltyTier, Points: g.LoyaltyPoints},
+		},
+		Shipping:  Address(g.Shipping),
+		Billing:   Address(g.Billing),
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+	}
+	for _, it := range g.Items {
+		o.Items = append(o.Items, LineItem{SKU: it.SKU, Quantity: it.Quantity, Price: Money{Cents: it.PriceCents, Currency: it.Currency}, Flags: ItemFlags{Backorder: it.Backorder, Digital: it.Digital}})
+	}
+	return o
+}
+
+func toGen(o *Order) ordergen.Order {
+	g := ordergen.Order{
+		ID:            o.ID,
+		First:         o.Customer.Name.First,
+		Last:          o.Customer.Name.Last,
+		Email:         o.Customer.Email,
+		Phone:         o.Customer.Phone,
+		LoyaltyTier:   o.Customer.Loyalty.Tier,
+		LoyaltyPoints: o.Customer.Loyalty.Points,
+		Shipping:      ordergen.Address(o.Shipping),
+		Billing:       ordergen.Address(o.Billing),
+		CreatedAt:     o.CreatedAt,
+		UpdatedAt:     o.UpdatedAt,
+	}
+	for _, it := range o.Items {
+		g.Items = append(g.Items, ordergen.Item{SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.Price.Cents, Currency: it.Price.Currency, Backorder: it.Flags.Backorder, Digital: it.Flags.Digital})
+	}
+	return g
+}
+
+func TestPersistenceRecord_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 34))
+	for i := 0; i < propertyRuns; i++ {
+		g := ordergen.New(r)
+		got := toGen(fromPersistenceRecord(toPersistenceRecord(fromGen(g))))
+		if d := ordergen.Diff(g, got); d != "" {
+			t.Fatalf("order %d: %s", i, d)
+		}
+	}
+}
+
+func TestDirectRepo_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(2, 34))
+	repo := NewDirectRepo()
+	for i := 0; i < propertyRuns; i++ {
+		g := ordergen.New(r)
+		if err := repo.Save(fromGen(g)); err != nil {
+			t.Fatalf("order %d: Save: %v", i, err)
+		}
+		o, err := repo.FindByID(g.ID)
+		if err != nil {
+			t.Fatalf("order %d: FindByID: %v", i, err)
+		}
+		if d := ordergen.Diff(g, toGen(o)); d != "" {
+			t.Fatalf("order %d: %s", i, d)
+		}
+	}
+}
+
+// Saved times come back at their zone offset, truncated to the minute, and
+// without a monotonic reading.
+func TestDirectRepo_Times(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	now := time.Now()
+	_, nowOffset := now.Zone()
+	repo := NewDirectRepo()
+	for _, tt := range []struct {
+		name   string
+		t      time.Time
+		offset int // seconds east of UTC on load
+		shift  time.Duration
+	}{
+		{"UTC", at, 0, 0},
+		{"fixed offset", at.In(time.FixedZone("X", -(9*3600 + 30*60))), -(9*3600 + 30*60), 0},
+		{"monotonic", now, nowOffset, 0},
+		{"offset with seconds", at.In(time.FixedZone("LMT", 5*3600+53*60+28)), 5*3600 + 53*60, 28 * time.Second},
+	} {
+		if err := repo.Save(&Order{ID: tt.name, CreatedAt: tt.t}); err != nil {
+			t.Fatal(err)
+		}
+		o, err := repo.FindByID(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := o.CreatedAt
+		if got != got.Round(0) {
+			t.Errorf("%s: %v kept a monotonic reading", tt.name, got)
+		}
+		if _, off := got.Zone(); off != tt.offset {
+			t.Errorf("%s: offset %ds, want %ds", tt.name, off, tt.offset)
+		}
+		if d := got.Sub(tt.t); d != tt.shift {
+			t.Errorf("%s: loaded %v, %v from saved %v; want %v", tt.name, got, d, tt.t, tt.shift)
+		}
+	}
+}
+
+// Loading order after order into one destination gives each order as it
+// was saved, whatever the destination held before, and reuses its item
+// array when the next order fits.
+func TestDirectRepo_LoadInto(t *testing.T) {
+	r := rand.New(rand.NewPCG(4, 34))
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := NewDirectRepo(WithClock(clk))
+	gens := make([]ordergen.Order, 100)
+	for i := range gens {
+		gens[i] = ordergen.New(r)
+		gens[i].ID = "order-" + strconv.Itoa(i) // generated IDs may repeat
+		if err := repo.Save(fromGen(gens[i])); err != nil {
+			t.Fatalf("order %d: Save: %v", i, err)
+		}
+	}
+	var dst Order
+	reused := 0
+	for i, g := range gens {
+		var prev *LineItem
+		if len(g.Items) > 0 && cap(dst.Items) >= len(g.Items) {
+			prev = &dst.Items[:1][0]
+		}
+		if err := repo.LoadInto(g.ID, &dst); err != nil {
+			t.Fatalf("order %d: LoadInto: %v", i, err)
+		}
+		if d := ordergen.Diff(g, toGen(&dst)); d != "" {
+			t.Fatalf("order %d: %s", i, d)
+		}
+		if dst.Clock != clk {
+			t.Fatalf("order %d: clock not set", i)
+		}
+		if prev != nil {
+			if &dst.Items[0] != prev {
+				t.Fatalf("order %d: items reallocated though %d fit in %d", i, len(g.Items), cap(dst.Items))
+			}
+			reused++
+		}
+	}
+	if reused == 0 {
+		t.Fatal("no load reused the item array")
+	}
+
+	before := toGen(&dst)
+	if err := repo.LoadInto("missing", &dst); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Fatalf("LoadInto(missing) = %v, want ErrNotFound", err)
+	}
+	if d := ordergen.Diff(before, toGen(&dst)); d != "" {
+		t.Fatalf("failed load changed dst: %s", d)
+	}
+}
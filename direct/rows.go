@@ -0,0 +1,126 @@
+package direct
+
+import (
+	"cmp"
+	"errors"
+	"strings"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/table"
+)
+
+// itemPK is the order_items primary key.
+type itemPK struct {
+	orderID string
+	line    int // see PartialRepo
+}
+
+func compareItemPK(a, b itemPK) int {
+	if c := strings.Compare(a.orderID, b.orderID); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.line, b.line)
+}
+
+// RowRepo persists orders as typed rows in a table.DB: one orders row plus one
+// order_items row per line item, found through a secondary index on
+// OrderItemRow.OrderID. Like PartialRepo it diffs against the loaded record
+// and writes only changed rows, keyed by line ID, all in a single transaction.
+type RowRepo struct {
+	db      *table.DB
+	headers *table.Table[string, OrderHeader]
+	items   *table.Table[itemPK, OrderItemRow]
+	byOrder *table.Index[itemPK, OrderItemRow]
+	clock   clock.Clock // given to loaded orders
+	tracked tracked
+}
+
+// NewRowRepo returns a repository whose loaded orders stamp their changes
+// with c, or with the real clock if c is nil.
+func NewRowRepo(db *table.DB, c clock.Clock) *RowRepo {
+	r := &RowRepo{
+		db:      db,
+		clock:   c,
+		headers: table.NewTable[string, OrderHeader](db, headerTable, strings.Compare),
+		items:   table.NewTable[itemPK, OrderItemRow](db, itemTable, compareItemPK),
+	}
+	r.byOrder = r.items.AddIndex("order_items_order_id", func(row *OrderItemRow) string { return row.OrderID })
+	return r
+}
+
+func (r *RowRepo) Save(o *Order) error {
+	cur := toPersistenceRecord(o)
+	prev := r.tracked.get(o)
+	var (
+		lines []int
+		next  int
+	)
+	err := r.db.Update(func(tx *table.Tx) error {
+		var stored []int
+		if prev == nil {
+			stored = r.lines(tx, o.ID)
+		}
+		var writes []itemWrite
+		lines, writes, next = diffItems(prev, stored, cur.Items)
+		if prev == nil || cur.Header != prev.rec.Header {
+			r.headers.Put(tx, cur.Header.ID, cur.Header)
+		}
+		for _, w := range writes {
+			if w.row == nil {
+				r.items.Delete(tx, itemPK{orderID: o.ID, line: w.line})
+				continue
+			}
+			r.items.Put(tx, itemPK{orderID: o.ID, line: w.line}, *w.row)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.tracked.set(&partialState{order: o, rec: cur, lines: lines, next: next})
+	return nil
+}
+
+// lines returns the line IDs of the order's stored item rows, in order.
+func (r *RowRepo) lines(tx *table.Tx, orderID string) []int {
+	keys := r.byOrder.AppendKeys(tx, nil, orderID)
+	lines := make([]int, len(keys))
+	for i, k := range keys {
+		lines[i] = k.line
+	}
+	return lines
+}
+
+func (r *RowRepo) FindByID(id string) (*Order, error) {
+	var rec persistenceRecord
+	var lines []int
+	err := r.db.View(func(tx *table.Tx) error {
+		h, ok := r.headers.Get(tx, id)
+		if !ok {
+			return errors.New("not found")
+		}
+		rec.Header = h
+		rec.Items = r.byOrder.AppendRows(tx, nil, id)
+		lines = r.lines(tx, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := fromPersistenceRecord(rec)
+	o.Clock = r.clock
+	r.tracked.set(&partialState{order: o, rec: rec, lines: lines, next: nextLine(lines)})
+	return o, nil
+}
+
+// DataUnsafeForBench returns a copy of the keys to iterate in benchmarks.
+func (r *RowRepo) DataUnsafeForBench() map[string]struct{} {
+	ids := make(map[string]struct{})
+	_ = r.db.View(func(tx *table.Tx) error {
+		for _, k := range r.headers.Keys(tx) {
+			ids[k] = struct{}{}
+		}
+		return nil
+	})
+	return ids
+}
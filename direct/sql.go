@@ -0,0 +1,113 @@
+package direct
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/ordersql"
+)
+
+// SQLRepo persists orders through database/sql using the ordersql schema.
+// Because the model's fields are public, rows scan straight into the domain
+// struct; only the timestamps pass through an intermediate.
+type SQLRepo struct {
+	db    *sql.DB
+	clock clock.Clock // given to loaded orders
+}
+
+// NewSQLRepo returns a repository whose loaded orders stamp their changes
+// with c, or with the real clock if c is nil.
+func NewSQLRepo(db *sql.DB, c clock.Clock) *SQLRepo { return &SQLRepo{db: db, clock: c} }
+
+// Save upserts the order row and rewrites its item rows in one transaction.
+func (r *SQLRepo) Save(o *Order) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	c, s, bl := &o.Customer, &o.Shipping, &o.Billing
+	res, err := tx.Exec(ordersql.UpdateOrder,
+		c.Name.First, c.Name.Last, c.Email, c.Phone, c.Loyalty.Tier, c.Loyalty.Points,
+		s.Street1, s.Street2, s.City, s.State, s.Zip, bl.Street1, bl.Street2, bl.City, bl.State, bl.Zip,
+		timeToUnix(o.CreatedAt), timeToUnix(o.UpdatedAt), o.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := tx.Exec(ordersql.InsertOrder, o.ID,
+			c.Name.First, c.Name.Last, c.Email, c.Phone, c.Loyalty.Tier, c.Loyalty.Points,
+			s.Street1, s.Street2, s.City, s.State, s.Zip, bl.Street1, bl.Street2, bl.City, bl.State, bl.Zip,
+			timeToUnix(o.CreatedAt), timeToUnix(o.UpdatedAt)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ordersql.DeleteItems, o.ID); err != nil {
+		return err
+	}
+	for i, it := range o.Items {
+		if _, err := tx.Exec(ordersql.InsertItem, o.ID, i, it.SKU, it.Quantity, it.Price.Cents, it.Price.Currency, it.Flags.Backorder, it.Flags.Digital); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FindByID reads the order row and its item rows in one read-only
+// transaction, so they always come from the same committed Save.
+func (r *SQLRepo) FindByID(id string) (*Order, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // nothing to commit
+	o := Order{Clock: r.clock}
+	var createdAt, updatedAt int64
+	c, s, bl := &o.Customer, &o.Shipping, &o.Billing
+	err = tx.QueryRow(ordersql.SelectOrder, id).Scan(&o.ID,
+		&c.Name.First, &c.Name.Last, &c.Email, &c.Phone, &c.Loyalty.Tier, &c.Loyalty.Points,
+		&s.Street1, &s.Street2, &s.City, &s.State, &s.Zip, &bl.Street1, &bl.Street2, &bl.City, &bl.State, &bl.Zip,
+		&createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	o.CreatedAt = unixToTime(createdAt)
+	o.UpdatedAt = unixToTime(updatedAt)
+	rows, err := tx.Query(ordersql.SelectItems, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it LineItem
+		if err := rows.Scan(&it.SKU, &it.Quantity, &it.Price.Cents, &it.Price.Currency, &it.Flags.Backorder, &it.Flags.Digital); err != nil {
+			return nil, err
+		}
+		o.Items = append(o.Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// DataUnsafeForBench returns a copy of the keys to iterate in benchmarks.
+func (r *SQLRepo) DataUnsafeForBench() map[string]struct{} {
+	ids, _ := ordersql.SelectIDs(r.db)
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,82 @@
+package direct
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/schema"
+	"github.com/alechenninger/go-ddd-bench/internal/stagebench"
+)
+
+// BenchmarkDirect_RMW_Stages runs DirectRepo.FindByID and DirectRepo.Save
+// with a small change in between, one step per stage, and reports each
+// stage's share of the cycle. The model is encoded as is, so there are no
+// mapping stages. A check before timing makes sure the pipeline writes the
+// same blob as Save.
+func BenchmarkDirect_RMW_Stages(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := NewDirectRepo(WithClock(clk))
+	var ids []string
+	for _, o := range seedDirectOrders(clk, 1000) {
+		if err := repo.Save(o); err != nil {
+			b.Fatal(err)
+		}
+		ids = append(ids, o.ID)
+	}
+
+	var (
+		id   string
+		blob []byte
+		data []byte
+		o    *Order
+	)
+	stages := []stagebench.Stage{
+		{Name: "get", Run: func(i int) (err error) {
+			id = ids[i%len(ids)]
+			blob, err = repo.store.Get(id)
+			return err
+		}},
+		{Name: "upgrade", Run: func(int) (err error) {
+			data, _, err = versions.Upgrade(blob)
+			return err
+		}},
+		{Name: "decode", Run: func(int) error {
+			o = &Order{Clock: repo.clock}
+			return json.Unmarshal(data, o)
+		}},
+		{Name: "modify", Run: func(i int) error {
+			if err := o.RemoveItem("STAGE-TOGGLE"); errors.Is(err, ErrItemNotFound) {
+				if err := o.AddItem("STAGE-TOGGLE", 1, 99, "USD", ItemFlags{Digital: true}); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+			o.UpdateLoyaltyPoints(i)
+			return nil
+		}},
+		{Name: "encode", Run: func(int) (err error) {
+			blob, err = json.Marshal(schema.Envelope[*Order]{V: versions.Current(), Data: o})
+			return err
+		}},
+		{Name: "put", Run: func(int) error { return repo.store.Put(id, blob) }},
+	}
+
+	for _, st := range stages {
+		if err := st.Run(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := repo.Save(o); err != nil {
+		b.Fatal(err)
+	}
+	if saved, _ := repo.store.Get(id); !bytes.Equal(saved, blob) {
+		b.Fatalf("staged pipeline wrote\n%s\nbut Save wrote\n%s", blob, saved)
+	}
+	stagebench.Run(b, stages...)
+	sinkDirect = o
+}
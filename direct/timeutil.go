@@ -0,0 +1,21 @@
+package direct
+
+import "time"
+
+// timeToUnix and unixToTime convert between times and the int64 nanoseconds
+// used in persistence rows, keeping the zero time as 0 so it survives the
+// round trip. The price is that the Unix epoch itself maps to 0 too and
+// comes back as the zero time.
+func timeToUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func unixToTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
@@ -1,7 +1,20 @@
 package directflat
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/money"
+)
+
+var (
+	// ErrItemNotFound is returned when no item row has the requested SKU.
+	ErrItemNotFound = errors.New("item not found")
+	// ErrInvalidQuantity is returned for row quantities below one.
+	ErrInvalidQuantity = errors.New("invalid quantity")
+	// ErrInvalidDiscount is returned for discounts outside 0-100 percent.
+	ErrInvalidDiscount = errors.New("invalid discount")
 )
 
 type OrderHeader struct {
@@ -9,13 +22,16 @@ type OrderHeader struct {
 	CustomerFirst string
 	CustomerLast  string
 	CustomerEmail string
+	CustomerPhone string
 	LoyaltyTier   string
 	LoyaltyPoints int
-	Street        string
+	Street1       string
+	Street2       string
 	City          string
 	State         string
 	Zip           string
-	BillStreet    string
+	BillStreet1   string
+	BillStreet2   string
 	BillCity      string
 	BillState     string
 	BillZip       string
@@ -36,17 +52,111 @@ type OrderItemRow struct {
 type OrderRecord struct {
 	Header OrderHeader
 	Items  []OrderItemRow
+
+	// Clock stamps UpdatedAt; nil is the real clock.
+	Clock clock.Clock `json:"-"`
 }
 
-func NewOrderRecord(id, first, last, email string, loyaltyTier string, loyaltyPts int) *OrderRecord {
-	now := clock.Now().UnixNano()
+func NewOrderRecord(c clock.Clock, id, first, last, email string, loyaltyTier string, loyaltyPts int) *OrderRecord {
+	now := clock.Now(c).UnixNano()
 	return &OrderRecord{
-		Header: OrderHeader{ID: id, CustomerFirst: first, CustomerLast: last, CustomerEmail: email, LoyaltyTier: loyaltyTier, LoyaltyPoints: loyaltyPts, Street: "1 Main", City: "Town", State: "CA", Zip: "94000", BillStreet: "2 Main", BillCity: "Town", BillState: "CA", BillZip: "94000", CreatedAt: now, UpdatedAt: now},
+		Header: OrderHeader{ID: id, CustomerFirst: first, CustomerLast: last, CustomerEmail: email, LoyaltyTier: loyaltyTier, LoyaltyPoints: loyaltyPts, Street1: "1 Main", City: "Town", State: "CA", Zip: "94000", BillStreet1: "2 Main", BillCity: "Town", BillState: "CA", BillZip: "94000", CreatedAt: now, UpdatedAt: now},
 		Items:  nil,
+		Clock:  c,
 	}
 }
 
-func (r *OrderRecord) AddItem(sku string, qty int, priceCents int64, currency string, backorder, digital bool) {
+// AddItem appends an item row. Like ChangeQuantity, it rejects quantities
+// below one.
+func (r *OrderRecord) AddItem(sku string, qty int, priceCents int64, currency string, backorder, digital bool) error {
+	if qty < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, qty)
+	}
 	r.Items = append(r.Items, OrderItemRow{OrderID: r.Header.ID, SKU: sku, Quantity: qty, PriceCents: priceCents, Currency: currency, Backorder: backorder, Digital: digital})
-	r.Header.UpdatedAt = clock.Now().UnixNano()
+	r.touch()
+	return nil
+}
+
+// RemoveItem removes the first item row with the given SKU.
+func (r *OrderRecord) RemoveItem(sku string) error {
+	i := r.itemIndex(sku)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrItemNotFound, sku)
+	}
+	r.Items = append(r.Items[:i], r.Items[i+1:]...)
+	r.touch()
+	return nil
+}
+
+// ChangeQuantity sets the quantity of the first item row with the given SKU.
+func (r *OrderRecord) ChangeQuantity(sku string, qty int) error {
+	if qty < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, qty)
+	}
+	i := r.itemIndex(sku)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrItemNotFound, sku)
+	}
+	r.Items[i].Quantity = qty
+	r.touch()
+	return nil
+}
+
+// ApplyDiscount reduces every row's unit price by percent, rounding the
+// discount down to whole cents.
+func (r *OrderRecord) ApplyDiscount(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("%w: %d%%", ErrInvalidDiscount, percent)
+	}
+	for i := range r.Items {
+		r.Items[i].PriceCents = Money{Cents: r.Items[i].PriceCents}.Discount(percent).Cents
+	}
+	r.touch()
+	return nil
+}
+
+func (r *OrderRecord) UpdateLoyaltyPoints(points int) {
+	r.Header.LoyaltyPoints = points
+	r.touch()
+}
+
+func (r *OrderRecord) UpdateShipping(street1, street2, city, state, zip string) {
+	r.Header.Street1, r.Header.Street2, r.Header.City, r.Header.State, r.Header.Zip = street1, street2, city, state, zip
+	r.touch()
+}
+
+func (r *OrderRecord) UpdateBilling(street1, street2, city, state, zip string) {
+	r.Header.BillStreet1, r.Header.BillStreet2, r.Header.BillCity, r.Header.BillState, r.Header.BillZip = street1, street2, city, state, zip
+	r.touch()
+}
+
+func (r *OrderRecord) itemIndex(sku string) int {
+	for i := range r.Items {
+		if r.Items[i].SKU == sku {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *OrderRecord) touch() { r.Header.UpdatedAt = clock.Now(r.Clock).UnixNano() }
+
+// Price returns the row's unit price.
+func (it OrderItemRow) Price() Money { return Money{Cents: it.PriceCents, Currency: it.Currency} }
+
+// Total returns the order total as one Money per currency, in the order each
+// currency first appears among the items. It fails with money.ErrOverflow if
+// a line or a sum does not fit in int64 cents.
+func (r *OrderRecord) Total() ([]Money, error) {
+	var totals []Money
+	for _, it := range r.Items {
+		line, err := it.Price().Multiply(it.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		if totals, err = money.Accumulate(totals, line); err != nil {
+			return nil, err
+		}
+	}
+	return totals, nil
 }
@@ -0,0 +1,7 @@
+package directflat
+
+import "github.com/alechenninger/go-ddd-bench/internal/money"
+
+// Money is an amount in minor units of Currency. Item rows store it flattened
+// as PriceCents and Currency.
+type Money = money.Money
@@ -2,47 +2,130 @@ package directflat
 
 import (
 	"encoding/json"
-	"errors"
-	"sync"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/bufpool"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/schema"
+)
+
+// versions migrates stored records to the current OrderRecord shape:
+//
+//	v2 splits Street and BillStreet into two lines each
+//	v3 adds CustomerPhone
+var versions = schema.NewChain(
+	schema.Steps(
+		schema.SplitLines("Header.Street", "Street1", "Street2"),
+		schema.SplitLines("Header.BillStreet", "BillStreet1", "BillStreet2"),
+	),
+	schema.Default("Header.CustomerPhone", ""),
 )
 
 type Repo struct {
-	mu   sync.RWMutex
-	data map[string][]byte
+	store blobstore.Store
+	stale *schema.Stale // nil unless WithLazyRewrite
+	clock clock.Clock   // given to loaded orders
+
+	pooled bool // encode through pooled buffers; see WithPooling
 }
 
-func NewRepo() *Repo { return &Repo{data: make(map[string][]byte)} }
+// Option configures a Repo.
+type Option func(*Repo)
+
+// WithStore keeps blobs in s instead of the default in-memory store.
+func WithStore(s blobstore.Store) Option { return func(r *Repo) { r.store = s } }
+
+// WithLazyRewrite makes each Save also rewrite, at the current schema
+// version, up to schema.FlushBatch of the orders FindByID had to upcast,
+// remembering at most schema.MaxPending of them in between. A failed rewrite
+// does not fail the Save that triggered it. Without this option, an old blob
+// is only migrated when its own order is saved.
+func WithLazyRewrite() Option { return func(r *Repo) { r.stale = schema.NewStale() } }
+
+// WithClock makes the orders FindByID returns stamp their changes with c
+// instead of the real clock.
+func WithClock(c clock.Clock) Option { return func(r *Repo) { r.clock = c } }
+
+// WithPooling makes Save encode through a pooled buffer and JSON encoder
+// instead of a fresh json.Marshal buffer per call. It is offered for parity
+// with encap, where it also pools the mapping values, but saves nothing
+// here: json.Marshal already encodes into a pooled buffer and returns a
+// copy, just as bufpool.Marshal does.
+func WithPooling() Option { return func(r *Repo) { r.pooled = true } }
+
+func NewRepo(opts ...Option) *Repo {
+	r := &Repo{store: blobstore.NewMemory()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
 
 func (r *Repo) Save(rec *OrderRecord) error {
-	blob, err := json.Marshal(rec)
+	marshal := json.Marshal
+	if r.pooled {
+		marshal = bufpool.Marshal
+	}
+	blob, err := marshal(schema.Envelope[*OrderRecord]{V: versions.Current(), Data: rec})
 	if err != nil {
 		return err
 	}
-	r.mu.Lock()
-	r.data[rec.Header.ID] = blob
-	r.mu.Unlock()
+	if err := r.store.Put(rec.Header.ID, blob); err != nil {
+		return err
+	}
+	if r.stale != nil {
+		r.stale.Forget(rec.Header.ID)
+		_ = r.stale.Flush(r.store, schema.FlushBatch) // failed rewrites are retried on the next Save
+	}
 	return nil
 }
 
 func (r *Repo) FindByID(id string) (*OrderRecord, error) {
-	r.mu.RLock()
-	blob, ok := r.data[id]
-	r.mu.RUnlock()
-	if !ok {
-		return nil, errors.New("not found")
-	}
-	var rec OrderRecord
-	if err := json.Unmarshal(blob, &rec); err != nil {
+	rec := new(OrderRecord)
+	if err := r.LoadInto(id, rec); err != nil {
 		return nil, err
 	}
-	return &rec, nil
+	return rec, nil
+}
+
+// LoadInto decodes the order with the given ID into dst, replacing all of
+// its state. dst's item array is reused when it has room, so loading into the
+// same record again and again allocates no new aggregate. dst is unchanged if
+// the order cannot be read, and unspecified if it cannot be decoded.
+func (r *Repo) LoadInto(id string, dst *OrderRecord) error {
+	blob, err := r.store.Get(id)
+	if err != nil {
+		return err
+	}
+	data, from, err := versions.Upgrade(blob)
+	if err != nil {
+		return err
+	}
+	items := dst.Items[:cap(dst.Items)]
+	clear(items) // json fills elements in place, keeping fields the blob lacks
+	*dst = OrderRecord{Items: items[:0], Clock: r.clock}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return err
+	}
+	if r.stale != nil && from != versions.Current() {
+		r.stale.Add(id, blob, versions.Wrap(nil, data))
+	}
+	return nil
+}
+
+// Delete removes the order with the given ID. Deleting an order that does
+// not exist is not an error.
+func (r *Repo) Delete(id string) error {
+	if r.stale != nil {
+		r.stale.Forget(id)
+	}
+	return r.store.Delete(id)
 }
 
 func (r *Repo) DataUnsafeForBench() map[string]struct{} {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	ids := make(map[string]struct{}, len(r.data))
-	for k := range r.data {
+	keys := r.store.Keys()
+	ids := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
 		ids[k] = struct{}{}
 	}
 	return ids
@@ -0,0 +1,108 @@
+package directflat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/ordersql"
+)
+
+// SQLRepo persists records through database/sql using the ordersql schema.
+// The model already has the row shape, so rows scan straight into it.
+type SQLRepo struct {
+	db    *sql.DB
+	clock clock.Clock // given to loaded orders
+}
+
+// NewSQLRepo returns a repository whose loaded orders stamp their changes
+// with c, or with the real clock if c is nil.
+func NewSQLRepo(db *sql.DB, c clock.Clock) *SQLRepo { return &SQLRepo{db: db, clock: c} }
+
+// Save upserts the order row and rewrites its item rows in one transaction.
+func (r *SQLRepo) Save(rec *OrderRecord) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	h := &rec.Header
+	res, err := tx.Exec(ordersql.UpdateOrder,
+		h.CustomerFirst, h.CustomerLast, h.CustomerEmail, h.CustomerPhone, h.LoyaltyTier, h.LoyaltyPoints,
+		h.Street1, h.Street2, h.City, h.State, h.Zip, h.BillStreet1, h.BillStreet2, h.BillCity, h.BillState, h.BillZip,
+		h.CreatedAt, h.UpdatedAt, h.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := tx.Exec(ordersql.InsertOrder, h.ID,
+			h.CustomerFirst, h.CustomerLast, h.CustomerEmail, h.CustomerPhone, h.LoyaltyTier, h.LoyaltyPoints,
+			h.Street1, h.Street2, h.City, h.State, h.Zip, h.BillStreet1, h.BillStreet2, h.BillCity, h.BillState, h.BillZip,
+			h.CreatedAt, h.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ordersql.DeleteItems, h.ID); err != nil {
+		return err
+	}
+	for i, row := range rec.Items {
+		if _, err := tx.Exec(ordersql.InsertItem, h.ID, i, row.SKU, row.Quantity, row.PriceCents, row.Currency, row.Backorder, row.Digital); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FindByID reads the order row and its item rows in one read-only
+// transaction, so they always come from the same committed Save.
+func (r *SQLRepo) FindByID(id string) (*OrderRecord, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // nothing to commit
+	rec := OrderRecord{Clock: r.clock}
+	h := &rec.Header
+	err = tx.QueryRow(ordersql.SelectOrder, id).Scan(&h.ID,
+		&h.CustomerFirst, &h.CustomerLast, &h.CustomerEmail, &h.CustomerPhone, &h.LoyaltyTier, &h.LoyaltyPoints,
+		&h.Street1, &h.Street2, &h.City, &h.State, &h.Zip, &h.BillStreet1, &h.BillStreet2, &h.BillCity, &h.BillState, &h.BillZip,
+		&h.CreatedAt, &h.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ordersql.SelectItems, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := OrderItemRow{OrderID: id}
+		if err := rows.Scan(&row.SKU, &row.Quantity, &row.PriceCents, &row.Currency, &row.Backorder, &row.Digital); err != nil {
+			return nil, err
+		}
+		rec.Items = append(rec.Items, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *SQLRepo) DataUnsafeForBench() map[string]struct{} {
+	ids, _ := ordersql.SelectIDs(r.db)
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
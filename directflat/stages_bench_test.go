@@ -0,0 +1,104 @@
+package directflat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/schema"
+	"github.com/alechenninger/go-ddd-bench/internal/stagebench"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
+)
+
+var sinkDirectFlat any
+
+func seedDirectFlatRecords(c clock.Clock, n int) []*OrderRecord {
+	recs := make([]*OrderRecord, 0, n)
+	for _, w := range workload.New(workload.Default()).Orders(n) {
+		cu := w.Customer
+		rec := NewOrderRecord(c, w.ID, cu.First, cu.Last, cu.Email, cu.LoyaltyTier, cu.LoyaltyPoints)
+		rec.Header.CustomerPhone = cu.Phone
+		s, bl := w.Shipping, w.Billing
+		rec.UpdateShipping(s.Street1, s.Street2, s.City, s.State, s.Zip)
+		rec.UpdateBilling(bl.Street1, bl.Street2, bl.City, bl.State, bl.Zip)
+		for _, it := range w.Items {
+			if err := rec.AddItem(it.SKU, it.Quantity, it.PriceCents, it.Currency, it.Backorder, it.Digital); err != nil {
+				panic(err)
+			}
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// BenchmarkDirectFlat_RMW_Stages runs Repo.FindByID and Repo.Save with a
+// small change in between, one step per stage, and reports each stage's
+// share of the cycle. The record is encoded as is, so there are no mapping
+// stages. A check before timing makes sure the pipeline writes the same blob
+// as Save.
+func BenchmarkDirectFlat_RMW_Stages(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := NewRepo(WithClock(clk))
+	var ids []string
+	for _, rec := range seedDirectFlatRecords(clk, 1000) {
+		if err := repo.Save(rec); err != nil {
+			b.Fatal(err)
+		}
+		ids = append(ids, rec.Header.ID)
+	}
+
+	var (
+		id   string
+		blob []byte
+		data []byte
+		rec  *OrderRecord
+	)
+	stages := []stagebench.Stage{
+		{Name: "get", Run: func(i int) (err error) {
+			id = ids[i%len(ids)]
+			blob, err = repo.store.Get(id)
+			return err
+		}},
+		{Name: "upgrade", Run: func(int) (err error) {
+			data, _, err = versions.Upgrade(blob)
+			return err
+		}},
+		{Name: "decode", Run: func(int) error {
+			rec = &OrderRecord{Clock: repo.clock}
+			return json.Unmarshal(data, rec)
+		}},
+		{Name: "modify", Run: func(i int) error {
+			if err := rec.RemoveItem("STAGE-TOGGLE"); errors.Is(err, ErrItemNotFound) {
+				if err := rec.AddItem("STAGE-TOGGLE", 1, 99, "USD", false, true); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+			rec.UpdateLoyaltyPoints(i)
+			return nil
+		}},
+		{Name: "encode", Run: func(int) (err error) {
+			blob, err = json.Marshal(schema.Envelope[*OrderRecord]{V: versions.Current(), Data: rec})
+			return err
+		}},
+		{Name: "put", Run: func(int) error { return repo.store.Put(id, blob) }},
+	}
+
+	for _, st := range stages {
+		if err := st.Run(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := repo.Save(rec); err != nil {
+		b.Fatal(err)
+	}
+	if saved, _ := repo.store.Get(id); !bytes.Equal(saved, blob) {
+		b.Fatalf("staged pipeline wrote\n%s\nbut Save wrote\n%s", blob, saved)
+	}
+	stagebench.Run(b, stages...)
+	sinkDirectFlat = rec
+}
@@ -0,0 +1,42 @@
+package encap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/allocbudget"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+)
+
+// allocBudgets caps the heap allocations of each mapping of o, an order with
+// line items.
+func allocBudgets(o *Order) []allocbudget.Budget {
+	return []allocbudget.Budget{
+		// Each mapping copies the items into a new slice, and FromSnapshot also
+		// allocates the Order; everything else is copied by value.
+		{Name: "ToSnapshot", Allocs: 1, Run: func() { allocsSnapshot = o.ToSnapshot() }},
+		{Name: "FromSnapshot", Allocs: 2, Run: func() { allocsOrder = FromSnapshot(allocsSnapshot) }},
+		{Name: "toPersistenceRecord", Allocs: 1, Run: func() { allocsRecord = toPersistenceRecord(allocsSnapshot) }},
+		{Name: "fromPersistenceRecord", Allocs: 1, Run: func() { allocsSnapshot = fromPersistenceRecord(allocsRecord) }},
+		{Name: "roundTripEncap", Allocs: 5, Run: func() { allocsOrder = roundTripEncap(o) }},
+	}
+}
+
+var (
+	allocsSnapshot Snapshot
+	allocsRecord   persistenceRecord
+	allocsOrder    *Order
+)
+
+func TestAllocBudgets(t *testing.T) {
+	var o *Order
+	for _, c := range seedEncapOrders(clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond), 64) {
+		if len(c.items) > 1 {
+			o = c
+			break
+		}
+	}
+	allocsSnapshot = o.ToSnapshot()
+	allocsRecord = toPersistenceRecord(allocsSnapshot)
+	allocbudget.Check(t, allocBudgets(o))
+}
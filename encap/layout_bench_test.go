@@ -0,0 +1,41 @@
+package encap
+
+import (
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/layoutbench"
+)
+
+// lineItemReordered is lineItem in the field order cmd/layout suggests: the
+// same fields and size, with quantity after the pointer-holding price.
+type lineItemReordered struct {
+	sku      string
+	price    Money
+	quantity int
+	line     int
+	flags    itemFlags
+	dirty    bool
+}
+
+// The reordered copies are written by hand; this keeps them in step with
+// the declared types.
+func TestLayout_ReorderedSameFields(t *testing.T) {
+	if err := layoutbench.SameFields[lineItem, lineItemReordered](); err != nil {
+		t.Error(err)
+	}
+}
+
+var layoutStrings = [...]string{"SKU-42", "USD", "EUR", "SKU-7"}
+
+func layoutString(i int) string { return layoutStrings[i%len(layoutStrings)] }
+
+func BenchmarkEncap_Layout_LineItem(b *testing.B) {
+	layoutbench.Compare(b,
+		func(it *lineItem, i int) {
+			*it = lineItem{sku: layoutString(i), quantity: i, price: NewMoney(int64(i), layoutString(i+1)), line: i, flags: itemFlags{backorder: i%2 == 0}, dirty: i%3 == 0}
+		},
+		func(it *lineItemReordered, i int) {
+			*it = lineItemReordered{sku: layoutString(i), quantity: i, price: NewMoney(int64(i), layoutString(i+1)), line: i, flags: itemFlags{backorder: i%2 == 0}, dirty: i%3 == 0}
+		},
+	)
+}
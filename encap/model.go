@@ -1,11 +1,22 @@
 package encap
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/alechenninger/go-ddd-bench/internal/clock"
 )
 
+var (
+	// ErrItemNotFound is returned when no line item has the requested SKU.
+	ErrItemNotFound = errors.New("item not found")
+	// ErrInvalidQuantity is returned for line quantities below one.
+	ErrInvalidQuantity = errors.New("invalid quantity")
+	// ErrInvalidDiscount is returned for discounts outside 0-100 percent.
+	ErrInvalidDiscount = errors.New("invalid discount")
+)
+
 // Snapshot DTOs with deeper nesting
 
 type SnapshotName struct{ First, Last string }
@@ -18,6 +29,7 @@ type SnapshotLoyalty struct {
 type SnapshotCustomer struct {
 	Name    SnapshotName
 	Email   string
+	Phone   string
 	Loyalty SnapshotLoyalty
 }
 
@@ -32,10 +44,11 @@ type Snapshot struct {
 }
 
 type SnapshotAddress struct {
-	Street string
-	City   string
-	State  string
-	Zip    string
+	Street1 string
+	Street2 string
+	City    string
+	State   string
+	Zip     string
 }
 
 type SnapshotMoney struct {
@@ -64,19 +77,16 @@ type loyalty struct {
 type customer struct {
 	name    name
 	email   string
+	phone   string
 	loyalty loyalty
 }
 
 type address struct {
-	street string
-	city   string
-	state  string
-	zip    string
-}
-
-type money struct {
-	cents    int64
-	currency string
+	street1 string
+	street2 string
+	city    string
+	state   string
+	zip     string
 }
 
 type itemFlags struct{ backorder, digital bool }
@@ -84,8 +94,10 @@ type itemFlags struct{ backorder, digital bool }
 type lineItem struct {
 	sku      string
 	quantity int
-	price    money
+	price    Money
+	line     int // order_items key; see PartialRepo
 	flags    itemFlags
+	dirty    bool // changed since last persisted; see PartialRepo
 }
 
 type Order struct {
@@ -96,77 +108,235 @@ type Order struct {
 	items     []lineItem
 	createdAt time.Time
 	updatedAt time.Time
+	clock     clock.Clock // stamps updatedAt; nil is the real clock
+
+	// Change tracking for PartialRepo and RowRepo. owner is the repository
+	// the order was last loaded from or saved to; any other writes it in full.
+	// Items get line IDs that increase in item order. Those below savedLines
+	// are stored, and removedLines lists the stored ones removed since.
+	owner        any
+	headerDirty  bool
+	nextLine     int
+	savedLines   int
+	removedLines []int
 }
 
-func NewOrder(id string, cust SnapshotCustomer, shipping, billing SnapshotAddress) *Order {
+// NewOrder returns an order with no items, stamped and later touched by c.
+func NewOrder(c clock.Clock, id string, cust SnapshotCustomer, shipping, billing SnapshotAddress) *Order {
 	return &Order{
 		id: id,
 		customer: customer{
 			name:    name{first: cust.Name.First, last: cust.Name.Last},
 			email:   cust.Email,
+			phone:   cust.Phone,
 			loyalty: loyalty{tier: cust.Loyalty.Tier, points: cust.Loyalty.Points},
 		},
-		shipping:  address{street: shipping.Street, city: shipping.City, state: shipping.State, zip: shipping.Zip},
-		billing:   address{street: billing.Street, city: billing.City, state: billing.State, zip: billing.Zip},
+		shipping:  address{street1: shipping.Street1, street2: shipping.Street2, city: shipping.City, state: shipping.State, zip: shipping.Zip},
+		billing:   address{street1: billing.Street1, street2: billing.Street2, city: billing.City, state: billing.State, zip: billing.Zip},
 		items:     nil,
-		createdAt: clock.Now(),
-		updatedAt: clock.Now(),
+		createdAt: clock.Now(c),
+		updatedAt: clock.Now(c),
+		clock:     c,
 	}
 }
 
-func (o *Order) AddItem(sku string, qty int, priceCents int64, currency string, flags SnapshotItemFlags) {
-	o.items = append(o.items, lineItem{sku: sku, quantity: qty, price: money{cents: priceCents, currency: currency}, flags: itemFlags{backorder: flags.Backorder, digital: flags.Digital}})
+// AddItem appends a line item. Like ChangeQuantity, it rejects quantities
+// below one.
+func (o *Order) AddItem(sku string, qty int, priceCents int64, currency string, flags SnapshotItemFlags) error {
+	if qty < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, qty)
+	}
+	o.items = append(o.items, lineItem{sku: sku, quantity: qty, price: NewMoney(priceCents, currency), line: o.nextLine, flags: itemFlags{backorder: flags.Backorder, digital: flags.Digital}, dirty: true})
+	o.nextLine++
 	o.touch()
+	return nil
 }
 
 func (o *Order) UpdateShipping(s SnapshotAddress) {
-	o.shipping = address{street: s.Street, city: s.City, state: s.State, zip: s.Zip}
+	o.shipping = address{street1: s.Street1, street2: s.Street2, city: s.City, state: s.State, zip: s.Zip}
 	o.touch()
 }
 func (o *Order) UpdateBilling(s SnapshotAddress) {
-	o.billing = address{street: s.Street, city: s.City, state: s.State, zip: s.Zip}
+	o.billing = address{street1: s.Street1, street2: s.Street2, city: s.City, state: s.State, zip: s.Zip}
+	o.touch()
+}
+
+// RemoveItem removes the first line item with the given SKU.
+func (o *Order) RemoveItem(sku string) error {
+	i := o.itemIndex(sku)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrItemNotFound, sku)
+	}
+	if line := o.items[i].line; line < o.savedLines {
+		o.removedLines = append(o.removedLines, line)
+	}
+	o.items = append(o.items[:i], o.items[i+1:]...)
+	o.touch()
+	return nil
+}
+
+// ChangeQuantity sets the quantity of the first line item with the given SKU.
+func (o *Order) ChangeQuantity(sku string, qty int) error {
+	if qty < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, qty)
+	}
+	i := o.itemIndex(sku)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrItemNotFound, sku)
+	}
+	o.items[i].quantity = qty
+	o.items[i].dirty = true
 	o.touch()
+	return nil
+}
+
+// ApplyDiscount reduces every item's unit price by percent, rounding the
+// discount down to whole cents.
+func (o *Order) ApplyDiscount(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("%w: %d%%", ErrInvalidDiscount, percent)
+	}
+	for i := range o.items {
+		o.items[i].price.v = o.items[i].price.v.Discount(percent)
+		o.items[i].dirty = true
+	}
+	o.touch()
+	return nil
+}
+
+func (o *Order) UpdateLoyaltyPoints(points int) {
+	o.customer.loyalty.points = points
+	o.touch()
+}
+
+func (o *Order) itemIndex(sku string) int {
+	for i := range o.items {
+		if o.items[i].sku == sku {
+			return i
+		}
+	}
+	return -1
 }
 
-func (o *Order) touch() { o.updatedAt = clock.Now() }
+func (o *Order) touch() {
+	o.updatedAt = clock.Now(o.clock)
+	o.headerDirty = true
+}
+
+// markPersisted records that the order's current state matches what owner
+// stores.
+func (o *Order) markPersisted(owner any) {
+	o.owner = owner
+	o.headerDirty = false
+	o.savedLines = o.nextLine
+	o.removedLines = o.removedLines[:0]
+	for i := range o.items {
+		o.items[i].dirty = false
+	}
+}
+
+// lines returns the line IDs of o's items, in order.
+func (o *Order) lines() []int {
+	lines := make([]int, len(o.items))
+	for i := range o.items {
+		lines[i] = o.items[i].line
+	}
+	return lines
+}
+
+// setLines gives o's items the line IDs they are stored under.
+func (o *Order) setLines(lines []int) {
+	o.nextLine = 0
+	for i := range o.items {
+		o.items[i].line = lines[i]
+		o.nextLine = max(o.nextLine, lines[i]+1)
+	}
+}
 
 func (o *Order) ToSnapshot() Snapshot {
-	items := make([]SnapshotLineItem, len(o.items))
-	for i, it := range o.items {
-		items[i] = SnapshotLineItem{
+	var s Snapshot
+	o.snapshotInto(&s)
+	return s
+}
+
+// snapshotInto writes o's snapshot to s, reusing s's item array when it has
+// room.
+func (o *Order) snapshotInto(s *Snapshot) {
+	items := s.Items[:0]
+	if items == nil || cap(items) < len(o.items) { // an empty array still encodes as [], not null
+		items = make([]SnapshotLineItem, 0, len(o.items))
+	}
+	for _, it := range o.items {
+		items = append(items, SnapshotLineItem{
 			SKU:      it.sku,
 			Quantity: it.quantity,
-			Price:    SnapshotMoney{Cents: it.price.cents, Currency: it.price.currency},
+			Price:    SnapshotMoney{Cents: it.price.Cents(), Currency: it.price.Currency()},
 			Flags:    SnapshotItemFlags{Backorder: it.flags.backorder, Digital: it.flags.digital},
-		}
+		})
 	}
-	return Snapshot{
+	*s = Snapshot{
 		ID: o.id,
 		Customer: SnapshotCustomer{
 			Name:    SnapshotName{First: o.customer.name.first, Last: o.customer.name.last},
 			Email:   o.customer.email,
+			Phone:   o.customer.phone,
 			Loyalty: SnapshotLoyalty{Tier: o.customer.loyalty.tier, Points: o.customer.loyalty.points},
 		},
-		Shipping:  SnapshotAddress{Street: o.shipping.street, City: o.shipping.city, State: o.shipping.state, Zip: o.shipping.zip},
-		Billing:   SnapshotAddress{Street: o.billing.street, City: o.billing.city, State: o.billing.state, Zip: o.billing.zip},
+		Shipping:  SnapshotAddress{Street1: o.shipping.street1, Street2: o.shipping.street2, City: o.shipping.city, State: o.shipping.state, Zip: o.shipping.zip},
+		Billing:   SnapshotAddress{Street1: o.billing.street1, Street2: o.billing.street2, City: o.billing.city, State: o.billing.state, Zip: o.billing.zip},
 		Items:     items,
 		CreatedAt: o.createdAt,
 		UpdatedAt: o.updatedAt,
 	}
 }
 
+// FromSnapshot rebuilds an order from s. The order uses the real clock;
+// repositories give the orders they load their own.
 func FromSnapshot(s Snapshot) *Order {
-	items := make([]lineItem, len(s.Items))
-	for i, it := range s.Items {
-		items[i] = lineItem{sku: it.SKU, quantity: it.Quantity, price: money{cents: it.Price.Cents, currency: it.Price.Currency}, flags: itemFlags{backorder: it.Flags.Backorder, digital: it.Flags.Digital}}
+	o := new(Order)
+	o.restore(s)
+	return o
+}
+
+// reset clears o for reuse, keeping the backing array of its items.
+func (o *Order) reset() {
+	items := o.items[:cap(o.items)]
+	clear(items)
+	*o = Order{items: items[:0]}
+}
+
+// restore replaces o's state with s, as FromSnapshot would build it, reusing
+// o's item array when it has room.
+func (o *Order) restore(s Snapshot) {
+	o.reset()
+	o.id = s.ID
+	o.customer = customer{name: name{first: s.Customer.Name.First, last: s.Customer.Name.Last}, email: s.Customer.Email, phone: s.Customer.Phone, loyalty: loyalty{tier: s.Customer.Loyalty.Tier, points: s.Customer.Loyalty.Points}}
+	o.shipping = address{street1: s.Shipping.Street1, street2: s.Shipping.Street2, city: s.Shipping.City, state: s.Shipping.State, zip: s.Shipping.Zip}
+	o.billing = address{street1: s.Billing.Street1, street2: s.Billing.Street2, city: s.Billing.City, state: s.Billing.State, zip: s.Billing.Zip}
+	if cap(o.items) < len(s.Items) {
+		o.items = make([]lineItem, 0, len(s.Items))
 	}
-	return &Order{
-		id:        s.ID,
-		customer:  customer{name: name{first: s.Customer.Name.First, last: s.Customer.Name.Last}, email: s.Customer.Email, loyalty: loyalty{tier: s.Customer.Loyalty.Tier, points: s.Customer.Loyalty.Points}},
-		shipping:  address{street: s.Shipping.Street, city: s.Shipping.City, state: s.Shipping.State, zip: s.Shipping.Zip},
-		billing:   address{street: s.Billing.Street, city: s.Billing.City, state: s.Billing.State, zip: s.Billing.Zip},
-		items:     items,
-		createdAt: s.CreatedAt,
-		updatedAt: s.UpdatedAt,
+	for _, it := range s.Items {
+		o.items = append(o.items, lineItem{sku: it.SKU, quantity: it.Quantity, price: NewMoney(it.Price.Cents, it.Price.Currency), line: len(o.items), flags: itemFlags{backorder: it.Flags.Backorder, digital: it.Flags.Digital}})
+	}
+	o.nextLine = len(o.items)
+	o.createdAt = s.CreatedAt
+	o.updatedAt = s.UpdatedAt
+}
+
+// Total returns the order total as one Money per currency, in the order each
+// currency first appears among the items. It fails with money.ErrOverflow if
+// a line or a sum does not fit in int64 cents.
+func (o *Order) Total() ([]Money, error) {
+	var totals []Money
+	for _, it := range o.items {
+		line, err := it.price.Multiply(it.quantity)
+		if err != nil {
+			return nil, err
+		}
+		if totals, err = accumulate(totals, line); err != nil {
+			return nil, err
+		}
 	}
+	return totals, nil
 }
@@ -0,0 +1,49 @@
+package encap
+
+import "github.com/alechenninger/go-ddd-bench/internal/money"
+
+// Money is an amount in minor units of a currency.
+type Money struct{ v money.Money }
+
+func NewMoney(cents int64, currency string) Money {
+	return Money{money.Money{Cents: cents, Currency: currency}}
+}
+
+func (m Money) Cents() int64     { return m.v.Cents }
+func (m Money) Currency() string { return m.v.Currency }
+
+// Add returns m + other. Both amounts must share a currency, and the sum must
+// fit in int64 cents.
+func (m Money) Add(other Money) (Money, error) {
+	v, err := m.v.Add(other.v)
+	return Money{v}, err
+}
+
+// Multiply returns m scaled by qty, e.g. a unit price times a line quantity.
+// It fails with money.ErrOverflow if the product does not fit in int64 cents.
+func (m Money) Multiply(qty int) (Money, error) {
+	v, err := m.v.Multiply(qty)
+	return Money{v}, err
+}
+
+// AppendFormat appends m formatted as "12.34 USD" to b and returns the
+// extended buffer. It does not allocate if b has enough capacity.
+func (m Money) AppendFormat(b []byte) []byte { return m.v.AppendFormat(b) }
+
+func (m Money) String() string { return m.v.String() }
+
+// accumulate adds m to the entry of totals with the same currency, appending
+// a new entry the first time a currency is seen.
+func accumulate(totals []Money, m Money) ([]Money, error) {
+	for i := range totals {
+		if totals[i].Currency() == m.Currency() {
+			sum, err := totals[i].Add(m)
+			if err != nil {
+				return nil, err
+			}
+			totals[i] = sum
+			return totals, nil
+		}
+	}
+	return append(totals, m), nil
+}
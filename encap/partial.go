@@ -0,0 +1,134 @@
+package encap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/kv"
+)
+
+// Tables used by PartialRepo.
+const (
+	headerTable = "orders"
+	itemTable   = "order_items"
+)
+
+// PartialRepo stores each order as a header row plus one row per line item,
+// keyed per table. The aggregate tracks its own changes, so Save writes only
+// the header and the item rows that were inserted, updated or deleted since
+// the order was loaded from or last saved to this repository.
+//
+// Item rows are keyed by a line ID that stays with the item for as long as it
+// is stored, so removing one item does not rewrite those after it. The store
+// cannot scan a key range, so the header row lists the order's line IDs.
+type PartialRepo struct {
+	store *kv.Store
+	clock clock.Clock // given to loaded orders
+}
+
+// NewPartialRepo returns a repository whose loaded orders stamp their
+// changes with c, or with the real clock if c is nil.
+func NewPartialRepo(store *kv.Store, c clock.Clock) *PartialRepo {
+	return &PartialRepo{store: store, clock: c}
+}
+
+// itemKey is the order_items primary key: the order ID and the item's line ID.
+func itemKey(orderID string, line int) string { return orderID + "/" + strconv.Itoa(line) }
+
+// headerRow is the orders row PartialRepo stores.
+type headerRow struct {
+	OrderHeader
+	Lines []int // line IDs of the item rows, in item order
+}
+
+func (r *PartialRepo) Save(o *Order) error {
+	s := o.ToSnapshot()
+	tracked := o.owner == r
+	lines := o.lines()
+	var b kv.Batch
+	if !tracked || o.headerDirty || len(o.removedLines) > 0 || o.nextLine != o.savedLines {
+		blob, err := json.Marshal(headerRow{OrderHeader: toOrderHeader(s), Lines: lines})
+		if err != nil {
+			return err
+		}
+		b.Put(headerTable, s.ID, blob)
+	}
+	for i, it := range s.Items {
+		if tracked && !o.items[i].dirty {
+			continue
+		}
+		blob, err := json.Marshal(toOrderItemRow(s.ID, it))
+		if err != nil {
+			return err
+		}
+		b.Put(itemTable, itemKey(s.ID, lines[i]), blob)
+	}
+	if tracked {
+		for _, line := range o.removedLines {
+			b.Delete(itemTable, itemKey(s.ID, line))
+		}
+	} else if blob, ok := r.store.Get(headerTable, s.ID); ok {
+		// Unknown stored state; remove rows the stored version has and o lacks.
+		var h headerRow
+		if err := json.Unmarshal(blob, &h); err != nil {
+			return err
+		}
+		for _, line := range h.Lines {
+			if _, found := slices.BinarySearch(lines, line); !found {
+				b.Delete(itemTable, itemKey(s.ID, line))
+			}
+		}
+	}
+	r.store.Commit(&b)
+	o.markPersisted(r)
+	return nil
+}
+
+func (r *PartialRepo) FindByID(id string) (*Order, error) {
+	var rec persistenceRecord
+	var h headerRow
+	err := r.store.Read(func(kr kv.Reader) error {
+		blob, ok := kr.Get(headerTable, id)
+		if !ok {
+			return errors.New("not found")
+		}
+		if err := json.Unmarshal(blob, &h); err != nil {
+			return err
+		}
+		rec.Header = h.OrderHeader
+		for _, line := range h.Lines {
+			blob, ok := kr.Get(itemTable, itemKey(id, line))
+			if !ok {
+				return fmt.Errorf("item row %s not found", itemKey(id, line))
+			}
+			var row OrderItemRow
+			if err := json.Unmarshal(blob, &row); err != nil {
+				return err
+			}
+			rec.Items = append(rec.Items, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := FromSnapshot(fromPersistenceRecord(rec))
+	o.clock = r.clock
+	o.setLines(h.Lines)
+	o.markPersisted(r)
+	return o, nil
+}
+
+// DataUnsafeForBench returns a copy of the keys to iterate in benchmarks.
+func (r *PartialRepo) DataUnsafeForBench() map[string]struct{} {
+	keys := r.store.Keys(headerTable)
+	ids := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		ids[k] = struct{}{}
+	}
+	return ids
+}
@@ -0,0 +1,70 @@
+package encap
+
+import (
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/kv"
+	"github.com/alechenninger/go-ddd-bench/internal/table"
+)
+
+// Change tracking lives on the Order and does not survive a Snapshot, so an
+// order rebuilt with FromSnapshot writes every row, even to the repository
+// its snapshot came from.
+func TestRowRepos_SnapshotCopyWritesInFull(t *testing.T) {
+	type repo struct {
+		save  func(*Order) error
+		find  func(string) (*Order, error)
+		stats func() (puts, deletes int64)
+	}
+	for _, tc := range []struct {
+		name string
+		new  func() repo
+	}{
+		{"partial", func() repo {
+			store := kv.New()
+			r := NewPartialRepo(store, nil)
+			return repo{r.Save, r.FindByID, store.Stats}
+		}},
+		{"rows", func() repo {
+			db := table.NewDB()
+			r := NewRowRepo(db, nil)
+			return repo{r.Save, r.FindByID, db.Stats}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := tc.new()
+			o := NewOrder(nil, "o", SnapshotCustomer{}, SnapshotAddress{}, SnapshotAddress{})
+			for _, sku := range []string{"A", "B", "C"} {
+				if err := o.AddItem(sku, 1, 1000, "USD", SnapshotItemFlags{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.save(o); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := r.find("o")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := loaded.RemoveItem("B"); err != nil {
+				t.Fatal(err)
+			}
+
+			p0, d0 := r.stats()
+			if err := r.save(FromSnapshot(loaded.ToSnapshot())); err != nil {
+				t.Fatal(err)
+			}
+			p1, d1 := r.stats()
+			if puts, deletes := p1-p0, d1-d0; puts != 3 || deletes != 1 {
+				t.Errorf("Save wrote %d puts, %d deletes; want 3, 1", puts, deletes)
+			}
+			got, err := r.find("o")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(got.ToSnapshot().Items); n != 2 {
+				t.Errorf("reloaded %d items, want 2", n)
+			}
+		})
+	}
+}
@@ -2,24 +2,33 @@ package encap
 
 import (
 	"encoding/json"
-	"errors"
 	"sync"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/bufpool"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/schema"
 )
 
 // RDBMS-oriented DTOs (tables) — flat structures intended for persistence.
-// OrderHeader corresponds to an orders table.
+// OrderHeader corresponds to an orders table. Its times are Unix
+// nanoseconds with 0 reserved for the zero time, so the Unix epoch itself
+// cannot be represented and reads back as zero.
 type OrderHeader struct {
 	ID            string
 	CustomerFirst string
 	CustomerLast  string
 	CustomerEmail string
+	CustomerPhone string
 	LoyaltyTier   string
 	LoyaltyPoints int
-	Street        string
+	Street1       string
+	Street2       string
 	City          string
 	State         string
 	Zip           string
-	BillStreet    string
+	BillStreet1   string
+	BillStreet2   string
 	BillCity      string
 	BillState     string
 	BillZip       string
@@ -45,99 +54,270 @@ type persistenceRecord struct {
 	Items  []OrderItemRow
 }
 
+// versions migrates stored records to the current persistenceRecord shape:
+//
+//	v2 splits Street and BillStreet into two lines each
+//	v3 adds CustomerPhone
+var versions = schema.NewChain(
+	schema.Steps(
+		schema.SplitLines("Header.Street", "Street1", "Street2"),
+		schema.SplitLines("Header.BillStreet", "BillStreet1", "BillStreet2"),
+	),
+	schema.Default("Header.CustomerPhone", ""),
+)
+
 // Repo simulates a repository with multiple transformations:
 // domain <-> snapshot <-> persistence DTOs <-> bytes
 // We store JSON blobs to emulate IO and avoid in-memory aliasing.
 type Repo struct {
-	mu   sync.RWMutex
-	data map[string][]byte
+	store blobstore.Store
+	stale *schema.Stale // nil unless WithLazyRewrite
+	clock clock.Clock   // given to loaded orders
+
+	pooled bool // map and encode through pooled values; see WithPooling
 }
 
-func NewRepo() *Repo { return &Repo{data: make(map[string][]byte)} }
+// Pools for repositories created WithPooling. Values go back cleared, so
+// pooled orders' strings are not kept alive, and keep their item arrays.
+var (
+	recordPool   = sync.Pool{New: func() any { return new(persistenceRecord) }}
+	snapshotPool = sync.Pool{New: func() any { return new(Snapshot) }}
+)
+
+// maxPooledItems is the largest item capacity returned to the pools. Like
+// bufpool's cap on buffers, it keeps a rare huge order from pinning its
+// arrays for as long as the pool keeps them.
+const maxPooledItems = 256
+
+func releaseRecord(rec *persistenceRecord) {
+	if cap(rec.Items) > maxPooledItems {
+		return
+	}
+	items := rec.Items[:cap(rec.Items)]
+	clear(items) // json fills elements in place, keeping fields the blob lacks
+	*rec = persistenceRecord{Items: items[:0]}
+	recordPool.Put(rec)
+}
+
+func releaseSnapshot(s *Snapshot) {
+	if cap(s.Items) > maxPooledItems {
+		return
+	}
+	items := s.Items[:cap(s.Items)]
+	clear(items)
+	*s = Snapshot{Items: items[:0]}
+	snapshotPool.Put(s)
+}
+
+// Option configures a Repo.
+type Option func(*Repo)
+
+// WithStore keeps blobs in s instead of the default in-memory store.
+func WithStore(s blobstore.Store) Option { return func(r *Repo) { r.store = s } }
+
+// WithLazyRewrite makes each Save also rewrite, at the current schema
+// version, up to schema.FlushBatch of the orders FindByID had to upcast,
+// remembering at most schema.MaxPending of them in between. A failed rewrite
+// does not fail the Save that triggered it. Without this option, an old blob
+// is only migrated when its own order is saved.
+func WithLazyRewrite() Option { return func(r *Repo) { r.stale = schema.NewStale() } }
+
+// WithClock makes the orders FindByID returns stamp their changes with c
+// instead of the real clock.
+func WithClock(c clock.Clock) Option { return func(r *Repo) { r.clock = c } }
+
+// WithPooling makes Save and FindByID take the snapshot and persistence
+// record they map through, with their item slices, from pools instead of
+// allocating them on every call, and makes Save encode through a pooled
+// buffer and JSON encoder.
+func WithPooling() Option { return func(r *Repo) { r.pooled = true } }
+
+func NewRepo(opts ...Option) *Repo {
+	r := &Repo{store: blobstore.NewMemory()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
 
 func (r *Repo) Save(o *Order) error {
-	s := o.ToSnapshot()
-	rec := toPersistenceRecord(s)
-	blob, err := json.Marshal(rec)
+	var (
+		blob []byte
+		err  error
+	)
+	if r.pooled {
+		s := snapshotPool.Get().(*Snapshot)
+		rec := recordPool.Get().(*persistenceRecord)
+		o.snapshotInto(s)
+		toPersistenceRecordInto(*s, rec)
+		blob, err = bufpool.Marshal(schema.Envelope[*persistenceRecord]{V: versions.Current(), Data: rec})
+		releaseSnapshot(s)
+		releaseRecord(rec)
+	} else {
+		rec := toPersistenceRecord(o.ToSnapshot())
+		blob, err = json.Marshal(schema.Envelope[persistenceRecord]{V: versions.Current(), Data: rec})
+	}
 	if err != nil {
 		return err
 	}
-	r.mu.Lock()
-	r.data[s.ID] = blob
-	r.mu.Unlock()
+	if err := r.store.Put(o.id, blob); err != nil {
+		return err
+	}
+	if r.stale != nil {
+		r.stale.Forget(o.id)
+		_ = r.stale.Flush(r.store, schema.FlushBatch) // failed rewrites are retried on the next Save
+	}
 	return nil
 }
 
 func (r *Repo) FindByID(id string) (*Order, error) {
-	r.mu.RLock()
-	blob, ok := r.data[id]
-	r.mu.RUnlock()
-	if !ok {
-		return nil, errors.New("not found")
-	}
-	var rec persistenceRecord
-	if err := json.Unmarshal(blob, &rec); err != nil {
+	o := new(Order)
+	if err := r.LoadInto(id, o); err != nil {
 		return nil, err
 	}
-	s := fromPersistenceRecord(rec)
-	return FromSnapshot(s), nil
+	return o, nil
+}
+
+// LoadInto decodes the order with the given ID into dst, replacing all of
+// its state. dst's item array is reused when it has room, so loading into the
+// same order again and again allocates no new aggregate; the record and
+// snapshot it is mapped through are still built fresh unless the repository
+// was created WithPooling. dst is unchanged if the order cannot be read or
+// decoded.
+func (r *Repo) LoadInto(id string, dst *Order) error {
+	blob, err := r.store.Get(id)
+	if err != nil {
+		return err
+	}
+	data, from, err := versions.Upgrade(blob)
+	if err != nil {
+		return err
+	}
+	if r.pooled {
+		rec := recordPool.Get().(*persistenceRecord)
+		defer releaseRecord(rec)
+		if err := json.Unmarshal(data, rec); err != nil {
+			return err
+		}
+		r.markStale(id, from, blob, data)
+		s := snapshotPool.Get().(*Snapshot)
+		defer releaseSnapshot(s)
+		fromPersistenceRecordInto(*rec, s)
+		dst.restore(*s)
+	} else {
+		var rec persistenceRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		r.markStale(id, from, blob, data)
+		dst.restore(fromPersistenceRecord(rec))
+	}
+	dst.clock = r.clock
+	return nil
+}
+
+// markStale queues an order FindByID had to upcast for rewriting, if the
+// repository rewrites lazily.
+func (r *Repo) markStale(id string, from int, blob, data []byte) {
+	if r.stale != nil && from != versions.Current() {
+		r.stale.Add(id, blob, versions.Wrap(nil, data))
+	}
+}
+
+// Delete removes the order with the given ID. Deleting an order that does
+// not exist is not an error.
+func (r *Repo) Delete(id string) error {
+	if r.stale != nil {
+		r.stale.Forget(id)
+	}
+	return r.store.Delete(id)
 }
 
 // DataUnsafeForBench returns a copy of the keys to iterate in benchmarks.
 func (r *Repo) DataUnsafeForBench() map[string]struct{} {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	ids := make(map[string]struct{}, len(r.data))
-	for k := range r.data {
+	keys := r.store.Keys()
+	ids := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
 		ids[k] = struct{}{}
 	}
 	return ids
 }
 
 func toPersistenceRecord(s Snapshot) persistenceRecord {
-	rec := persistenceRecord{
-		Header: OrderHeader{
-			ID:            s.ID,
-			CustomerFirst: s.Customer.Name.First,
-			CustomerLast:  s.Customer.Name.Last,
-			CustomerEmail: s.Customer.Email,
-			LoyaltyTier:   s.Customer.Loyalty.Tier,
-			LoyaltyPoints: s.Customer.Loyalty.Points,
-			Street:        s.Shipping.Street,
-			City:          s.Shipping.City,
-			State:         s.Shipping.State,
-			Zip:           s.Shipping.Zip,
-			BillStreet:    s.Billing.Street,
-			BillCity:      s.Billing.City,
-			BillState:     s.Billing.State,
-			BillZip:       s.Billing.Zip,
-			CreatedAt:     s.CreatedAt.UnixNano(),
-			UpdatedAt:     s.UpdatedAt.UnixNano(),
-		},
+	var rec persistenceRecord
+	toPersistenceRecordInto(s, &rec)
+	return rec
+}
+
+// toPersistenceRecordInto writes s's record to rec, reusing rec's item array
+// when it has room.
+func toPersistenceRecordInto(s Snapshot, rec *persistenceRecord) {
+	items := rec.Items[:0]
+	if items == nil || cap(items) < len(s.Items) { // an empty array still encodes as [], not null
+		items = make([]OrderItemRow, 0, len(s.Items))
 	}
-	rec.Items = make([]OrderItemRow, len(s.Items))
-	for i, it := range s.Items {
-		rec.Items[i] = OrderItemRow{OrderID: s.ID, SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.Price.Cents, Currency: it.Price.Currency, Backorder: it.Flags.Backorder, Digital: it.Flags.Digital}
+	for _, it := range s.Items {
+		items = append(items, toOrderItemRow(s.ID, it))
 	}
-	return rec
+	*rec = persistenceRecord{Header: toOrderHeader(s), Items: items}
+}
+
+func toOrderHeader(s Snapshot) OrderHeader {
+	return OrderHeader{
+		ID:            s.ID,
+		CustomerFirst: s.Customer.Name.First,
+		CustomerLast:  s.Customer.Name.Last,
+		CustomerEmail: s.Customer.Email,
+		CustomerPhone: s.Customer.Phone,
+		LoyaltyTier:   s.Customer.Loyalty.Tier,
+		LoyaltyPoints: s.Customer.Loyalty.Points,
+		Street1:       s.Shipping.Street1,
+		Street2:       s.Shipping.Street2,
+		City:          s.Shipping.City,
+		State:         s.Shipping.State,
+		Zip:           s.Shipping.Zip,
+		BillStreet1:   s.Billing.Street1,
+		BillStreet2:   s.Billing.Street2,
+		BillCity:      s.Billing.City,
+		BillState:     s.Billing.State,
+		BillZip:       s.Billing.Zip,
+		CreatedAt:     timeToUnix(s.CreatedAt),
+		UpdatedAt:     timeToUnix(s.UpdatedAt),
+	}
+}
+
+func toOrderItemRow(orderID string, it SnapshotLineItem) OrderItemRow {
+	return OrderItemRow{OrderID: orderID, SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.Price.Cents, Currency: it.Price.Currency, Backorder: it.Flags.Backorder, Digital: it.Flags.Digital}
 }
 
 func fromPersistenceRecord(rec persistenceRecord) Snapshot {
-	s := Snapshot{
+	var s Snapshot
+	fromPersistenceRecordInto(rec, &s)
+	return s
+}
+
+// fromPersistenceRecordInto writes rec's snapshot to s, reusing s's item
+// array when it has room.
+func fromPersistenceRecordInto(rec persistenceRecord, s *Snapshot) {
+	items := s.Items[:0]
+	if items == nil || cap(items) < len(rec.Items) {
+		items = make([]SnapshotLineItem, 0, len(rec.Items))
+	}
+	for _, row := range rec.Items {
+		items = append(items, SnapshotLineItem{SKU: row.SKU, Quantity: row.Quantity, Price: SnapshotMoney{Cents: row.PriceCents, Currency: row.Currency}, Flags: SnapshotItemFlags{Backorder: row.Backorder, Digital: row.Digital}})
+	}
+	*s = Snapshot{
 		ID: rec.Header.ID,
 		Customer: SnapshotCustomer{
 			Name:    SnapshotName{First: rec.Header.CustomerFirst, Last: rec.Header.CustomerLast},
 			Email:   rec.Header.CustomerEmail,
+			Phone:   rec.Header.CustomerPhone,
 			Loyalty: SnapshotLoyalty{Tier: rec.Header.LoyaltyTier, Points: rec.Header.LoyaltyPoints},
 		},
-		Shipping:  SnapshotAddress{Street: rec.Header.Street, City: rec.Header.City, State: rec.Header.State, Zip: rec.Header.Zip},
-		Billing:   SnapshotAddress{Street: rec.Header.BillStreet, City: rec.Header.BillCity, State: rec.Header.BillState, Zip: rec.Header.BillZip},
+		Shipping:  SnapshotAddress{Street1: rec.Header.Street1, Street2: rec.Header.Street2, City: rec.Header.City, State: rec.Header.State, Zip: rec.Header.Zip},
+		Billing:   SnapshotAddress{Street1: rec.Header.BillStreet1, Street2: rec.Header.BillStreet2, City: rec.Header.BillCity, State: rec.Header.BillState, Zip: rec.Header.BillZip},
+		Items:     items,
 		CreatedAt: unixToTime(rec.Header.CreatedAt),
 		UpdatedAt: unixToTime(rec.Header.UpdatedAt),
 	}
-	s.Items = make([]SnapshotLineItem, len(rec.Items))
-	for i, row := range rec.Items {
-		s.Items[i] = SnapshotLineItem{SKU: row.SKU, Quantity: row.Quantity, Price: SnapshotMoney{Cents: row.PriceCents, Currency: row.Currency}, Flags: SnapshotItemFlags{Backorder: row.Backorder, Digital: row.Digital}}
-	}
-	return s
 }
@@ -0,0 +1,12 @@
+package encap
+
+import "testing"
+
+// A missing CreatedAt in the persistence record comes back as the zero time,
+// as in direct, rather than the Unix epoch.
+func TestFromPersistenceRecord_ZeroCreatedAtIsZeroTime(t *testing.T) {
+	s := fromPersistenceRecord(persistenceRecord{})
+	if !s.CreatedAt.IsZero() {
+		t.Fatalf("CreatedAt = %v, want the zero time", s.CreatedAt)
+	}
+}
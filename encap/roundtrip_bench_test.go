@@ -1,32 +1,32 @@
 package encap
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
 	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
 )
 
 var sinkEncap any
 
-func randID() string {
-	var b [16]byte
-	_, _ = rand.Read(b[:])
-	return hex.EncodeToString(b[:])
-}
-
-func seedEncapOrders(n int) []*Order {
+func seedEncapOrders(c clock.Clock, n int) []*Order {
 	orders := make([]*Order, 0, n)
-	for i := 0; i < n; i++ {
-		cust := SnapshotCustomer{Name: SnapshotName{First: "Ada", Last: "Lovelace"}, Email: "ada@example.com", Loyalty: SnapshotLoyalty{Tier: "gold", Points: 100}}
-		ship := SnapshotAddress{Street: "1 Main", City: "Town", State: "CA", Zip: "94000"}
-		bill := SnapshotAddress{Street: "2 Main", City: "Town", State: "CA", Zip: "94000"}
-		o := NewOrder(randID(), cust, ship, bill)
-		o.AddItem("A", 1, 1234, "USD", SnapshotItemFlags{})
-		o.AddItem("B", 2, 555, "USD", SnapshotItemFlags{Backorder: true})
+	for _, w := range workload.New(workload.Default()).Orders(n) {
+		cust := SnapshotCustomer{
+			Name:    SnapshotName{First: w.Customer.First, Last: w.Customer.Last},
+			Email:   w.Customer.Email,
+			Phone:   w.Customer.Phone,
+			Loyalty: SnapshotLoyalty{Tier: w.Customer.LoyaltyTier, Points: w.Customer.LoyaltyPoints},
+		}
+		o := NewOrder(c, w.ID, cust, SnapshotAddress(w.Shipping), SnapshotAddress(w.Billing))
+		for _, it := range w.Items {
+			if err := o.AddItem(it.SKU, it.Quantity, it.PriceCents, it.Currency, SnapshotItemFlags{Backorder: it.Backorder, Digital: it.Digital}); err != nil {
+				panic(err)
+			}
+		}
 		orders = append(orders, o)
 	}
 	return orders
@@ -41,11 +41,11 @@ func roundTripEncap(o *Order) *Order {
 }
 
 func BenchmarkEncap_RoundTrip_NoJSON(b *testing.B) {
-	restore := clock.UseMonotonicFake(time.Unix(0, 0), time.Nanosecond)
-	defer restore()
-	orders := seedEncapOrders(1024)
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	orders := seedEncapOrders(clk, 1024)
 	b.ReportAllocs()
 	b.ResetTimer()
+	benchgc.Track(b)
 	for i := 0; i < b.N; i++ {
 		res := roundTripEncap(orders[i%len(orders)])
 		sinkEncap = res
@@ -0,0 +1,208 @@
+package encap
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/ordergen"
+)
+
+const propertyRuns = 500
+
+func fromGen(g ordergen.Order) *Order {
+	s := Snapshot{
+		ID: g.ID,
+		Customer: SnapshotCustomer{
+			Name:    SnapshotName{First: g.First, Last: g.Last},
+			Email:   g.Email,
+			Phone:   g.Phone,
+			Loyalty: SnapshotLoyalty{Tier: g.LoyaltyTier, Points: g.LoyaltyPoints},
+		},
+		Shipping:  SnapshotAddress(g.Shipping),
+		Billing:   SnapshotAddress(g.Billing),
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+	}
+	for _, it := range g.Items {
+		s.Items = append(s.Items, SnapshotLineItem{SKU: it.SKU, Quantity: it.Quantity, Price: SnapshotMoney{Cents: it.PriceCents, Currency: it.Currency}, Flags: SnapshotItemFlags{Backorder: it.Backorder, Digital: it.Digital}})
+	}
+	return FromSnapshot(s)
+}
+
+func toGen(o *Order) ordergen.Order {
+	s := o.ToSnapshot()
+	g := ordergen.Order{
+		ID:            s.ID,
+		First:         s.Customer.Name.First,
+		Last:          s.Customer.Name.Last,
+		Email:         s.Customer.Email,
+		Phone:         s.Customer.Phone,
+		LoyaltyTier:   s.Customer.Loyalty.Tier,
+		LoyaltyPoints: s.Customer.Loyalty.Points,
+		Shipping:      ordergen.Address(s.Shipping),
+		Billing:       ordergen.Address(s.Billing),
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+	}
+	for _, it := range s.Items {
+		g.Items = append(g.Items, ordergen.Item{SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.Price.Cents, Currency: it.Price.Currency, Backorder: it.Flags.Backorder, Digital: it.Flags.Digital})
+	}
+	return g
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 34))
+	for i := 0; i < propertyRuns; i++ {
+		g := ordergen.New(r)
+		got := toGen(FromSnapshot(fromGen(g).ToSnapshot()))
+		if d := ordergen.Diff(g, got); d != "" {
+			t.Fatalf("order %d: %s", i, d)
+		}
+	}
+}
+
+func TestPersistenceRecord_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(2, 34))
+	for i := 0; i < propertyRuns; i++ {
+		g := ordergen.New(r)
+		got := toGen(FromSnapshot(fromPersistenceRecord(toPersistenceRecord(fromGen(g).ToSnapshot()))))
+		if d := ordergen.Diff(g, got); d != "" {
+			t.Fatalf("order %d: %s", i, d)
+		}
+	}
+}
+
+func TestRepo_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 34))
+	repo := NewRepo()
+	for i := 0; i < propertyRuns; i++ {
+		g := ordergen.New(r)
+		if err := repo.Save(fromGen(g)); err != nil {
+			t.Fatalf("order %d: Save: %v", i, err)
+		}
+		o, err := repo.FindByID(g.ID)
+		if err != nil {
+			t.Fatalf("order %d: FindByID: %v", i, err)
+		}
+		if d := ordergen.Diff(g, toGen(o)); d != "" {
+			t.Fatalf("order %d: %s", i, d)
+		}
+	}
+}
+
+// Loading order after order into one destination gives each order as it
+// was saved, whatever the destination held before, and reuses its item
+// array when the next order fits.
+func TestRepo_LoadInto(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 34))
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := NewRepo(WithClock(clk))
+	gens := make([]ordergen.Order, 100)
+	for i := range gens {
+		gens[i] = ordergen.New(r)
+		gens[i].ID = "order-" + strconv.Itoa(i) // generated IDs may repeat
+		if err := repo.Save(fromGen(gens[i])); err != nil {
+			t.Fatalf("order %d: Save: %v", i, err)
+		}
+	}
+	var dst Order
+	reused := 0
+	for i, g := range gens {
+		var prev *lineItem
+		if len(g.Items) > 0 && cap(dst.items) >= len(g.Items) {
+			prev = &dst.items[:1][0]
+		}
+		if err := repo.LoadInto(g.ID, &dst); err != nil {
+			t.Fatalf("order %d: LoadInto: %v", i, err)
+		}
+		if d := ordergen.Diff(g, toGen(&dst)); d != "" {
+			t.Fatalf("order %d: %s", i, d)
+		}
+		if dst.clock != clk {
+			t.Fatalf("order %d: clock not set", i)
+		}
+		if prev != nil {
+			if &dst.items[0] != prev {
+				t.Fatalf("order %d: items reallocated though %d fit in %d", i, len(g.Items), cap(dst.items))
+			}
+			reused++
+		}
+	}
+	if reused == 0 {
+		t.Fatal("no load reused the item array")
+	}
+
+	before := toGen(&dst)
+	if err := repo.LoadInto("missing", &dst); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Fatalf("LoadInto(missing) = %v, want ErrNotFound", err)
+	}
+	if d := ordergen.Diff(before, toGen(&dst)); d != "" {
+		t.Fatalf("failed load changed dst: %s", d)
+	}
+}
+
+// Concurrent saves and loads through one pooled repository each see only
+// their own order, so no pooled value is shared between calls.
+func TestRepo_PooledConcurrent(t *testing.T) {
+	repo := NewRepo(WithPooling())
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(6, uint64(w)))
+			for i := 0; i < 50; i++ {
+				g := ordergen.New(r)
+				g.ID = strconv.Itoa(w) + "/" + strconv.Itoa(i)
+				if err := repo.Save(fromGen(g)); err != nil {
+					t.Errorf("worker %d, order %d: Save: %v", w, i, err)
+					return
+				}
+				o, err := repo.FindByID(g.ID)
+				if err != nil {
+					t.Errorf("worker %d, order %d: FindByID: %v", w, i, err)
+					return
+				}
+				if d := ordergen.Diff(g, toGen(o)); d != "" {
+					t.Errorf("worker %d, order %d: %s", w, i, d)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Pooled values go back cleared, and ones holding more than maxPooledItems
+// items are dropped with their contents untouched.
+func TestRelease_ClearsAndCapsPooledValues(t *testing.T) {
+	rec := &persistenceRecord{Header: OrderHeader{ID: "o1"}, Items: make([]OrderItemRow, 2, 4)}
+	rec.Items[1].SKU = "sku"
+	s := &Snapshot{ID: "o1", Items: make([]SnapshotLineItem, 2, 4)}
+	s.Items[1].SKU = "sku"
+	releaseRecord(rec)
+	releaseSnapshot(s)
+	if rec.Header.ID != "" || len(rec.Items) != 0 || cap(rec.Items) != 4 || rec.Items[:2][1].SKU != "" {
+		t.Errorf("released record = %+v, want cleared with its item array kept", rec)
+	}
+	if s.ID != "" || len(s.Items) != 0 || cap(s.Items) != 4 || s.Items[:2][1].SKU != "" {
+		t.Errorf("released snapshot = %+v, want cleared with its item array kept", s)
+	}
+
+	big := &persistenceRecord{Header: OrderHeader{ID: "big"}, Items: make([]OrderItemRow, maxPooledItems+1)}
+	releaseRecord(big)
+	if big.Header.ID != "big" || len(big.Items) != maxPooledItems+1 {
+		t.Error("oversized record was cleared for the pool, want it dropped")
+	}
+	bigSnap := &Snapshot{ID: "big", Items: make([]SnapshotLineItem, maxPooledItems+1)}
+	releaseSnapshot(bigSnap)
+	if bigSnap.ID != "big" {
+		t.Error("oversized snapshot was cleared for the pool, want it dropped")
+	}
+}
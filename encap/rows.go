@@ -0,0 +1,124 @@
+package encap
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/table"
+)
+
+// itemPK is the order_items primary key.
+type itemPK struct {
+	orderID string
+	line    int // see PartialRepo
+}
+
+func compareItemPK(a, b itemPK) int {
+	if c := strings.Compare(a.orderID, b.orderID); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.line, b.line)
+}
+
+// RowRepo persists orders as typed rows in a table.DB: one orders row plus one
+// order_items row per line item, found through a secondary index on
+// OrderItemRow.OrderID. Like PartialRepo it relies on the aggregate's change
+// tracking to write only changed rows, keyed by line ID, all in a single
+// transaction.
+type RowRepo struct {
+	db      *table.DB
+	headers *table.Table[string, OrderHeader]
+	items   *table.Table[itemPK, OrderItemRow]
+	byOrder *table.Index[itemPK, OrderItemRow]
+	clock   clock.Clock // given to loaded orders
+}
+
+// NewRowRepo returns a repository whose loaded orders stamp their changes
+// with c, or with the real clock if c is nil.
+func NewRowRepo(db *table.DB, c clock.Clock) *RowRepo {
+	r := &RowRepo{
+		db:      db,
+		clock:   c,
+		headers: table.NewTable[string, OrderHeader](db, headerTable, strings.Compare),
+		items:   table.NewTable[itemPK, OrderItemRow](db, itemTable, compareItemPK),
+	}
+	r.byOrder = r.items.AddIndex("order_items_order_id", func(row *OrderItemRow) string { return row.OrderID })
+	return r
+}
+
+func (r *RowRepo) Save(o *Order) error {
+	s := o.ToSnapshot()
+	tracked := o.owner == r
+	err := r.db.Update(func(tx *table.Tx) error {
+		if !tracked || o.headerDirty {
+			r.headers.Put(tx, s.ID, toOrderHeader(s))
+		}
+		for i, it := range s.Items {
+			if tracked && !o.items[i].dirty {
+				continue
+			}
+			r.items.Put(tx, itemPK{orderID: s.ID, line: o.items[i].line}, toOrderItemRow(s.ID, it))
+		}
+		if tracked {
+			for _, line := range o.removedLines {
+				r.items.Delete(tx, itemPK{orderID: s.ID, line: line})
+			}
+			return nil
+		}
+		// Unknown stored state; remove rows the stored version has and o lacks.
+		lines := o.lines()
+		for _, k := range r.byOrder.AppendKeys(tx, nil, s.ID) {
+			if _, found := slices.BinarySearch(lines, k.line); !found {
+				r.items.Delete(tx, k)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	o.markPersisted(r)
+	return nil
+}
+
+func (r *RowRepo) FindByID(id string) (*Order, error) {
+	var rec persistenceRecord
+	var keys []itemPK
+	err := r.db.View(func(tx *table.Tx) error {
+		h, ok := r.headers.Get(tx, id)
+		if !ok {
+			return errors.New("not found")
+		}
+		rec.Header = h
+		rec.Items = r.byOrder.AppendRows(tx, nil, id)
+		keys = r.byOrder.AppendKeys(tx, nil, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := FromSnapshot(fromPersistenceRecord(rec))
+	o.clock = r.clock
+	lines := make([]int, len(keys))
+	for i, k := range keys {
+		lines[i] = k.line
+	}
+	o.setLines(lines)
+	o.markPersisted(r)
+	return o, nil
+}
+
+// DataUnsafeForBench returns a copy of the keys to iterate in benchmarks.
+func (r *RowRepo) DataUnsafeForBench() map[string]struct{} {
+	ids := make(map[string]struct{})
+	_ = r.db.View(func(tx *table.Tx) error {
+		for _, k := range r.headers.Keys(tx) {
+			ids[k] = struct{}{}
+		}
+		return nil
+	})
+	return ids
+}
@@ -0,0 +1,100 @@
+package encap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/schema"
+	"github.com/alechenninger/go-ddd-bench/internal/stagebench"
+)
+
+// BenchmarkEncap_RMW_Stages runs Repo.FindByID and Repo.Save with a small
+// change in between, one transformation per stage, and reports each stage's
+// share of the cycle. The pipeline copies the repository's; a check before
+// timing makes sure both write the same blob.
+func BenchmarkEncap_RMW_Stages(b *testing.B) {
+	clk := clock.NewMonotonicFake(time.Unix(0, 0), time.Nanosecond)
+	repo := NewRepo(WithClock(clk))
+	var ids []string
+	for _, o := range seedEncapOrders(clk, 1000) {
+		if err := repo.Save(o); err != nil {
+			b.Fatal(err)
+		}
+		ids = append(ids, o.id)
+	}
+
+	var (
+		id   string
+		blob []byte
+		data []byte
+		rec  persistenceRecord
+		snap Snapshot
+		o    *Order
+	)
+	stages := []stagebench.Stage{
+		{Name: "get", Run: func(i int) (err error) {
+			id = ids[i%len(ids)]
+			blob, err = repo.store.Get(id)
+			return err
+		}},
+		{Name: "upgrade", Run: func(int) (err error) {
+			data, _, err = versions.Upgrade(blob)
+			return err
+		}},
+		{Name: "decode", Run: func(int) error {
+			rec = persistenceRecord{}
+			return json.Unmarshal(data, &rec)
+		}},
+		{Name: "from-record", Run: func(int) error {
+			snap = fromPersistenceRecord(rec)
+			return nil
+		}},
+		{Name: "from-snapshot", Run: func(int) error {
+			o = FromSnapshot(snap)
+			o.clock = repo.clock
+			return nil
+		}},
+		{Name: "modify", Run: func(i int) error {
+			if err := o.RemoveItem("STAGE-TOGGLE"); errors.Is(err, ErrItemNotFound) {
+				if err := o.AddItem("STAGE-TOGGLE", 1, 99, "USD", SnapshotItemFlags{Digital: true}); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+			o.UpdateLoyaltyPoints(i)
+			return nil
+		}},
+		{Name: "to-snapshot", Run: func(int) error {
+			snap = o.ToSnapshot()
+			return nil
+		}},
+		{Name: "to-record", Run: func(int) error {
+			rec = toPersistenceRecord(snap)
+			return nil
+		}},
+		{Name: "encode", Run: func(int) (err error) {
+			blob, err = json.Marshal(schema.Envelope[persistenceRecord]{V: versions.Current(), Data: rec})
+			return err
+		}},
+		{Name: "put", Run: func(int) error { return repo.store.Put(id, blob) }},
+	}
+
+	for _, st := range stages {
+		if err := st.Run(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := repo.Save(o); err != nil {
+		b.Fatal(err)
+	}
+	if saved, _ := repo.store.Get(id); !bytes.Equal(saved, blob) {
+		b.Fatalf("staged pipeline wrote\n%s\nbut Save wrote\n%s", blob, saved)
+	}
+	stagebench.Run(b, stages...)
+	sinkEncap = o
+}
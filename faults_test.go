@@ -0,0 +1,335 @@
+package bench
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+)
+
+// These tests pin down how each variant's FindByID reacts to damaged or
+// drifted blobs: whether it fails cleanly or silently returns zeroed fields.
+
+const faultOrderID = "order-1"
+
+var faultCreatedAt = time.Unix(1_700_000_000, 123)
+
+// orderView is the part of an order the fault and schema tests inspect,
+// extracted the same way from every variant.
+type orderView struct {
+	ID               string
+	Email, Phone     string
+	Points           int
+	Street1, Street2 string // shipping
+	SKUs             []string
+	CreatedAtZero    bool // the variant reports no creation time
+}
+
+// intactView is the fixture order saved by every blobVariant.
+var intactView = orderView{ID: faultOrderID, Email: "ada@example.com", Phone: "555-0100", Points: 100, Street1: "12 Main St", Street2: "Apt 4", SKUs: []string{"A", "B"}}
+
+// blobRepo is a variant's blob-backed repository seen through orderView.
+type blobRepo struct {
+	load func(id string) (orderView, error)
+	// resave loads the order and saves it unchanged.
+	resave func(id string) error
+}
+
+type blobVariant struct {
+	name string
+	// Member paths in the variant's stored data, below the schema envelope.
+	idPath, emailPath, pointsPath, createdAtPath, itemsPath string
+	// streetPaths are the version 1 street members, each split into
+	// <path>1 and <path>2 at version 2; phonePath was added at version 3.
+	streetPaths []string
+	phonePath   string
+	// open saves the fixture order, and a second order with ID otherOrderID,
+	// into a repo over s.
+	open func(t *testing.T, s blobstore.Store, lazy bool) blobRepo
+}
+
+const otherOrderID = "order-2"
+
+var blobVariants = []blobVariant{
+	{
+		name:   "direct",
+		idPath: "ID", emailPath: "Customer.Email", pointsPath: "Customer.Loyalty.Points",
+		createdAtPath: "CreatedAt", itemsPath: "Items",
+		streetPaths: []string{"Shipping.Street", "Billing.Street"}, phonePath: "Customer.Phone",
+		open: func(t *testing.T, s blobstore.Store, lazy bool) blobRepo {
+			opts := []direct.Option{direct.WithStore(s)}
+			if lazy {
+				opts = append(opts, direct.WithLazyRewrite())
+			}
+			repo := direct.NewDirectRepo(opts...)
+			for _, id := range []string{faultOrderID, otherOrderID} {
+				o := &direct.Order{
+					ID:        id,
+					Customer:  direct.Customer{Name: direct.Name{First: "Ada", Last: "Lovelace"}, Email: "ada@example.com", Phone: "555-0100", Loyalty: direct.Loyalty{Tier: "gold", Points: 100}},
+					Shipping:  direct.Address{Street1: "12 Main St", Street2: "Apt 4", City: "Town", State: "CA", Zip: "94000"},
+					CreatedAt: faultCreatedAt,
+					UpdatedAt: faultCreatedAt,
+				}
+				o.Items = []direct.LineItem{{SKU: "A", Quantity: 1, Price: direct.Money{Cents: 1234, Currency: "USD"}}, {SKU: "B", Quantity: 2, Price: direct.Money{Cents: 555, Currency: "USD"}}}
+				if err := repo.Save(o); err != nil {
+					t.Fatal(err)
+				}
+			}
+			load := func(id string) (orderView, error) {
+				o, err := repo.FindByID(id)
+				if err != nil {
+					return orderView{}, err
+				}
+				v := orderView{ID: o.ID, Email: o.Customer.Email, Phone: o.Customer.Phone, Points: o.Customer.Loyalty.Points, Street1: o.Shipping.Street1, Street2: o.Shipping.Street2, CreatedAtZero: o.CreatedAt.IsZero()}
+				for _, it := range o.Items {
+					v.SKUs = append(v.SKUs, it.SKU)
+				}
+				return v, nil
+			}
+			resave := func(id string) error {
+				o, err := repo.FindByID(id)
+				if err != nil {
+					return err
+				}
+				return repo.Save(o)
+			}
+			return blobRepo{load: load, resave: resave}
+		},
+	},
+	{
+		name:   "encap",
+		idPath: "Header.ID", emailPath: "Header.CustomerEmail", pointsPath: "Header.LoyaltyPoints",
+		createdAtPath: "Header.CreatedAt", itemsPath: "Items",
+		streetPaths: []string{"Header.Street", "Header.BillStreet"}, phonePath: "Header.CustomerPhone",
+		open: func(t *testing.T, s blobstore.Store, lazy bool) blobRepo {
+			opts := []encap.Option{encap.WithStore(s)}
+			if lazy {
+				opts = append(opts, encap.WithLazyRewrite())
+			}
+			repo := encap.NewRepo(opts...)
+			for _, id := range []string{faultOrderID, otherOrderID} {
+				o := encap.FromSnapshot(encap.Snapshot{
+					ID:        id,
+					Customer:  encap.SnapshotCustomer{Name: encap.SnapshotName{First: "Ada", Last: "Lovelace"}, Email: "ada@example.com", Phone: "555-0100", Loyalty: encap.SnapshotLoyalty{Tier: "gold", Points: 100}},
+					Shipping:  encap.SnapshotAddress{Street1: "12 Main St", Street2: "Apt 4", City: "Town", State: "CA", Zip: "94000"},
+					Items:     []encap.SnapshotLineItem{{SKU: "A", Quantity: 1, Price: encap.SnapshotMoney{Cents: 1234, Currency: "USD"}}, {SKU: "B", Quantity: 2, Price: encap.SnapshotMoney{Cents: 555, Currency: "USD"}}},
+					CreatedAt: faultCreatedAt,
+					UpdatedAt: faultCreatedAt,
+				})
+				if err := repo.Save(o); err != nil {
+					t.Fatal(err)
+				}
+			}
+			load := func(id string) (orderView, error) {
+				o, err := repo.FindByID(id)
+				if err != nil {
+					return orderView{}, err
+				}
+				s := o.ToSnapshot()
+				v := orderView{ID: s.ID, Email: s.Customer.Email, Phone: s.Customer.Phone, Points: s.Customer.Loyalty.Points, Street1: s.Shipping.Street1, Street2: s.Shipping.Street2, CreatedAtZero: s.CreatedAt.IsZero()}
+				for _, it := range s.Items {
+					v.SKUs = append(v.SKUs, it.SKU)
+				}
+				return v, nil
+			}
+			resave := func(id string) error {
+				o, err := repo.FindByID(id)
+				if err != nil {
+					return err
+				}
+				return repo.Save(o)
+			}
+			return blobRepo{load: load, resave: resave}
+		},
+	},
+	{
+		name:   "directflat",
+		idPath: "Header.ID", emailPath: "Header.CustomerEmail", pointsPath: "Header.LoyaltyPoints",
+		createdAtPath: "Header.CreatedAt", itemsPath: "Items",
+		streetPaths: []string{"Header.Street", "Header.BillStreet"}, phonePath: "Header.CustomerPhone",
+		open: func(t *testing.T, s blobstore.Store, lazy bool) blobRepo {
+			opts := []directflat.Option{directflat.WithStore(s)}
+			if lazy {
+				opts = append(opts, directflat.WithLazyRewrite())
+			}
+			repo := directflat.NewRepo(opts...)
+			for _, id := range []string{faultOrderID, otherOrderID} {
+				rec := directflat.NewOrderRecord(nil, id, "Ada", "Lovelace", "ada@example.com", "gold", 100)
+				rec.UpdateShipping("12 Main St", "Apt 4", "Town", "CA", "94000")
+				if err := rec.AddItem("A", 1, 1234, "USD", false, false); err != nil {
+					t.Fatal(err)
+				}
+				if err := rec.AddItem("B", 2, 555, "USD", true, false); err != nil {
+					t.Fatal(err)
+				}
+				rec.Header.CustomerPhone = "555-0100"
+				rec.Header.CreatedAt = faultCreatedAt.UnixNano()
+				if err := repo.Save(rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			load := func(id string) (orderView, error) {
+				rec, err := repo.FindByID(id)
+				if err != nil {
+					return orderView{}, err
+				}
+				h := &rec.Header
+				v := orderView{ID: h.ID, Email: h.CustomerEmail, Phone: h.CustomerPhone, Points: h.LoyaltyPoints, Street1: h.Street1, Street2: h.Street2, CreatedAtZero: h.CreatedAt == 0}
+				for _, it := range rec.Items {
+					v.SKUs = append(v.SKUs, it.SKU)
+				}
+				return v, nil
+			}
+			resave := func(id string) error {
+				rec, err := repo.FindByID(id)
+				if err != nil {
+					return err
+				}
+				return repo.Save(rec)
+			}
+			return blobRepo{load: load, resave: resave}
+		},
+	},
+}
+
+func TestFindByID_Faults(t *testing.T) {
+	cases := []struct {
+		name    string
+		fault   func(v blobVariant) blobstore.Fault
+		wantErr bool
+		want    func(v orderView) orderView // expected view, derived from intactView
+	}{
+		{
+			name:    "truncated",
+			fault:   func(blobVariant) blobstore.Fault { return blobstore.Truncate(40) },
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			fault:   func(blobVariant) blobstore.Fault { return blobstore.Truncate(0) },
+			wantErr: true,
+		},
+		{
+			name:    "bit flip in syntax",
+			fault:   func(blobVariant) blobstore.Fault { return blobstore.FlipBit(0, 0) }, // '{' becomes 'z'
+			wantErr: true,
+		},
+		{
+			// Without a checksum a flipped bit inside a string decodes fine.
+			name:  "bit flip in value",
+			fault: func(blobVariant) blobstore.Fault { return blobstore.FlipBitIn("ada@") },
+			want:  func(v orderView) orderView { v.Email = "`da@example.com"; return v },
+		},
+		{
+			name:    "wrong type",
+			fault:   func(fv blobVariant) blobstore.Fault { return blobstore.SetField("data."+fv.pointsPath, "lots") },
+			wantErr: true,
+		},
+		{
+			name:  "missing ID",
+			fault: func(fv blobVariant) blobstore.Fault { return blobstore.DropField("data." + fv.idPath) },
+			want:  func(v orderView) orderView { v.ID = ""; return v },
+		},
+		{
+			name:  "missing CreatedAt",
+			fault: func(fv blobVariant) blobstore.Fault { return blobstore.DropField("data." + fv.createdAtPath) },
+			want:  func(v orderView) orderView { v.CreatedAtZero = true; return v },
+		},
+		{
+			name:  "missing Items",
+			fault: func(fv blobVariant) blobstore.Fault { return blobstore.DropField("data." + fv.itemsPath) },
+			want:  func(v orderView) orderView { v.SKUs = nil; return v },
+		},
+		{
+			name:  "missing item SKU",
+			fault: func(fv blobVariant) blobstore.Fault { return blobstore.DropField("data." + fv.itemsPath + ".*.SKU") },
+			want:  func(v orderView) orderView { v.SKUs = []string{"", ""}; return v },
+		},
+		{
+			name:  "unknown envelope field",
+			fault: func(blobVariant) blobstore.Fault { return blobstore.SetField("writer", "v9") },
+			want:  func(v orderView) orderView { return v },
+		},
+		{
+			name: "unknown nested field",
+			fault: func(fv blobVariant) blobstore.Fault {
+				return blobstore.SetField("data."+fv.emailPath+"Verified", true)
+			},
+			want: func(v orderView) orderView { return v },
+		},
+	}
+	for _, fv := range blobVariants {
+		for _, tc := range cases {
+			t.Run(fv.name+"/"+tc.name, func(t *testing.T) {
+				store := blobstore.NewFaulty(blobstore.NewMemory())
+				load := fv.open(t, store, false).load
+				store.Inject(faultOrderID, tc.fault(fv))
+
+				got, err := load(faultOrderID)
+				if tc.wantErr {
+					if err == nil {
+						t.Fatalf("FindByID succeeded with %+v, want error", got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("FindByID: %v", err)
+				}
+				if want := tc.want(intactView); !equalViews(got, want) {
+					t.Fatalf("FindByID = %+v, want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestFindByID_LogChecksum checks that the same in-value bit flip that the
+// in-memory store lets through is caught when it happens on disk.
+func TestFindByID_LogChecksum(t *testing.T) {
+	for _, fv := range blobVariants {
+		t.Run(fv.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "orders.log")
+			l, err := blobstore.OpenLog(path, blobstore.LogOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			load := fv.open(t, l, false).load
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = blobstore.FlipBitIn("ada@")(data)
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := load(faultOrderID); !errors.Is(err, blobstore.ErrCorrupt) {
+				t.Fatalf("FindByID err = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestFindByID_NotFound(t *testing.T) {
+	for _, fv := range blobVariants {
+		t.Run(fv.name, func(t *testing.T) {
+			load := fv.open(t, blobstore.NewMemory(), false).load
+			if _, err := load("missing"); !errors.Is(err, blobstore.ErrNotFound) {
+				t.Fatalf("FindByID err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func equalViews(a, b orderView) bool {
+	return a.ID == b.ID && a.Email == b.Email && a.Phone == b.Phone && a.Points == b.Points &&
+		a.Street1 == b.Street1 && a.Street2 == b.Street2 && a.CreatedAtZero == b.CreatedAtZero && slices.Equal(a.SKUs, b.SKUs)
+}
@@ -0,0 +1,45 @@
+// Package allocbudget fails a test when an operation makes more heap
+// allocations than its budget, so a mapping that starts allocating more is
+// caught by go test rather than only moving a benchmark number. Each package
+// keeps its own table of budgets and calls Check:
+//
+//	func TestAllocBudgets(t *testing.T) {
+//		allocbudget.Check(t, []allocbudget.Budget{
+//			{Name: "toRecord", Allocs: 1, Run: func() { sinkRecord = toRecord(o) }},
+//		})
+//	}
+//
+// Operations should store their results in typed package-level sinks, as
+// above, so the compiler cannot drop the work; storing to an interface would
+// add an allocation of its own.
+//
+// Run the tests with -v to see every measured count next to its budget. Lower
+// a budget when a change saves allocations, and raise one only with a reason
+// in the commit that does it.
+package allocbudget
+
+import "testing"
+
+// Budget caps the allocations of one operation.
+type Budget struct {
+	Name   string
+	Allocs float64
+	Run    func()
+}
+
+// Check measures each budget's Run with testing.AllocsPerRun, failing t for
+// counts over budget and logging the rest.
+func Check(t testing.TB, budgets []Budget) {
+	t.Helper()
+	for _, b := range budgets {
+		got := testing.AllocsPerRun(100, b.Run)
+		switch {
+		case got > b.Allocs:
+			t.Errorf("%s: %v allocs, budget %v", b.Name, got, b.Allocs)
+		case got < b.Allocs:
+			t.Logf("%s: %v allocs, under budget %v; consider lowering it", b.Name, got, b.Allocs)
+		default:
+			t.Logf("%s: %v allocs", b.Name, got)
+		}
+	}
+}
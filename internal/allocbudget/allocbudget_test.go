@@ -0,0 +1,41 @@
+package allocbudget
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recorder captures Check's verdicts instead of failing the real test.
+type recorder struct {
+	*testing.T
+	errors, logs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+var sink []byte
+
+func TestCheck(t *testing.T) {
+	alloc := func() { sink = make([]byte, 64) }
+	r := &recorder{T: t}
+	Check(r, []Budget{
+		{Name: "over", Allocs: 0, Run: alloc},
+		{Name: "exact", Allocs: 1, Run: alloc},
+		{Name: "under", Allocs: 2, Run: alloc},
+	})
+	if len(r.errors) != 1 || r.errors[0] != "over: 1 allocs, budget 0" {
+		t.Errorf("errors = %q, want only the over-budget operation", r.errors)
+	}
+	want := []string{"exact: 1 allocs", "under: 1 allocs, under budget 2; consider lowering it"}
+	if fmt.Sprint(r.logs) != fmt.Sprint(want) {
+		t.Errorf("logs = %q, want %q", r.logs, want)
+	}
+}
@@ -0,0 +1,132 @@
+// Package benchgc reports what a benchmark costs the garbage collector, which
+// B/op and allocs/op only hint at. Call Track right after b.ResetTimer:
+//
+//	b.ResetTimer()
+//	b.ReportAllocs()
+//	benchgc.Track(b)
+//	for i := 0; i < b.N; i++ { ... }
+//
+// Track reads runtime/metrics then and again when the benchmark function
+// returns, and reports the difference as the metrics in Units. The peak heap
+// is only reported with the -benchgc.peak flag, as it is found by sampling
+// from a goroutine that wakes every SampleEvery and so disturbs the
+// benchmark it measures:
+//
+//	go test -run '^$' -bench . -benchgc.peak
+package benchgc
+
+import (
+	"flag"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The units Track reports.
+const (
+	// CyclesPerOp is completed GC cycles per iteration.
+	CyclesPerOp = "gc-cycles/op"
+	// CPUFraction is the share of available CPU time the GC used, including
+	// assists by allocating goroutines. The runtime updates its CPU estimates
+	// at the end of each cycle, so runs with no cycle report 0.
+	CPUFraction = "gc-cpu-frac"
+	// PauseNSPerOp is stop-the-world GC pause time per iteration, estimated
+	// from the runtime's pause histogram to within about 6%.
+	PauseNSPerOp = "gc-pause-ns/op"
+	// PeakHeapBytes is the largest heap, in bytes of objects live or not yet
+	// swept, seen by sampling every SampleEvery during the run. It is only
+	// reported with -benchgc.peak.
+	PeakHeapBytes = "peak-heap-B"
+)
+
+// Units lists the metrics Track reports, for tools that parse results.
+var Units = []string{CyclesPerOp, CPUFraction, PauseNSPerOp, PeakHeapBytes}
+
+// SampleEvery is how often Track samples the heap size.
+const SampleEvery = time.Millisecond
+
+var peakFlag = flag.Bool("benchgc.peak", false, "sample the heap every "+SampleEvery.String()+" and report "+PeakHeapBytes)
+
+const (
+	cycles  = "/gc/cycles/total:gc-cycles"
+	gcCPU   = "/cpu/classes/gc/total:cpu-seconds"
+	allCPU  = "/cpu/classes/total:cpu-seconds"
+	pauses  = "/sched/pauses/total/gc:seconds"
+	heapObj = "/memory/classes/heap/objects:bytes"
+)
+
+func read() []metrics.Sample {
+	s := []metrics.Sample{{Name: cycles}, {Name: gcCPU}, {Name: allCPU}, {Name: pauses}, {Name: heapObj}}
+	metrics.Read(s)
+	return s
+}
+
+// Track starts measuring b and reports the results when the benchmark
+// function returns. Benchmarks that call b.Run should call it in the
+// sub-benchmarks instead.
+func Track(b *testing.B) {
+	before := read()
+	peak := before[4].Value.Uint64()
+	var (
+		done     = make(chan struct{})
+		wg       sync.WaitGroup
+		sampling = *peakFlag
+	)
+	if sampling {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := []metrics.Sample{{Name: heapObj}}
+			t := time.NewTicker(SampleEvery)
+			defer t.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-t.C:
+					metrics.Read(s)
+					peak = max(peak, s[0].Value.Uint64()) // read after wg.Wait
+				}
+			}
+		}()
+	}
+	b.Cleanup(func() {
+		close(done)
+		wg.Wait()
+		after := read()
+		n := float64(max(b.N, 1))
+		b.ReportMetric(float64(after[0].Value.Uint64()-before[0].Value.Uint64())/n, CyclesPerOp)
+		frac := 0.0
+		if total := after[2].Value.Float64() - before[2].Value.Float64(); total > 0 {
+			frac = (after[1].Value.Float64() - before[1].Value.Float64()) / total
+		}
+		b.ReportMetric(frac, CPUFraction)
+		pause := pauseSeconds(after[3].Value.Float64Histogram()) - pauseSeconds(before[3].Value.Float64Histogram())
+		b.ReportMetric(pause*1e9/n, PauseNSPerOp)
+		if sampling {
+			b.ReportMetric(float64(max(peak, after[4].Value.Uint64())), PeakHeapBytes)
+		}
+	})
+}
+
+// pauseSeconds estimates the total of the pauses in h from the midpoints of
+// its buckets.
+func pauseSeconds(h *metrics.Float64Histogram) float64 {
+	total := 0.0
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		lo, hi := h.Buckets[i], h.Buckets[i+1]
+		switch {
+		case math.IsInf(lo, -1):
+			lo = 0
+		case math.IsInf(hi, 1):
+			hi = lo
+		}
+		total += float64(c) * (lo + hi) / 2
+	}
+	return total
+}
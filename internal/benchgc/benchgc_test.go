@@ -0,0 +1,60 @@
+package benchgc
+
+import (
+	"testing"
+)
+
+var sink []byte
+
+func TestTrack(t *testing.T) {
+	defer func(v bool) { *peakFlag = v }(*peakFlag)
+	*peakFlag = true
+	res := testing.Benchmark(func(b *testing.B) {
+		Track(b)
+		for i := 0; i < b.N; i++ {
+			sink = make([]byte, 64<<10)
+		}
+	})
+	for _, unit := range Units {
+		if _, ok := res.Extra[unit]; !ok {
+			t.Errorf("%s not reported: %v", unit, res.Extra)
+		}
+	}
+	if res.Extra[CyclesPerOp] <= 0 || res.Extra[PauseNSPerOp] <= 0 {
+		t.Errorf("allocating 64KB per op caused no GC: %v", res.Extra)
+	}
+	if f := res.Extra[CPUFraction]; f <= 0 || f >= 1 {
+		t.Errorf("%s = %v, want a fraction", CPUFraction, f)
+	}
+	if res.Extra[PeakHeapBytes] < 64<<10 {
+		t.Errorf("%s = %v, want at least one live buffer", PeakHeapBytes, res.Extra[PeakHeapBytes])
+	}
+}
+
+func TestTrack_NoAllocs(t *testing.T) {
+	res := testing.Benchmark(func(b *testing.B) {
+		Track(b)
+		for i := 0; i < b.N; i++ {
+		}
+	})
+	if c := res.Extra[CyclesPerOp]; c != 0 {
+		t.Errorf("%s = %v for a loop that does not allocate", CyclesPerOp, c)
+	}
+}
+
+func TestTrack_PeakOnlyWithFlag(t *testing.T) {
+	defer func(v bool) { *peakFlag = v }(*peakFlag)
+	*peakFlag = false
+	res := testing.Benchmark(func(b *testing.B) {
+		Track(b)
+		for i := 0; i < b.N; i++ {
+			sink = make([]byte, 64<<10)
+		}
+	})
+	if _, ok := res.Extra[PeakHeapBytes]; ok {
+		t.Errorf("%s reported without -benchgc.peak: %v", PeakHeapBytes, res.Extra)
+	}
+	if _, ok := res.Extra[CyclesPerOp]; !ok {
+		t.Errorf("%s not reported: %v", CyclesPerOp, res.Extra)
+	}
+}
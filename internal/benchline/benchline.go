@@ -0,0 +1,106 @@
+// Package benchline reads go test benchmark output.
+package benchline
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	benchLinePrefix = "Benchmark"
+	valueBeforeUnit = regexp.MustCompile(`([0-9]+\.?[0-9]*)\s+(ns/op|B/op|allocs/op)`) // captures value and unit
+)
+
+// Parse reads the name, ns/op, B/op and allocs/op of a go test benchmark
+// result line. Lines that are not results report false.
+func Parse(line string) (name string, ns, bytes, allocs float64, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, benchLinePrefix) {
+		return "", 0, 0, 0, false
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return "", 0, 0, 0, false
+	}
+	name = fields[0]
+	// Find value preceding units using regex to be robust to spacing
+	matches := valueBeforeUnit.FindAllStringSubmatch(line, -1)
+	// Expect three metrics per line
+	for _, m := range matches {
+		if len(m) != 3 {
+			continue
+		}
+		valStr := m[1]
+		unit := m[2]
+		v, err := strconv.ParseFloat(valStr, 64)
+		if err != nil {
+			continue
+		}
+		switch unit {
+		case "ns/op":
+			ns = v
+		case "B/op":
+			bytes = v
+		case "allocs/op":
+			allocs = v
+		}
+	}
+	if ns == 0 && bytes == 0 && allocs == 0 {
+		return "", 0, 0, 0, false
+	}
+	return name, ns, bytes, allocs, true
+}
+
+// Metrics returns the values that line reports in any of units. Go test
+// prints each custom metric as a value followed by its unit.
+func Metrics(line string, units []string) map[string]float64 {
+	var vals map[string]float64
+	fields := strings.Fields(line)
+	for i := 1; i < len(fields); i++ {
+		for _, u := range units {
+			if fields[i] != u {
+				continue
+			}
+			v, err := strconv.ParseFloat(fields[i-1], 64)
+			if err != nil {
+				continue
+			}
+			if vals == nil {
+				vals = make(map[string]float64)
+			}
+			vals[u] = v
+		}
+	}
+	return vals
+}
+
+// Median returns the median of values, or 0 if there are none.
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	cp := make([]float64, len(values))
+	copy(cp, values)
+	sort.Float64s(cp)
+	n := len(cp)
+	if n%2 == 1 {
+		return cp[n/2]
+	}
+	return (cp[n/2-1] + cp[n/2]) / 2
+}
+
+// NsPerOp collects the ns/op of every benchmark result in r by name.
+func NsPerOp(r io.Reader) (map[string][]float64, error) {
+	ns := make(map[string][]float64)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if name, v, _, _, ok := Parse(s.Text()); ok {
+			ns[name] = append(ns[name], v)
+		}
+	}
+	return ns, s.Err()
+}
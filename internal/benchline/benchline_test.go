@@ -0,0 +1,92 @@
+package benchline
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// FuzzParse checks that Parse never panics, that what it
+// accepts is a plausible result, and that formatting an accepted result as a
+// go test line and parsing it again gives the same result.
+func FuzzParse(f *testing.F) {
+	files, err := filepath.Glob("../../bench_results_*.txt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		r, err := os.Open(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			f.Add(s.Text())
+		}
+		r.Close()
+		if err := s.Err(); err != nil {
+			f.Fatal(err)
+		}
+	}
+	f.Add("BenchmarkX-8 \t 10 \t 1.5 ns/op")
+	f.Fuzz(func(t *testing.T, line string) {
+		name, ns, bytes, allocs, ok := Parse(line)
+		if !ok {
+			return
+		}
+		if !strings.HasPrefix(name, benchLinePrefix) || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+			t.Fatalf("Parse(%q) name = %q", line, name)
+		}
+		for _, v := range []float64{ns, bytes, allocs} {
+			if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+				t.Fatalf("Parse(%q) = %v, %v, %v", line, ns, bytes, allocs)
+			}
+		}
+		formatted := name + "\t1\t" + format(ns) + " ns/op\t" + format(bytes) + " B/op\t" + format(allocs) + " allocs/op"
+		name2, ns2, bytes2, allocs2, ok := Parse(formatted)
+		if !ok || name2 != name || ns2 != ns || bytes2 != bytes || allocs2 != allocs {
+			t.Fatalf("Parse(%q) = %q, %v, %v, %v, %v; parsed from %q as %q, %v, %v, %v",
+				formatted, name2, ns2, bytes2, allocs2, ok, line, name, ns, bytes, allocs)
+		}
+	})
+}
+
+func format(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+func TestMetrics(t *testing.T) {
+	line := "BenchmarkX-8  100  5.5 ns/op  0.25 gc-cpu-frac  7 rows/op  oops gc-cycles/op  2 B/op"
+	got := Metrics(line, []string{"gc-cpu-frac", "rows/op", "gc-cycles/op", "disk-B/op"})
+	if len(got) != 2 || got["gc-cpu-frac"] != 0.25 || got["rows/op"] != 7 {
+		t.Fatalf("Metrics = %v", got)
+	}
+	if got := Metrics("BenchmarkX-8  100  5.5 ns/op", []string{"rows/op"}); got != nil {
+		t.Fatalf("Metrics of a line without the units = %v", got)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	for _, tt := range []struct {
+		in   []float64
+		want float64
+	}{{nil, 0}, {[]float64{3}, 3}, {[]float64{5, 1, 3}, 3}, {[]float64{4, 1, 3, 2}, 2.5}} {
+		if got := Median(tt.in); got != tt.want {
+			t.Errorf("Median(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNsPerOp(t *testing.T) {
+	out := "goos: linux\nBenchmarkA-8  10  300 ns/op  8 B/op  1 allocs/op\nBenchmarkA-8  10  200 ns/op  8 B/op  1 allocs/op\nBenchmarkB-8  10  50 ns/op  0 B/op  0 allocs/op\nPASS\n"
+	got, err := NsPerOp(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || len(got["BenchmarkA-8"]) != 2 || got["BenchmarkA-8"][1] != 200 || got["BenchmarkB-8"][0] != 50 {
+		t.Fatalf("NsPerOp = %v", got)
+	}
+}
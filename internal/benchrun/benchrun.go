@@ -0,0 +1,57 @@
+// Package benchrun builds a package's test binary once and runs its
+// benchmarks, so tools that compare runs under different settings all
+// execute the same code.
+package benchrun
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Binary is a compiled test binary.
+type Binary struct {
+	Path string
+	Dir  string // the package directory, where tests expect to run
+}
+
+// Dir returns the directory of the package pkg.
+func Dir(pkg string) (string, error) {
+	dir, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkg).Output()
+	if err != nil {
+		return "", fmt.Errorf("go list %s: %w", pkg, err)
+	}
+	return strings.TrimSpace(string(dir)), nil
+}
+
+// Build compiles the tests of pkg to out, passing buildFlags such as -pgo to
+// go test. The compiler's output goes to stderr.
+func Build(pkg, out string, buildFlags ...string) (*Binary, error) {
+	dir, err := Dir(pkg)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"test", "-c", "-o", out}, buildFlags...)
+	cmd := exec.Command("go", append(args, pkg)...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("building %s: %w", pkg, err)
+	}
+	return &Binary{Path: out, Dir: dir}, nil
+}
+
+// Bench runs benchmarks with tests disabled, writing their results to
+// stdout. args are test binary flags such as -test.bench; env, if not nil,
+// replaces the inherited environment.
+func (b *Binary) Bench(stdout io.Writer, env []string, args ...string) error {
+	cmd := exec.Command(b.Path, append([]string{"-test.run=^$"}, args...)...)
+	cmd.Dir = b.Dir
+	cmd.Env = env
+	cmd.Stdout, cmd.Stderr = stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %w", b.Path, strings.Join(args, " "), err)
+	}
+	return nil
+}
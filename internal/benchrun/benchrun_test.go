@@ -0,0 +1,51 @@
+package benchrun
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const tinyPkg = "./testdata/tiny"
+
+func TestDir(t *testing.T) {
+	dir, err := Dir(tinyPkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.Abs(tinyPkg); dir != want {
+		t.Fatalf("Dir = %q, want %q", dir, want)
+	}
+	if _, err := Dir("./testdata/missing"); err == nil {
+		t.Fatal("Dir of a missing package succeeded")
+	}
+}
+
+func TestBuildAndBench(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary")
+	}
+	bin, err := Build(tinyPkg, filepath.Join(t.TempDir(), "tiny.test"), "-pgo=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	env := append(os.Environ(), "BENCHRUN_MARK=set")
+	if err := bin.Bench(&out, env, "-test.bench=Tiny", "-test.benchtime=1x", "-test.v"); err != nil {
+		t.Fatalf("%v\n%s", err, &out)
+	}
+	for _, want := range []string{"BenchmarkTiny", "mark=set", "PASS"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, &out)
+		}
+	}
+	if strings.Contains(out.String(), "TestFails") {
+		t.Errorf("Bench ran tests:\n%s", &out)
+	}
+
+	if err := bin.Bench(&out, env, "-test.bench=Tiny", "-test.benchtime=1x", "-test.run=Fails"); err == nil {
+		t.Error("Bench succeeded although the binary failed")
+	}
+}
@@ -0,0 +1,22 @@
+package tiny
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFails shows whether Bench really disables tests.
+func TestFails(t *testing.T) { t.Fatal("tests should not run") }
+
+// BenchmarkTiny fails unless it runs in the package directory, and logs
+// BENCHRUN_MARK so the caller can see which environment it got.
+func BenchmarkTiny(b *testing.B) {
+	wd, err := os.Getwd()
+	if err != nil || filepath.Base(wd) != "tiny" {
+		b.Fatalf("running in %q, %v; want the package directory", wd, err)
+	}
+	b.Logf("mark=%s", os.Getenv("BENCHRUN_MARK"))
+	for i := 0; i < b.N; i++ {
+	}
+}
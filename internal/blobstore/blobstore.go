@@ -0,0 +1,81 @@
+// Package blobstore abstracts where the JSON repositories keep their encoded
+// aggregates. Memory is the default map-backed store; Log appends records to
+// a file on the local filesystem.
+package blobstore
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned by Get for keys that have no blob.
+var ErrNotFound = errors.New("not found")
+
+// Store holds one blob per key. Implementations are safe for concurrent use.
+// Callers must not modify a blob after passing it to Put or receiving it from
+// Get.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Put(key string, blob []byte) error
+	Delete(key string) error
+	Keys() []string
+
+	// CompareAndPut stores blob under key only if key currently holds a
+	// blob equal to old, and reports whether it did. A missing key never
+	// matches.
+	CompareAndPut(key string, old, blob []byte) (bool, error)
+}
+
+// Memory is an in-memory Store.
+type Memory struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemory() *Memory { return &Memory{data: make(map[string][]byte)} }
+
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.mu.RLock()
+	blob, ok := m.data[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return blob, nil
+}
+
+func (m *Memory) Put(key string, blob []byte) error {
+	m.mu.Lock()
+	m.data[key] = blob
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) CompareAndPut(key string, old, blob []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.data[key]
+	if !ok || !bytes.Equal(cur, old) {
+		return false, nil
+	}
+	m.data[key] = blob
+	return true, nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	delete(m.data, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.data))
+	for k := range m.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,30 @@
+package blobstore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareAndPut(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemory() },
+		"log":    func(t *testing.T) Store { return openTestLog(t, filepath.Join(t.TempDir(), "seg")) },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			if ok, err := s.CompareAndPut("a", nil, []byte("x")); ok || err != nil {
+				t.Fatalf("CompareAndPut(missing) = %v, %v; want false, nil", ok, err)
+			}
+			mustPut(t, s, "a", "1")
+			if ok, err := s.CompareAndPut("a", []byte("2"), []byte("3")); ok || err != nil {
+				t.Fatalf("CompareAndPut(mismatch) = %v, %v; want false, nil", ok, err)
+			}
+			wantBlob(t, s, "a", "1")
+			if ok, err := s.CompareAndPut("a", []byte("1"), []byte("3")); !ok || err != nil {
+				t.Fatalf("CompareAndPut(match) = %v, %v; want true, nil", ok, err)
+			}
+			wantBlob(t, s, "a", "3")
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package blobstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Fault rewrites a blob as it is read back, simulating storage corruption or
+// schema drift. It must not modify blob in place.
+type Fault func(blob []byte) []byte
+
+// Faulty wraps a Store and applies injected faults to blobs returned by Get.
+// Writes pass through unchanged, so a fault affects every read of a key until
+// it is cleared.
+type Faulty struct {
+	Store
+
+	mu     sync.RWMutex
+	faults map[string]Fault
+}
+
+func NewFaulty(s Store) *Faulty { return &Faulty{Store: s, faults: make(map[string]Fault)} }
+
+// Inject applies f to every subsequent read of key.
+func (s *Faulty) Inject(key string, f Fault) {
+	s.mu.Lock()
+	s.faults[key] = f
+	s.mu.Unlock()
+}
+
+// Clear removes all injected faults.
+func (s *Faulty) Clear() {
+	s.mu.Lock()
+	clear(s.faults)
+	s.mu.Unlock()
+}
+
+func (s *Faulty) Get(key string) ([]byte, error) {
+	blob, err := s.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	f := s.faults[key]
+	s.mu.RUnlock()
+	if f == nil {
+		return blob, nil
+	}
+	return f(blob), nil
+}
+
+// FlipBit flips one bit of the byte at offset; negative offsets count from
+// the end. Offsets outside the blob leave it unchanged.
+func FlipBit(offset int, bit uint) Fault {
+	return func(blob []byte) []byte {
+		i := offset
+		if i < 0 {
+			i += len(blob)
+		}
+		out := bytes.Clone(blob)
+		if i >= 0 && i < len(out) {
+			out[i] ^= 1 << (bit % 8)
+		}
+		return out
+	}
+}
+
+// FlipBitIn flips the low bit of the first byte of the first occurrence of
+// substr, corrupting a value without breaking the surrounding syntax.
+func FlipBitIn(substr string) Fault {
+	return func(blob []byte) []byte {
+		i := bytes.Index(blob, []byte(substr))
+		if i < 0 {
+			return blob
+		}
+		return FlipBit(i, 0)(blob)
+	}
+}
+
+// Truncate keeps only the first n bytes, as after a torn write.
+func Truncate(n int) Fault {
+	return func(blob []byte) []byte {
+		if n < len(blob) {
+			return bytes.Clone(blob[:n])
+		}
+		return blob
+	}
+}
+
+// DropField removes the JSON object member at path. Path elements are
+// separated by dots; "*" matches every element of an array.
+func DropField(path string) Fault {
+	return editJSON(path, func(obj map[string]any, name string) { delete(obj, name) })
+}
+
+// SetField sets the JSON object member at path to v, adding it if absent.
+// Setting a member the decoder does not know simulates a newer writer.
+func SetField(path string, v any) Fault {
+	return editJSON(path, func(obj map[string]any, name string) { obj[name] = v })
+}
+
+// editJSON decodes blob, applies edit to the object member at path and
+// re-encodes it. Blobs that are not JSON objects are returned unchanged.
+func editJSON(path string, edit func(obj map[string]any, name string)) Fault {
+	elems := strings.Split(path, ".")
+	return func(blob []byte) []byte {
+		dec := json.NewDecoder(bytes.NewReader(blob))
+		dec.UseNumber() // keep int64 nanos exact
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			return blob
+		}
+		walk(doc, elems, edit)
+		out, err := json.Marshal(doc)
+		if err != nil {
+			return blob
+		}
+		return out
+	}
+}
+
+func walk(v any, elems []string, edit func(obj map[string]any, name string)) {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(elems) == 1 {
+			edit(v, elems[0])
+			return
+		}
+		walk(v[elems[0]], elems[1:], edit)
+	case []any:
+		if elems[0] != "*" || len(elems) == 1 {
+			return
+		}
+		for _, e := range v {
+			walk(e, elems[1:], edit)
+		}
+	}
+}
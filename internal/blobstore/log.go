@@ -0,0 +1,397 @@
+package blobstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrCorrupt is returned when a record fails its checksum or is malformed.
+var ErrCorrupt = errors.New("corrupt record")
+
+// Record layout, little-endian:
+//
+//	crc32  uint32 // Castagnoli, over everything after this field
+//	kind   uint8  // recordPut or recordDelete
+//	keyLen uint32
+//	valLen uint32
+//	key    [keyLen]byte
+//	value  [valLen]byte
+const headerSize = 4 + 1 + 4 + 4
+
+const (
+	recordPut    byte = 1
+	recordDelete byte = 2
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// LogOptions configure a Log.
+type LogOptions struct {
+	// Sync fsyncs the segment after every write.
+	Sync bool
+}
+
+// Log is a Store that appends every write to a segment file and keeps an
+// in-memory index of the latest record offset per key. Overwritten and
+// deleted records stay in the file until Compact rewrites it with only the
+// live records.
+//
+// On Open the segment is scanned to rebuild the index. A torn record at the
+// tail, as left by a crash mid-append, is truncated away. A record that fails
+// its checksum or whose lengths run past the end of the segment is reported
+// as ErrCorrupt if an intact record follows it, since truncating there would
+// lose committed writes.
+type Log struct {
+	path string
+	opts LogOptions
+
+	mu    sync.RWMutex
+	f     *os.File
+	size  int64
+	index map[string]span
+	buf   []byte
+
+	written int64 // bytes appended since Open
+	garbage int64 // bytes held by superseded records
+}
+
+// span locates a record in the segment.
+type span struct {
+	off int64
+	n   int64
+}
+
+// OpenLog opens or creates the segment file at path.
+func OpenLog(path string, opts LogOptions) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{path: path, opts: opts, f: f}
+	if err := l.recover(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// recover rebuilds the index from the segment and truncates a torn tail.
+func (l *Log) recover() error {
+	info, err := l.f.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	l.index = make(map[string]span)
+	l.garbage = 0
+	var off int64
+	hdr := make([]byte, headerSize)
+	for off < end {
+		rec, err := l.readRecord(hdr, off, end)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			// A crash mid-append leaves an incomplete record only at the
+			// tail, so anything followed by an intact record is damage,
+			// including a corrupted length that points past the end.
+			intact, err := l.intactAfter(off, end)
+			if err != nil {
+				return err
+			}
+			if intact {
+				return fmt.Errorf("blobstore: %w at offset %d of %s", ErrCorrupt, off, l.path)
+			}
+			break // torn tail
+		}
+		kind, n := rec[4], int64(len(rec))
+		key := string(rec[headerSize : headerSize+binary.LittleEndian.Uint32(rec[5:])])
+		if old, ok := l.index[key]; ok {
+			l.garbage += old.n
+		}
+		if kind == recordDelete {
+			delete(l.index, key)
+			l.garbage += n
+		} else {
+			l.index[key] = span{off: off, n: n}
+		}
+		off += n
+	}
+	if off < end {
+		if err := l.f.Truncate(off); err != nil {
+			return err
+		}
+	}
+	l.size = off
+	return nil
+}
+
+// readRecord returns the record at off, or nil if it is incomplete, runs
+// past end or fails its checksum.
+func (l *Log) readRecord(hdr []byte, off, end int64) ([]byte, error) {
+	if end-off < headerSize {
+		return nil, nil
+	}
+	if _, err := l.f.ReadAt(hdr, off); err != nil {
+		return nil, err
+	}
+	n, ok := recordSize(hdr)
+	if !ok || off+n > end {
+		return nil, nil
+	}
+	rec := make([]byte, n)
+	if _, err := l.f.ReadAt(rec, off); err != nil {
+		return nil, err
+	}
+	if !validRecord(rec) {
+		return nil, nil
+	}
+	return rec, nil
+}
+
+// intactAfter reports whether a valid record starts anywhere after off. It
+// reads the rest of the segment into memory, which is fine for the recovery
+// path it serves.
+//
+// Only offsets that look like the start of a record are checksummed: the
+// kind must be known, the record must fit, and it must be followed by the
+// end of the segment, a torn header or another header of a known kind, as
+// a record appended after it would be. Without that, every byte offset in a
+// large damaged tail would checksum the rest of the tail.
+func (l *Log) intactAfter(off, end int64) (bool, error) {
+	if end-off <= headerSize {
+		return false, nil
+	}
+	b := make([]byte, end-off-1)
+	if _, err := l.f.ReadAt(b, off+1); err != nil {
+		return false, err
+	}
+	for p := 0; len(b)-p >= headerSize; p++ {
+		n, ok := recordSize(b[p:])
+		if !ok || n > int64(len(b)-p) {
+			continue
+		}
+		next := b[int64(p)+n:]
+		if len(next) >= headerSize && !knownKind(next[4]) {
+			continue
+		}
+		if validRecord(b[p : int64(p)+n]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordSize returns the length of the record whose header starts hdr, and
+// false if the header's kind is unknown.
+func recordSize(hdr []byte) (int64, bool) {
+	if !knownKind(hdr[4]) {
+		return 0, false
+	}
+	keyLen, valLen := binary.LittleEndian.Uint32(hdr[5:]), binary.LittleEndian.Uint32(hdr[9:])
+	return headerSize + int64(keyLen) + int64(valLen), true
+}
+
+func knownKind(kind byte) bool { return kind == recordPut || kind == recordDelete }
+
+func validRecord(rec []byte) bool {
+	return len(rec) >= headerSize && binary.LittleEndian.Uint32(rec) == crc32.Checksum(rec[4:], castagnoli)
+}
+
+func (l *Log) Get(key string) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.get(key)
+}
+
+func (l *Log) get(key string) ([]byte, error) {
+	sp, ok := l.index[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rec := make([]byte, sp.n)
+	if _, err := l.f.ReadAt(rec, sp.off); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("blobstore: %w: short read at offset %d", ErrCorrupt, sp.off)
+		}
+		return nil, err
+	}
+	if !validRecord(rec) {
+		return nil, fmt.Errorf("blobstore: %w: checksum mismatch at offset %d", ErrCorrupt, sp.off)
+	}
+	return rec[headerSize+len(key):], nil
+}
+
+func (l *Log) Put(key string, blob []byte) error {
+	return l.append(recordPut, key, blob)
+}
+
+func (l *Log) CompareAndPut(key string, old, blob []byte) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cur, err := l.get(key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(cur, old) {
+		return false, nil
+	}
+	if err := l.appendLocked(recordPut, key, blob); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *Log) Delete(key string) error {
+	l.mu.RLock()
+	_, ok := l.index[key]
+	l.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return l.append(recordDelete, key, nil)
+}
+
+func (l *Log) append(kind byte, key string, blob []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.appendLocked(kind, key, blob)
+}
+
+func (l *Log) appendLocked(kind byte, key string, blob []byte) error {
+	l.buf = appendRecord(l.buf[:0], kind, key, blob)
+	n := int64(len(l.buf))
+	if _, err := l.f.WriteAt(l.buf, l.size); err != nil {
+		return err
+	}
+	if l.opts.Sync {
+		if err := l.f.Sync(); err != nil {
+			return err
+		}
+	}
+	if old, ok := l.index[key]; ok {
+		l.garbage += old.n
+	}
+	if kind == recordDelete {
+		delete(l.index, key)
+		l.garbage += n
+	} else {
+		l.index[key] = span{off: l.size, n: n}
+	}
+	l.size += n
+	l.written += n
+	return nil
+}
+
+func appendRecord(b []byte, kind byte, key string, blob []byte) []byte {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0, kind)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(key)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(blob)))
+	b = append(b, key...)
+	b = append(b, blob...)
+	binary.LittleEndian.PutUint32(b[start:], crc32.Checksum(b[start+4:], castagnoli))
+	return b
+}
+
+func (l *Log) Keys() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	keys := make([]string, 0, len(l.index))
+	for k := range l.index {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// LogStats describes the segment's size and write volume.
+type LogStats struct {
+	Size    int64 // current segment size in bytes
+	Garbage int64 // bytes held by overwritten or deleted records
+	Written int64 // bytes appended since the Log was opened
+	Keys    int
+}
+
+func (l *Log) Stats() LogStats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return LogStats{Size: l.size, Garbage: l.garbage, Written: l.written, Keys: len(l.index)}
+}
+
+// Compact rewrites the segment with only the live record for each key, then
+// atomically replaces the old segment with it. The new segment and the
+// rename are both synced before Compact returns.
+func (l *Log) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tmpPath := l.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	index := make(map[string]span, len(l.index))
+	var off int64
+	var rec []byte
+	for key, sp := range l.index {
+		if int64(cap(rec)) < sp.n {
+			rec = make([]byte, sp.n)
+		}
+		rec = rec[:sp.n]
+		if _, err := l.f.ReadAt(rec, sp.off); err != nil {
+			return fail(err)
+		}
+		if !validRecord(rec) {
+			return fail(fmt.Errorf("blobstore: %w at offset %d of %s", ErrCorrupt, sp.off, l.path))
+		}
+		if _, err := tmp.WriteAt(rec, off); err != nil {
+			return fail(err)
+		}
+		index[key] = span{off: off, n: sp.n}
+		off += sp.n
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return fail(err)
+	}
+	l.f.Close()
+	l.f = tmp
+	l.index = index
+	l.size = off
+	l.garbage = 0
+	// The rename is only durable once the directory entry is; until then a
+	// crash could bring back the old segment.
+	return syncDir(filepath.Dir(l.path))
+}
+
+// syncDir fsyncs the directory at path.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
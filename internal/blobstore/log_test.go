@@ -0,0 +1,215 @@
+package blobstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestLog(t *testing.T, path string) *Log {
+	t.Helper()
+	l, err := OpenLog(path, LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func mustPut(t *testing.T, s Store, key, val string) {
+	t.Helper()
+	if err := s.Put(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantBlob(t *testing.T, s Store, key, want string) {
+	t.Helper()
+	got, err := s.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	if string(got) != want {
+		t.Fatalf("Get(%q) = %q, want %q", key, got, want)
+	}
+}
+
+func TestLog_ReopenRebuildsIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seg")
+	l := openTestLog(t, path)
+	mustPut(t, l, "a", "1")
+	mustPut(t, l, "b", "2")
+	mustPut(t, l, "a", "3")
+	if err := l.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	l = openTestLog(t, path)
+	wantBlob(t, l, "a", "3")
+	if _, err := l.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(deleted) err = %v, want ErrNotFound", err)
+	}
+	if st := l.Stats(); st.Keys != 1 || st.Garbage == 0 {
+		t.Fatalf("stats after reopen = %+v", st)
+	}
+}
+
+func TestLog_TornTailIsDiscarded(t *testing.T) {
+	for _, cut := range []int64{1, headerSize / 2, headerSize + 1} {
+		path := filepath.Join(t.TempDir(), "seg")
+		l := openTestLog(t, path)
+		mustPut(t, l, "a", "committed")
+		mustPut(t, l, "b", "torn-by-crash")
+		l.Close()
+		truncateBy(t, path, cut)
+
+		l = openTestLog(t, path)
+		wantBlob(t, l, "a", "committed")
+		if _, err := l.Get("b"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("cut %d: Get(torn) err = %v, want ErrNotFound", cut, err)
+		}
+		// The torn bytes are gone, so new appends are readable after reopen.
+		mustPut(t, l, "c", "after")
+		l.Close()
+		l = openTestLog(t, path)
+		wantBlob(t, l, "c", "after")
+	}
+}
+
+func TestLog_BitFlipDetectedOnGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seg")
+	l := openTestLog(t, path)
+	mustPut(t, l, "a", "payload")
+	flipByteAt(t, path, -2)
+	if _, err := l.Get("a"); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Get after bit flip err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestLog_BitFlipMidSegmentFailsOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seg")
+	l := openTestLog(t, path)
+	mustPut(t, l, "a", "first")
+	mustPut(t, l, "b", "second")
+	l.Close()
+	flipByteAt(t, path, headerSize+1) // inside the first record's key
+
+	if _, err := OpenLog(path, LogOptions{}); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("OpenLog err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestLog_CorruptLengthFailsOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seg")
+	l := openTestLog(t, path)
+	mustPut(t, l, "a", "first")
+	mustPut(t, l, "b", "second")
+	l.Close()
+	size := l.Stats().Size
+	// Flipping the low bit of keyLen's high byte makes the first record
+	// appear to run far past the end of the segment, like a torn tail.
+	flipByteAt(t, path, 8)
+
+	if _, err := OpenLog(path, LogOptions{}); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("OpenLog err = %v, want ErrCorrupt", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != size {
+		t.Fatalf("segment truncated after failed open: %v, %v", info, err)
+	}
+}
+
+func TestLog_CorruptLengthAtTailIsDiscarded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seg")
+	l := openTestLog(t, path)
+	mustPut(t, l, "a", "first")
+	mustPut(t, l, "b", "second")
+	l.Close()
+	flipByteAt(t, path, headerSize+int64(len("afirst"))+8)
+
+	l = openTestLog(t, path)
+	wantBlob(t, l, "a", "first")
+	if _, err := l.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(torn) err = %v, want ErrNotFound", err)
+	}
+}
+
+// A crash can tear the record after an intact one, which must still be
+// found behind earlier damage, whether the torn record kept its header or
+// not.
+func TestLog_CorruptBeforeTornTailFailsOpen(t *testing.T) {
+	for _, torn := range []int64{2, 10} {
+		path := filepath.Join(t.TempDir(), "seg")
+		l := openTestLog(t, path)
+		mustPut(t, l, "a", "first")
+		mustPut(t, l, "b", "second")
+		mustPut(t, l, "c", "third")
+		l.Close()
+		flipByteAt(t, path, headerSize+1) // inside the first record's value
+		truncateBy(t, path, torn)
+
+		if _, err := OpenLog(path, LogOptions{}); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("torn by %d: OpenLog err = %v, want ErrCorrupt", torn, err)
+		}
+	}
+}
+
+func TestLog_CompactKeepsLiveRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seg")
+	l := openTestLog(t, path)
+	for i := 0; i < 10; i++ {
+		mustPut(t, l, "a", string(rune('0'+i)))
+	}
+	mustPut(t, l, "b", "kept")
+	mustPut(t, l, "c", "dropped")
+	if err := l.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	before := l.Stats()
+	if err := l.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after := l.Stats()
+	if after.Garbage != 0 || after.Size >= before.Size || after.Keys != 2 {
+		t.Fatalf("stats before %+v, after %+v", before, after)
+	}
+	wantBlob(t, l, "a", "9")
+	wantBlob(t, l, "b", "kept")
+	l.Close()
+
+	l = openTestLog(t, path)
+	wantBlob(t, l, "a", "9")
+	wantBlob(t, l, "b", "kept")
+	if _, err := l.Get("c"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(deleted) err = %v, want ErrNotFound", err)
+	}
+}
+
+func truncateBy(t *testing.T, path string, n int64) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flipByteAt flips the low bit of the byte at off; negative offsets count
+// from the end of the file.
+func flipByteAt(t *testing.T, path string, off int64) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off < 0 {
+		off += int64(len(data))
+	}
+	data[off] ^= 1
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+// Package bufpool encodes JSON through pooled buffers, for repositories that
+// opt into reusing them across calls instead of letting every json.Marshal
+// grow a buffer of its own.
+package bufpool
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxKept is the largest buffer returned to the pool. A rare huge order would
+// otherwise pin its buffer for as long as the pool keeps it.
+const maxKept = 64 << 10
+
+// encoder is a buffer and a JSON encoder that writes to it.
+type encoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var encoders = sync.Pool{New: func() any {
+	e := new(encoder)
+	e.enc = json.NewEncoder(&e.buf)
+	return e
+}}
+
+// Marshal returns the JSON encoding of v, as json.Marshal does. The result
+// is a copy the caller owns; the buffer it was encoded into goes back to the
+// pool.
+func Marshal(v any) ([]byte, error) {
+	e := encoders.Get().(*encoder)
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		release(e)
+		return nil, err
+	}
+	b := e.buf.Bytes()
+	out := bytes.Clone(b[:len(b)-1]) // Encode ends with a newline; Marshal does not
+	release(e)
+	return out, nil
+}
+
+func release(e *encoder) {
+	if e.buf.Cap() <= maxKept {
+		encoders.Put(e)
+	}
+}
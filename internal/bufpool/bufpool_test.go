@@ -0,0 +1,71 @@
+package bufpool
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type doc struct {
+	ID    string            `json:"id"`
+	HTML  string            `json:"html"`
+	Items []int             `json:"items"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+func TestMarshal_MatchesJSON(t *testing.T) {
+	for _, v := range []any{
+		doc{ID: "a", HTML: "<b>&</b>", Items: []int{1, 2}},
+		doc{},
+		&doc{ID: strings.Repeat("x", maxKept), Tags: map[string]string{"k": "v"}},
+		[]string{"é", " "},
+	} {
+		want, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Marshal(%T) = %.60s, want %.60s", v, got, want)
+		}
+	}
+}
+
+func TestMarshal_Error(t *testing.T) {
+	if _, err := Marshal(math.NaN()); err == nil {
+		t.Fatal("Marshal(NaN) succeeded")
+	}
+	// The buffer that failed is not left holding a partial encoding.
+	got, err := Marshal(1)
+	if err != nil || string(got) != "1" {
+		t.Fatalf("Marshal(1) = %q, %v", got, err)
+	}
+}
+
+// Results are owned by the caller: encoding more values, from any
+// goroutine, does not change them.
+func TestMarshal_ResultsAreCopies(t *testing.T) {
+	first, _ := Marshal("first")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := Marshal(doc{ID: "overwrite", Items: []int{j}}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if string(first) != `"first"` {
+		t.Fatalf("first result changed to %q", first)
+	}
+}
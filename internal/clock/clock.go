@@ -1,25 +1,157 @@
+// Package clock provides the time sources aggregates use to stamp changes.
+// Each aggregate and repository holds its own Clock, so tests and benchmarks
+// running in parallel can use different clocks without sharing state.
 package clock
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// Now returns the current time. It can be overridden for tests/benchmarks.
-var Now = time.Now
+// Clock returns the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Now returns c.Now(), or time.Now() if c is nil, so the zero value of a
+// struct holding a Clock uses the real time.
+func Now(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// Real is the system clock.
+type Real struct{}
+
+func (Real) Now() time.Time { return time.Now() }
+
+// Fixed always returns the same time. Converting a Fixed to a Clock
+// allocates, so convert it once rather than per call.
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time { return time.Time(f) }
+
+// MonotonicFake returns a time that advances by a fixed step on every call,
+// avoiding the cost and noise of reading the system clock. It is safe for
+// concurrent use.
+type MonotonicFake struct {
+	start time.Time
+	step  time.Duration
+	n     atomic.Int64
+}
 
-// UseMonotonicFake overrides Now to return a monotonically increasing time
-// starting from the provided start time (or time.Unix(0,0) if start.IsZero())
-// advancing by the given step on each call. It returns a restore function
-// that resets Now back to time.Now.
-func UseMonotonicFake(start time.Time, step time.Duration) (restore func()) {
+// NewMonotonicFake returns a clock whose first reading is start+step, or
+// time.Unix(0, 0)+step if start is zero.
+func NewMonotonicFake(start time.Time, step time.Duration) *MonotonicFake {
 	if start.IsZero() {
 		start = time.Unix(0, 0)
 	}
-	var counter atomic.Int64
-	Now = func() time.Time {
-		n := counter.Add(1)
-		return start.Add(time.Duration(n) * step)
+	return &MonotonicFake{start: start, step: step}
+}
+
+func (c *MonotonicFake) Now() time.Time {
+	return c.start.Add(time.Duration(c.n.Add(1)) * c.step)
+}
+
+// Manual returns the time it was last set to, and only moves when told to.
+// It is safe for concurrent use.
+type Manual struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewManual(t time.Time) *Manual { return &Manual{t: t} }
+
+func (c *Manual) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Set moves the clock to t, which may be earlier than its current time.
+func (c *Manual) Set(t time.Time) {
+	c.mu.Lock()
+	c.t = t
+	c.mu.Unlock()
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (c *Manual) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+	return c.t
+}
+
+// Cached is a real clock that trades precision for speed: a background
+// goroutine stores the time every resolution, and Now returns the stored
+// value, which is up to one resolution old. Its times carry no monotonic
+// reading and are in the local zone. Until Start and after Stop, Now reads
+// the system clock directly. It is safe for concurrent use.
+type Cached struct {
+	resolution time.Duration
+	nanos      atomic.Int64 // Unix nanoseconds; 0 while stopped
+
+	mu   sync.Mutex // guards stop and done
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCached returns a stopped Cached clock. It panics if resolution is not
+// positive, rather than leaving Start to panic in time.NewTicker.
+func NewCached(resolution time.Duration) *Cached {
+	if resolution <= 0 {
+		panic("clock: non-positive Cached resolution")
+	}
+	return &Cached{resolution: resolution}
+}
+
+func (c *Cached) Now() time.Time {
+	if n := c.nanos.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Now()
+}
+
+// Start begins updating the cached time. Starting a running clock does
+// nothing.
+func (c *Cached) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return
+	}
+	c.stop, c.done = make(chan struct{}), make(chan struct{})
+	c.nanos.Store(time.Now().UnixNano())
+	go c.run(time.NewTicker(c.resolution), c.stop, c.done)
+}
+
+func (c *Cached) run(t *time.Ticker, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			c.nanos.Store(now.UnixNano())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop ends the updates and waits for the background goroutine to exit.
+// Stopping a stopped clock does nothing; a stopped clock can be restarted.
+func (c *Cached) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop == nil {
+		return
 	}
-	return func() { Now = time.Now }
+	close(c.stop)
+	<-c.done
+	c.stop, c.done = nil, nil
+	c.nanos.Store(0)
 }
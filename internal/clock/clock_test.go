@@ -0,0 +1,186 @@
+package clock
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNow_NilIsReal(t *testing.T) {
+	before := time.Now()
+	got := Now(nil)
+	if got.Before(before) || got.After(time.Now()) {
+		t.Fatalf("Now(nil) = %v, not between %v and now", got, before)
+	}
+}
+
+func TestFixed(t *testing.T) {
+	want := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	c := Fixed(want)
+	for i := 0; i < 3; i++ {
+		if got := Now(c); !got.Equal(want) {
+			t.Fatalf("call %d: Now = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMonotonicFake(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+		want  []time.Time
+	}{
+		{"zero start is the epoch", time.Time{}, []time.Time{time.Unix(0, 2), time.Unix(0, 4), time.Unix(0, 6)}},
+		{"explicit start", time.Unix(100, 0), []time.Time{time.Unix(100, 2), time.Unix(100, 4), time.Unix(100, 6)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMonotonicFake(tt.start, 2*time.Nanosecond)
+			for i, want := range tt.want {
+				if got := c.Now(); !got.Equal(want) {
+					t.Fatalf("call %d: Now = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestMonotonicFake_Independent checks that two fakes in use at once do not
+// share a counter, which the former package-level fake could not offer.
+func TestMonotonicFake_Independent(t *testing.T) {
+	a := NewMonotonicFake(time.Time{}, time.Second)
+	b := NewMonotonicFake(time.Time{}, time.Second)
+	a.Now()
+	a.Now()
+	if got, want := b.Now(), time.Unix(1, 0); !got.Equal(want) {
+		t.Fatalf("b.Now = %v, want %v", got, want)
+	}
+}
+
+func TestManual(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewManual(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatalf("Now = %v, want %v", got, start)
+	}
+	if got, want := c.Advance(time.Minute), start.Add(time.Minute); !got.Equal(want) || !c.Now().Equal(want) {
+		t.Fatalf("after Advance: %v, Now = %v, want %v", got, c.Now(), want)
+	}
+	c.Set(start.Add(-time.Hour))
+	if got, want := c.Now(), start.Add(-time.Hour); !got.Equal(want) {
+		t.Fatalf("after Set: Now = %v, want %v", got, want)
+	}
+}
+
+func TestCached(t *testing.T) {
+	const resolution = 5 * time.Millisecond
+	c := NewCached(resolution)
+	if got := c.Now(); got.Sub(time.Now()).Abs() > time.Second {
+		t.Fatalf("before Start: Now = %v, want about the real time", got)
+	}
+	c.Start()
+	c.Start() // no-op
+	first := c.Now()
+	if d := time.Since(first); d < 0 || d > time.Second {
+		t.Fatalf("after Start: Now is %v behind the real time", d)
+	}
+	if got := c.Now(); got.Before(first) {
+		t.Fatalf("Now went backwards: %v then %v", first, got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Now().Equal(first) {
+		if time.Now().After(deadline) {
+			t.Fatal("cached time never advanced")
+		}
+		time.Sleep(resolution)
+	}
+	c.Stop()
+	c.Stop() // no-op
+	a := c.Now()
+	time.Sleep(time.Millisecond)
+	if b := c.Now(); !b.After(a) {
+		t.Fatalf("after Stop: Now = %v then %v, want the real, advancing time", a, b)
+	}
+	c.Start() // restartable
+	c.Stop()
+}
+
+func TestNewCached_RejectsNonPositiveResolution(t *testing.T) {
+	for _, res := range []time.Duration{0, -time.Millisecond} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewCached(%v) did not panic", res)
+				}
+			}()
+			NewCached(res)
+		}()
+	}
+}
+
+// The benchmarks compare reading a time source through the Clock interface
+// with calling it directly and with the package-level function variable this
+// package used to expose, to show what the injection costs.
+
+var sink time.Time
+
+// nowVar mimics the former global: a mutable package-level func.
+var nowVar = time.Now
+
+func BenchmarkNow(b *testing.B) {
+	fake := NewMonotonicFake(time.Time{}, time.Nanosecond)
+	var fixed Clock = Fixed(time.Unix(1, 0)) // convert once; boxing allocates
+	cached := NewCached(time.Millisecond)
+	cached.Start()
+	defer cached.Stop()
+	var n atomic.Int64
+	fakeVar := func() time.Time { return time.Unix(0, 0).Add(time.Duration(n.Add(1))) }
+	benches := []struct {
+		name string
+		now  func() time.Time
+	}{
+		{"real/direct", time.Now},
+		{"real/var", func() time.Time { return nowVar() }},
+		{"real/interface", func() time.Time { return Now(Real{}) }},
+		{"fake/direct", fake.Now},
+		{"fake/var", fakeVar},
+		{"fake/interface", func() time.Time { return Now(fake) }},
+		{"fixed/interface", func() time.Time { return Now(fixed) }},
+		{"cached/direct", cached.Now},
+		{"cached/interface", func() time.Time { return Now(cached) }},
+	}
+	for _, bb := range benches {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sink = bb.now()
+			}
+		})
+	}
+}
+
+// BenchmarkNow_Parallel shows the contention on a shared MonotonicFake's
+// counter when every goroutine reads the same clock, against one per
+// goroutine.
+func BenchmarkNow_Parallel(b *testing.B) {
+	b.Run("shared", func(b *testing.B) {
+		var c Clock = NewMonotonicFake(time.Time{}, time.Nanosecond)
+		b.RunParallel(func(pb *testing.PB) {
+			var t time.Time
+			for pb.Next() {
+				t = Now(c)
+			}
+			_ = t
+		})
+	})
+	b.Run("per-goroutine", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			var c Clock = NewMonotonicFake(time.Time{}, time.Nanosecond)
+			var t time.Time
+			for pb.Next() {
+				t = Now(c)
+			}
+			_ = t
+		})
+	})
+}
@@ -0,0 +1,109 @@
+// Package hist records latency distributions in a log-linear histogram, the
+// layout HdrHistogram uses: values below 128ns get a bucket each, and every
+// power of two above that is split into 64 equal buckets, so any recorded
+// value is reported to within 1/64 (about 1.6%) of its true value. Recording
+// is a few arithmetic operations and never allocates.
+package hist
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	subBits    = 6            // 64 sub-buckets per power of two
+	subCount   = 1 << subBits // 64
+	linearMax  = 2 * subCount // values below this have exact buckets
+	numBuckets = linearMax + (64-subBits-1)*subCount
+)
+
+// Histogram counts durations. The zero value is empty and ready to use. It
+// is not safe for concurrent use.
+type Histogram struct {
+	counts   [numBuckets]uint64
+	n        uint64
+	sum      float64
+	min, max int64
+}
+
+func bucket(v uint64) int {
+	if v < linearMax {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBits - 1 // leaves v>>shift in [64, 128)
+	return linearMax + (shift-1)*subCount + int(v>>shift) - subCount
+}
+
+// bucketMax returns the largest value that falls in bucket i.
+func bucketMax(i int) uint64 {
+	if i < linearMax {
+		return uint64(i)
+	}
+	shift := (i-linearMax)/subCount + 1
+	sub := uint64((i-linearMax)%subCount + subCount)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds one observation. Negative durations are recorded as zero.
+func (h *Histogram) Record(d time.Duration) {
+	v := max(int64(d), 0)
+	h.counts[bucket(uint64(v))]++
+	if h.n == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.n++
+	h.sum += float64(v)
+}
+
+// Merge adds every observation in o to h.
+func (h *Histogram) Merge(o *Histogram) {
+	if o.n == 0 {
+		return
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	if h.n == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	h.max = max(h.max, o.max)
+	h.n += o.n
+	h.sum += o.sum
+}
+
+func (h *Histogram) Count() uint64 { return h.n }
+
+func (h *Histogram) Min() time.Duration { return time.Duration(h.min) }
+
+func (h *Histogram) Max() time.Duration { return time.Duration(h.max) }
+
+func (h *Histogram) Mean() time.Duration {
+	if h.n == 0 {
+		return 0
+	}
+	return time.Duration(math.Round(h.sum / float64(h.n)))
+}
+
+// Quantile returns the smallest recorded value, to within the histogram's
+// precision, that at least q of the observations are at or below; q is
+// clamped to [0, 1]. It returns 0 for an empty histogram.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.n == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(min(max(q, 0), 1) * float64(h.n)))
+	rank = max(rank, 1)
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			v := min(int64(bucketMax(i)), h.max)
+			return time.Duration(max(v, h.min))
+		}
+	}
+	return time.Duration(h.max)
+}
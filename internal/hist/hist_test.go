@@ -0,0 +1,92 @@
+package hist
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	// Every value must land in a bucket whose range contains it, and buckets
+	// must be contiguous and increasing.
+	for _, v := range []uint64{0, 1, 127, 128, 129, 255, 256, 1000, 1 << 20, 1<<20 + 12345, math.MaxInt64} {
+		i := bucket(v)
+		if hi := bucketMax(i); v > hi {
+			t.Errorf("bucket(%d) = %d, whose max is %d", v, i, hi)
+		}
+		if i > 0 {
+			if lo := bucketMax(i-1) + 1; v < lo {
+				t.Errorf("bucket(%d) = %d, which starts at %d", v, i, lo)
+			}
+		}
+	}
+	for i := 1; i < bucket(math.MaxInt64); i++ {
+		if bucket(bucketMax(i-1)+1) != i {
+			t.Fatalf("bucket %d does not start right after bucket %d", i, i-1)
+		}
+	}
+}
+
+func TestHistogram_Quantile(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	var h Histogram
+	values := make([]int64, 100000)
+	for i := range values {
+		values[i] = int64(r.ExpFloat64() * float64(50*time.Microsecond))
+		h.Record(time.Duration(values[i]))
+	}
+	slices.Sort(values)
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 0.999, 1} {
+		rank := max(int(math.Ceil(q*float64(len(values)))), 1)
+		want := float64(values[rank-1])
+		got := float64(h.Quantile(q))
+		if math.Abs(got-want) > want/64+1 {
+			t.Errorf("Quantile(%v) = %v, want %v within 1/64", q, got, want)
+		}
+	}
+	if h.Count() != uint64(len(values)) || h.Min() != time.Duration(values[0]) || h.Max() != time.Duration(values[len(values)-1]) {
+		t.Errorf("Count, Min, Max = %d, %v, %v", h.Count(), h.Min(), h.Max())
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	var a, b, all Histogram
+	for i := 1; i <= 1000; i++ {
+		d := time.Duration(i) * time.Microsecond
+		all.Record(d)
+		if i%3 == 0 {
+			a.Record(d)
+		} else {
+			b.Record(d)
+		}
+	}
+	var m Histogram
+	m.Merge(&a)
+	m.Merge(&b)
+	m.Merge(&Histogram{})
+	if m != all {
+		t.Fatalf("merged histogram differs: count %d mean %v p99 %v, want %d %v %v",
+			m.Count(), m.Mean(), m.Quantile(0.99), all.Count(), all.Mean(), all.Quantile(0.99))
+	}
+}
+
+func TestHistogram_Empty(t *testing.T) {
+	var h Histogram
+	if h.Count() != 0 || h.Mean() != 0 || h.Quantile(0.5) != 0 || h.Max() != 0 {
+		t.Fatal("empty histogram reports observations")
+	}
+	h.Record(-time.Second)
+	if h.Min() != 0 || h.Quantile(1) != 0 {
+		t.Fatalf("negative duration recorded as %v", h.Min())
+	}
+}
+
+func BenchmarkRecord(b *testing.B) {
+	var h Histogram
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Record(time.Duration(i & 0xfffff))
+	}
+}
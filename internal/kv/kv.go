@@ -0,0 +1,106 @@
+// Package kv is an in-memory key/value store with a separate key space per
+// table. It stands in for a row store where each row is written individually,
+// so repositories can persist only the rows that changed.
+package kv
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds encoded rows per table. Writes are applied in batches so that
+// all rows belonging to one Save land atomically.
+type Store struct {
+	mu     sync.RWMutex
+	tables map[string]map[string][]byte
+
+	puts    atomic.Int64
+	deletes atomic.Int64
+}
+
+func New() *Store { return &Store{tables: make(map[string]map[string][]byte)} }
+
+// Get returns the row stored under key in table.
+func (s *Store) Get(table, key string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blob, ok := s.tables[table][key]
+	return blob, ok
+}
+
+// Read calls fn with a view of the store that is consistent for the duration
+// of the call. fn must not retain r.
+func (s *Store) Read(fn func(r Reader) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(Reader{s: s})
+}
+
+// Keys returns a copy of the keys in table.
+func (s *Store) Keys(table string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.tables[table]))
+	for k := range s.tables[table] {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Commit applies every write in b atomically and resets b for reuse.
+func (s *Store) Commit(b *Batch) {
+	s.mu.Lock()
+	for _, w := range b.writes {
+		t := s.tables[w.table]
+		if t == nil {
+			t = make(map[string][]byte)
+			s.tables[w.table] = t
+		}
+		if w.blob == nil {
+			if _, ok := t[w.key]; ok {
+				delete(t, w.key)
+				s.deletes.Add(1)
+			}
+			continue
+		}
+		t[w.key] = w.blob
+		s.puts.Add(1)
+	}
+	s.mu.Unlock()
+	b.writes = b.writes[:0]
+}
+
+// Stats reports the number of row puts and deletes committed so far. Deletes
+// of rows that did not exist are not counted.
+func (s *Store) Stats() (puts, deletes int64) { return s.puts.Load(), s.deletes.Load() }
+
+// Reader reads from a Store while its read lock is held.
+type Reader struct{ s *Store }
+
+func (r Reader) Get(table, key string) ([]byte, bool) {
+	blob, ok := r.s.tables[table][key]
+	return blob, ok
+}
+
+// Batch collects row writes to be committed together.
+type Batch struct {
+	writes []write
+}
+
+type write struct {
+	table, key string
+	blob       []byte // nil deletes the row
+}
+
+func (b *Batch) Put(table, key string, blob []byte) {
+	if blob == nil {
+		blob = []byte{}
+	}
+	b.writes = append(b.writes, write{table: table, key: key, blob: blob})
+}
+
+func (b *Batch) Delete(table, key string) {
+	b.writes = append(b.writes, write{table: table, key: key})
+}
+
+func (b *Batch) Len() int { return len(b.writes) }
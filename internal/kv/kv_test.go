@@ -0,0 +1,58 @@
+package kv
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommit(t *testing.T) {
+	s := New()
+	var b Batch
+	b.Put("t", "a", []byte("1"))
+	b.Put("t", "b", nil)
+	b.Put("u", "a", []byte("2"))
+	b.Delete("t", "b")
+	b.Put("t", "c", []byte("3"))
+	s.Commit(&b)
+	if b.Len() != 0 {
+		t.Fatalf("Len after Commit = %d, want 0", b.Len())
+	}
+
+	if blob, ok := s.Get("t", "a"); !ok || string(blob) != "1" {
+		t.Errorf("t/a = %q, %v; want 1", blob, ok)
+	}
+	if blob, ok := s.Get("u", "a"); !ok || string(blob) != "2" {
+		t.Errorf("u/a = %q, %v; want 2", blob, ok)
+	}
+	if _, ok := s.Get("t", "b"); ok {
+		t.Error("t/b survived a later delete in the same batch")
+	}
+	keys := s.Keys("t")
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "c"}) {
+		t.Errorf("Keys(t) = %q, want [a c]", keys)
+	}
+
+	_ = s.Read(func(r Reader) error {
+		if blob, ok := r.Get("t", "c"); !ok || string(blob) != "3" {
+			t.Errorf("Reader t/c = %q, %v; want 3", blob, ok)
+		}
+		return nil
+	})
+}
+
+func TestStats_CountOnlyRowsDeleted(t *testing.T) {
+	s := New()
+	var b Batch
+	b.Put("t", "a", []byte("1"))
+	b.Put("t", "a", []byte("2"))
+	b.Delete("t", "missing")
+	b.Delete("other", "missing")
+	s.Commit(&b)
+	b.Delete("t", "a")
+	b.Delete("t", "a")
+	s.Commit(&b)
+	if puts, deletes := s.Stats(); puts != 2 || deletes != 1 {
+		t.Errorf("Stats = %d puts, %d deletes; want 2, 1", puts, deletes)
+	}
+}
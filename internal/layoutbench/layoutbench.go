@@ -0,0 +1,93 @@
+// Package layoutbench compares a struct type as declared with a copy of it
+// whose fields are reordered, as cmd/layout suggests. Reordering fields that
+// do not change a type's size can still shorten the prefix of each value the
+// garbage collector scans for pointers, so the comparison covers both
+// building values and collecting a heap full of them:
+//
+//	BenchmarkDirect_Layout_OrderItemRow/declared/fill     ...
+//	BenchmarkDirect_Layout_OrderItemRow/declared/gc       ...
+//	BenchmarkDirect_Layout_OrderItemRow/reordered/fill    ...
+//	BenchmarkDirect_Layout_OrderItemRow/reordered/gc      ...
+package layoutbench
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/benchgc"
+)
+
+// Sizes of the slices the sub-benchmarks work on: fill allocates a slice
+// the size of a large order's items on every iteration, gc keeps a few
+// megabytes of values live while it collects.
+const (
+	FillLen = 64
+	LiveLen = 1 << 16
+)
+
+// Compare runs the fill and gc sub-benchmarks for the declared type and for
+// the reordered one. The fill functions set every field of the value from i,
+// so both types do the same work.
+func Compare[Declared, Reordered any](b *testing.B, declared func(*Declared, int), reordered func(*Reordered, int)) {
+	b.Run("declared", func(b *testing.B) { run(b, declared) })
+	b.Run("reordered", func(b *testing.B) { run(b, reordered) })
+}
+
+// SameFields returns an error unless Declared and Reordered are structs
+// with the same size and the same fields by name and type, in any order, so
+// a hand-written reordered copy cannot drift from the type it stands in for.
+func SameFields[Declared, Reordered any]() error {
+	d, r := reflect.TypeFor[Declared](), reflect.TypeFor[Reordered]()
+	if d.Kind() != reflect.Struct || r.Kind() != reflect.Struct {
+		return fmt.Errorf("layoutbench: %v and %v are not both structs", d, r)
+	}
+	if d.NumField() != r.NumField() {
+		return fmt.Errorf("layoutbench: %v has %d fields, %v has %d", d, d.NumField(), r, r.NumField())
+	}
+	for i := 0; i < d.NumField(); i++ {
+		df := d.Field(i)
+		rf, ok := r.FieldByName(df.Name)
+		if !ok {
+			return fmt.Errorf("layoutbench: %v has no field %s", r, df.Name)
+		}
+		if rf.Type != df.Type {
+			return fmt.Errorf("layoutbench: %s is %v in %v and %v in %v", df.Name, df.Type, d, rf.Type, r)
+		}
+	}
+	if d.Size() != r.Size() {
+		return fmt.Errorf("layoutbench: %v is %d bytes, %v is %d", d, d.Size(), r, r.Size())
+	}
+	return nil
+}
+
+func run[T any](b *testing.B, fill func(*T, int)) {
+	n := FillLen // a variable length keeps make from being stack allocated
+	b.Run("fill", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		benchgc.Track(b)
+		for i := 0; i < b.N; i++ {
+			s := make([]T, n)
+			for j := range s {
+				fill(&s[j], i+j)
+			}
+			runtime.KeepAlive(s)
+		}
+	})
+	b.Run("gc", func(b *testing.B) {
+		live := make([]*T, LiveLen) // separate objects, each scanned on its own
+		for i := range live {
+			live[i] = new(T)
+			fill(live[i], i)
+		}
+		runtime.GC()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			runtime.GC()
+		}
+		b.StopTimer()
+		runtime.KeepAlive(live)
+	})
+}
@@ -0,0 +1,74 @@
+package layoutbench
+
+import (
+	"strings"
+	"testing"
+)
+
+type declared struct {
+	n int
+	s string
+}
+
+type reordered struct {
+	s string
+	n int
+}
+
+func TestCompare(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs full garbage collections")
+	}
+	var nd, nr int
+	testing.Benchmark(func(b *testing.B) {
+		Compare(b,
+			func(v *declared, i int) { v.n, v.s = i, "s"; nd++ },
+			func(v *reordered, i int) { v.n, v.s = i, "s"; nr++ },
+		)
+	})
+	// Each type fills at least its live heap and one slice.
+	if nd < LiveLen+FillLen || nr < LiveLen+FillLen {
+		t.Fatalf("fill calls: declared %d, reordered %d", nd, nr)
+	}
+}
+
+func TestSameFields(t *testing.T) {
+	type renamed struct {
+		s string
+		m int
+	}
+	type retyped struct {
+		s string
+		n int32
+	}
+	type extra struct {
+		s string
+		n int
+		b bool
+	}
+	type padded struct {
+		b1 bool
+		n  int
+		b2 bool
+	}
+	type packed struct {
+		n  int
+		b1 bool
+		b2 bool
+	}
+	check := func(name string, err error, want string) {
+		t.Helper()
+		if want == "" && err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("%s: err = %v, want error containing %q", name, err, want)
+		}
+	}
+	check("reordered", SameFields[declared, reordered](), "")
+	check("renamed", SameFields[declared, renamed](), "no field n")
+	check("retyped", SameFields[declared, retyped](), "n is int")
+	check("extra", SameFields[declared, extra](), "has 2 fields")
+	check("not a struct", SameFields[declared, int](), "not both structs")
+	check("resized", SameFields[padded, packed](), "bytes")
+}
@@ -0,0 +1,73 @@
+// Package loadreport defines the JSON record cmd/dddload writes for each run
+// and cmd/benchagg reads back. Records are written one per line, so repeated
+// runs can be appended to one file and aggregated like -count runs of a
+// benchmark.
+package loadreport
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/hist"
+)
+
+// Result describes one load run.
+type Result struct {
+	// Name follows benchmark naming, e.g. "BenchmarkDDDLoad/direct/workers=4",
+	// so benchagg can group runs with its other results.
+	Name      string  `json:"name"`
+	Variant   string  `json:"variant"`
+	Workers   int     `json:"workers"`
+	Ops       uint64  `json:"ops"`
+	ElapsedNS int64   `json:"elapsed_ns"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	Latency   Latency `json:"latency_ns"`
+	GC        GC      `json:"gc"`
+}
+
+// Latency summarises per-operation latency in nanoseconds.
+type Latency struct {
+	Mean int64 `json:"mean"`
+	P50  int64 `json:"p50"`
+	P90  int64 `json:"p90"`
+	P99  int64 `json:"p99"`
+	P999 int64 `json:"p99.9"`
+	Max  int64 `json:"max"`
+}
+
+// GC describes garbage collection and allocation during the run.
+type GC struct {
+	Cycles       uint32  `json:"cycles"`
+	PauseTotalNS uint64  `json:"pause_total_ns"`
+	PauseMaxNS   uint64  `json:"pause_max_ns"` // of the last 256 cycles at most
+	BytesPerOp   float64 `json:"bytes_per_op"`
+	AllocsPerOp  float64 `json:"allocs_per_op"`
+}
+
+// LatencyOf summarises h.
+func LatencyOf(h *hist.Histogram) Latency {
+	ns := func(d time.Duration) int64 { return int64(d) }
+	return Latency{
+		Mean: ns(h.Mean()),
+		P50:  ns(h.Quantile(0.5)),
+		P90:  ns(h.Quantile(0.9)),
+		P99:  ns(h.Quantile(0.99)),
+		P999: ns(h.Quantile(0.999)),
+		Max:  ns(h.Max()),
+	}
+}
+
+// Parse decodes line if it holds a Result. Other lines, such as benchmark
+// output in the same file, report false.
+func Parse(line string) (Result, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return Result{}, false
+	}
+	var r Result
+	if err := json.Unmarshal([]byte(line), &r); err != nil || !strings.HasPrefix(r.Name, "Benchmark") || r.Ops == 0 {
+		return Result{}, false
+	}
+	return r, true
+}
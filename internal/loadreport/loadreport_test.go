@@ -0,0 +1,38 @@
+package loadreport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/hist"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{`{"name":"BenchmarkDDDLoad/direct/workers=1","ops":5}`, true},
+		{`  {"name":"BenchmarkX","ops":1}  `, true},
+		{`{"name":"load","ops":5}`, false},
+		{`{"name":"BenchmarkX","ops":0}`, false},
+		{`{"name":"BenchmarkX",`, false},
+		{`BenchmarkX-8  10  5 ns/op`, false},
+	}
+	for _, tt := range tests {
+		if _, ok := Parse(tt.line); ok != tt.ok {
+			t.Errorf("Parse(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+		}
+	}
+}
+
+func TestLatencyOf(t *testing.T) {
+	var h hist.Histogram
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i))
+	}
+	l := LatencyOf(&h)
+	if l.P50 < 500 || l.P50 > 510 || l.Max != 1000 || l.Mean != 501 || l.P999 < l.P99 { // buckets are 1/64 wide
+		t.Fatalf("LatencyOf = %+v", l)
+	}
+}
@@ -0,0 +1,95 @@
+// Package money is the Money value object the order models share: an amount
+// in minor units of a currency, with checked arithmetic and allocation-free
+// formatting.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var (
+	// ErrCurrencyMismatch is returned when combining amounts in different currencies.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrOverflow is returned when a result does not fit in int64 cents.
+	ErrOverflow = errors.New("amount overflows int64 cents")
+)
+
+// Money is an amount in minor units of Currency.
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+// Add returns m + other. Both amounts must share a currency.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %q and %q", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	c := m.Cents + other.Cents
+	if (other.Cents > 0 && c < m.Cents) || (other.Cents < 0 && c > m.Cents) {
+		return Money{}, fmt.Errorf("%w: %v + %v", ErrOverflow, m, other)
+	}
+	return Money{Cents: c, Currency: m.Currency}, nil
+}
+
+// Multiply returns m scaled by qty, e.g. a unit price times a line quantity.
+func (m Money) Multiply(qty int) (Money, error) {
+	q := int64(qty)
+	if m.Cents == 0 || q == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	c := m.Cents * q
+	if c/q != m.Cents || (m.Cents == math.MinInt64 && q == -1) {
+		return Money{}, fmt.Errorf("%w: %v * %d", ErrOverflow, m, qty)
+	}
+	return Money{Cents: c, Currency: m.Currency}, nil
+}
+
+// Discount returns m reduced by percent, which must be between 0 and 100,
+// with the reduction rounded toward zero to whole cents. It is computed as
+// c/100*p + c%100*p/100, which equals c*p/100 but never leaves int64, so
+// discounting even math.MaxInt64 cents cannot overflow.
+func (m Money) Discount(percent int) Money {
+	p := int64(percent)
+	off := m.Cents/100*p + m.Cents%100*p/100
+	return Money{Cents: m.Cents - off, Currency: m.Currency}
+}
+
+// AppendFormat appends m formatted as "12.34 USD" to b and returns the
+// extended buffer. It does not allocate if b has enough capacity.
+func (m Money) AppendFormat(b []byte) []byte {
+	u := uint64(m.Cents)
+	if m.Cents < 0 {
+		b = append(b, '-')
+		u = -u
+	}
+	b = strconv.AppendUint(b, u/100, 10)
+	frac := u % 100
+	b = append(b, '.', byte('0'+frac/10), byte('0'+frac%10))
+	if m.Currency != "" {
+		b = append(b, ' ')
+		b = append(b, m.Currency...)
+	}
+	return b
+}
+
+func (m Money) String() string { return string(m.AppendFormat(make([]byte, 0, 32))) }
+
+// Accumulate adds m to the entry of totals with the same currency, appending
+// a new entry the first time a currency is seen.
+func Accumulate(totals []Money, m Money) ([]Money, error) {
+	for i := range totals {
+		if totals[i].Currency == m.Currency {
+			sum, err := totals[i].Add(m)
+			if err != nil {
+				return nil, err
+			}
+			totals[i] = sum
+			return totals, nil
+		}
+	}
+	return append(totals, m), nil
+}
@@ -0,0 +1,115 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	for _, tc := range []struct {
+		a, b Money
+		want Money
+		err  error
+	}{
+		{Money{150, "USD"}, Money{275, "USD"}, Money{425, "USD"}, nil},
+		{Money{-150, "USD"}, Money{100, "USD"}, Money{-50, "USD"}, nil},
+		{Money{1, "USD"}, Money{1, "EUR"}, Money{}, ErrCurrencyMismatch},
+		{Money{math.MaxInt64, "USD"}, Money{1, "USD"}, Money{}, ErrOverflow},
+		{Money{math.MinInt64, "USD"}, Money{-1, "USD"}, Money{}, ErrOverflow},
+		{Money{math.MaxInt64, "USD"}, Money{math.MinInt64, "USD"}, Money{-1, "USD"}, nil},
+	} {
+		got, err := tc.a.Add(tc.b)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("%v.Add(%v) = %v, %v; want %v, %v", tc.a, tc.b, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestMultiply(t *testing.T) {
+	for _, tc := range []struct {
+		m    Money
+		qty  int
+		want Money
+		err  error
+	}{
+		{Money{199, "USD"}, 3, Money{597, "USD"}, nil},
+		{Money{199, "USD"}, 0, Money{0, "USD"}, nil},
+		{Money{199, "USD"}, -2, Money{-398, "USD"}, nil},
+		{Money{0, "USD"}, math.MaxInt, Money{0, "USD"}, nil},
+		{Money{math.MaxInt64 / 2, "USD"}, 2, Money{math.MaxInt64 - 1, "USD"}, nil},
+		{Money{math.MaxInt64/2 + 1, "USD"}, 2, Money{}, ErrOverflow},
+		{Money{math.MinInt64, "USD"}, -1, Money{}, ErrOverflow},
+		{Money{-1, "USD"}, math.MinInt, Money{}, ErrOverflow},
+		{Money{1 << 32, "USD"}, 1 << 32, Money{}, ErrOverflow},
+	} {
+		got, err := tc.m.Multiply(tc.qty)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("%v.Multiply(%d) = %v, %v; want %v, %v", tc.m, tc.qty, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestDiscount(t *testing.T) {
+	for _, tc := range []struct {
+		cents   int64
+		percent int
+		want    int64
+	}{
+		{1999, 5, 1900},
+		{99, 5, 95},
+		{-10, 5, -10},
+		{-1999, 5, -1900},
+		{1000, 0, 1000},
+		{1000, 100, 0},
+		{1e17, 100, 0},
+		{math.MaxInt64, 100, 0},
+		{math.MaxInt64, 50, 4611686018427387904},
+		{math.MaxInt64, 1, 9131138316486228049},
+		{math.MinInt64, 100, 0},
+		{math.MinInt64, 50, -4611686018427387904},
+	} {
+		m := Money{tc.cents, "USD"}
+		if got, want := m.Discount(tc.percent), (Money{tc.want, "USD"}); got != want {
+			t.Errorf("%v.Discount(%d) = %v, want %v", m, tc.percent, got, want)
+		}
+	}
+}
+
+func TestAppendFormat(t *testing.T) {
+	for _, tc := range []struct {
+		m    Money
+		want string
+	}{
+		{Money{1234, "USD"}, "12.34 USD"},
+		{Money{5, "EUR"}, "0.05 EUR"},
+		{Money{-1205, "USD"}, "-12.05 USD"},
+		{Money{0, ""}, "0.00"},
+		{Money{math.MinInt64, "USD"}, "-92233720368547758.08 USD"},
+	} {
+		if got := string(tc.m.AppendFormat([]byte("x="))); got != "x="+tc.want {
+			t.Errorf("AppendFormat(%+v) = %q, want %q", tc.m, got, "x="+tc.want)
+		}
+	}
+	buf := make([]byte, 0, 32)
+	if n := testing.AllocsPerRun(100, func() { buf = Money{1234, "USD"}.AppendFormat(buf[:0]) }); n != 0 {
+		t.Errorf("AppendFormat allocated %v times into a buffer with room", n)
+	}
+}
+
+func TestAccumulate(t *testing.T) {
+	var totals []Money
+	var err error
+	for _, m := range []Money{{100, "USD"}, {50, "EUR"}, {25, "USD"}} {
+		if totals, err = Accumulate(totals, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []Money{{125, "USD"}, {50, "EUR"}}; !slices.Equal(totals, want) {
+		t.Errorf("totals = %v, want %v", totals, want)
+	}
+	if _, err := Accumulate(totals, Money{math.MaxInt64, "USD"}); !errors.Is(err, ErrOverflow) {
+		t.Errorf("overflowing total: err = %v, want ErrOverflow", err)
+	}
+}
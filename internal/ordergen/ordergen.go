@@ -0,0 +1,185 @@
+// Package ordergen generates random orders for property tests. Orders are
+// expressed in a variant-neutral shape that each variant's tests convert to
+// and from, and generation is biased towards edge cases: empty and non-ASCII
+// strings, extreme amounts, many items, and zero, zoned or monotonic times.
+package ordergen
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Order is the business state shared by every variant.
+type Order struct {
+	ID                        string
+	First, Last, Email, Phone string
+	LoyaltyTier               string
+	LoyaltyPoints             int
+	Shipping, Billing         Address
+	Items                     []Item
+	CreatedAt, UpdatedAt      time.Time
+}
+
+type Address struct{ Street1, Street2, City, State, Zip string }
+
+type Item struct {
+	SKU                string
+	Quantity           int
+	PriceCents         int64
+	Currency           string
+	Backorder, Digital bool
+}
+
+// New returns a random order. Strings are valid UTF-8, since JSON replaces
+// invalid bytes. Times are either zero or representable as int64 Unix
+// nanoseconds, and never exactly the Unix epoch, which the int64 storage
+// form reserves for the zero time. The same r always yields the same
+// instants; only the monotonic readings differ between runs.
+func New(r *rand.Rand) Order {
+	o := Order{
+		ID:            str(r),
+		First:         str(r),
+		Last:          str(r),
+		Email:         str(r),
+		Phone:         str(r),
+		LoyaltyTier:   str(r),
+		LoyaltyPoints: int(integer(r)),
+		Shipping:      address(r),
+		Billing:       address(r),
+		CreatedAt:     timestamp(r),
+		UpdatedAt:     timestamp(r),
+	}
+	n := 0
+	switch r.IntN(4) {
+	case 0: // none
+	case 1:
+		n = 200 + r.IntN(50)
+	default:
+		n = 1 + r.IntN(8)
+	}
+	if n > 0 {
+		o.Items = make([]Item, n)
+	}
+	for i := range o.Items {
+		o.Items[i] = Item{SKU: str(r), Quantity: int(integer(r)), PriceCents: integer(r), Currency: str(r), Backorder: r.IntN(2) == 0, Digital: r.IntN(2) == 0}
+	}
+	return o
+}
+
+func address(r *rand.Rand) Address {
+	return Address{Street1: str(r), Street2: str(r), City: str(r), State: str(r), Zip: str(r)}
+}
+
+var strs = []string{
+	"", " ", "Ada", "Lovelace", "ada@example.com", "Zoë", "Łódź", "日本語", "😀👍🏽",
+	"é", "  ", "\x00", `"quoted"\`, "<a&b>", "line1\nline2", "\t\r",
+}
+
+func str(r *rand.Rand) string {
+	if r.IntN(2) == 0 {
+		return strs[r.IntN(len(strs))]
+	}
+	var b strings.Builder
+	for n := r.IntN(24); n > 0; n-- {
+		switch r.IntN(4) {
+		case 0:
+			b.WriteByte(byte(' ' + r.IntN(95)))
+		case 1:
+			b.WriteRune(rune(0x80 + r.IntN(0x800-0x80)))
+		case 2:
+			b.WriteRune(rune(0x800 + r.IntN(0xD800-0x800)))
+		default:
+			b.WriteRune(rune(0x10000 + r.IntN(0x110000-0x10000)))
+		}
+	}
+	return b.String()
+}
+
+var ints = []int64{0, 1, -1, math.MaxInt64, math.MinInt64, math.MaxInt32, math.MinInt32, 1 << 53, 1<<53 + 1}
+
+func integer(r *rand.Rand) int64 {
+	if r.IntN(2) == 0 {
+		return ints[r.IntN(len(ints))]
+	}
+	return r.Int64() - r.Int64()
+}
+
+// Times with a monotonic reading are drawn from monoBase ± monoSpan, well
+// inside the years (1885 to 2157) whose wall clocks can carry one.
+var monoBase = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const monoSpan = int64(50 * 365 * 24 * time.Hour)
+
+func timestamp(r *rand.Rand) time.Time {
+	switch r.IntN(5) {
+	case 0:
+		return time.Time{}
+	case 1:
+		// Only time.Now carries a monotonic reading. Shifting it to an
+		// instant drawn from r keeps the reading and the local zone while
+		// the instant stays reproducible.
+		now := time.Now()
+		at := monoBase.Add(time.Duration(r.Int64N(2*monoSpan) - monoSpan))
+		return now.Add(at.Sub(now))
+	}
+	var nanos int64
+	for nanos == 0 {
+		nanos = r.Int64() - r.Int64()
+	}
+	t := time.Unix(0, nanos)
+	switch r.IntN(3) {
+	case 0:
+		return t.UTC()
+	case 1:
+		return t.In(time.FixedZone("", (r.IntN(28*4)-14*4)*15*60))
+	}
+	// JSON writes offsets to the minute, so the local zone's local mean time,
+	// offset by odd seconds before standard time, would move the instant of
+	// a JSON round trip. Such times are drawn in UTC instead.
+	if _, off := t.Zone(); off%60 != 0 {
+		return t.UTC()
+	}
+	return t
+}
+
+// Diff describes the first difference between want and got, or returns ""
+// if they represent the same state. Times are compared by instant, so
+// locations and monotonic readings are ignored, and nil and empty item
+// slices are equal.
+func Diff(want, got Order) string {
+	switch {
+	case want.ID != got.ID:
+		return fmt.Sprintf("ID = %q, want %q", got.ID, want.ID)
+	case want.First != got.First || want.Last != got.Last:
+		return fmt.Sprintf("name = %q %q, want %q %q", got.First, got.Last, want.First, want.Last)
+	case want.Email != got.Email:
+		return fmt.Sprintf("Email = %q, want %q", got.Email, want.Email)
+	case want.Phone != got.Phone:
+		return fmt.Sprintf("Phone = %q, want %q", got.Phone, want.Phone)
+	case want.LoyaltyTier != got.LoyaltyTier || want.LoyaltyPoints != got.LoyaltyPoints:
+		return fmt.Sprintf("loyalty = %q %d, want %q %d", got.LoyaltyTier, got.LoyaltyPoints, want.LoyaltyTier, want.LoyaltyPoints)
+	case want.Shipping != got.Shipping:
+		return fmt.Sprintf("Shipping = %+q, want %+q", got.Shipping, want.Shipping)
+	case want.Billing != got.Billing:
+		return fmt.Sprintf("Billing = %+q, want %+q", got.Billing, want.Billing)
+	case !sameInstant(want.CreatedAt, got.CreatedAt):
+		return fmt.Sprintf("CreatedAt = %v, want %v", got.CreatedAt, want.CreatedAt)
+	case !sameInstant(want.UpdatedAt, got.UpdatedAt):
+		return fmt.Sprintf("UpdatedAt = %v, want %v", got.UpdatedAt, want.UpdatedAt)
+	case len(want.Items) != len(got.Items):
+		return fmt.Sprintf("%d items, want %d", len(got.Items), len(want.Items))
+	}
+	for i := range want.Items {
+		if want.Items[i] != got.Items[i] {
+			return fmt.Sprintf("item %d = %+v, want %+v", i, got.Items[i], want.Items[i])
+		}
+	}
+	return ""
+}
+
+// sameInstant is Equal on the wall clocks alone; Equal compares monotonic
+// readings when both times have one.
+func sameInstant(a, b time.Time) bool { return a.Round(0).Equal(b.Round(0)) }
@@ -0,0 +1,33 @@
+package ordergen
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew_Reproducible(t *testing.T) {
+	r1, r2 := rand.New(rand.NewPCG(1, 2)), rand.New(rand.NewPCG(1, 2))
+	var monotonic int
+	for i := 0; i < 200; i++ {
+		a, b := New(r1), New(r2)
+		if d := Diff(a, b); d != "" {
+			t.Fatalf("order %d differs between runs with the same seed: %s", i, d)
+		}
+		for _, ts := range [][2]time.Time{{a.CreatedAt, b.CreatedAt}, {a.UpdatedAt, b.UpdatedAt}} {
+			if got, want := ts[1].Format(time.RFC3339Nano), ts[0].Format(time.RFC3339Nano); got != want {
+				t.Fatalf("order %d: time %s, want %s", i, got, want)
+			}
+			if strings.Contains(ts[0].String(), " m=") {
+				monotonic++
+				if ts[0].Location() != time.Local {
+					t.Fatalf("monotonic time %v is not in the local zone", ts[0])
+				}
+			}
+		}
+	}
+	if monotonic == 0 {
+		t.Fatal("no generated time carries a monotonic reading")
+	}
+}
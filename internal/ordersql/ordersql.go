@@ -0,0 +1,74 @@
+// Package ordersql holds the SQL schema and statements shared by the
+// database/sql-backed repositories. The column set mirrors the OrderHeader
+// and OrderItemRow shapes, with a line number added to the item primary key.
+package ordersql
+
+import "database/sql"
+
+const (
+	CreateOrders = `CREATE TABLE orders (
+		id TEXT, customer_first TEXT, customer_last TEXT, customer_email TEXT, customer_phone TEXT,
+		loyalty_tier TEXT, loyalty_points INTEGER,
+		street1 TEXT, street2 TEXT, city TEXT, state TEXT, zip TEXT,
+		bill_street1 TEXT, bill_street2 TEXT, bill_city TEXT, bill_state TEXT, bill_zip TEXT,
+		created_at INTEGER, updated_at INTEGER,
+		PRIMARY KEY (id))`
+
+	CreateOrderItems = `CREATE TABLE order_items (
+		order_id TEXT, line INTEGER, sku TEXT, quantity INTEGER,
+		price_cents INTEGER, currency TEXT, backorder BOOLEAN, digital BOOLEAN,
+		PRIMARY KEY (order_id, line))`
+
+	// SelectOrder and UpdateOrder list the non-key columns in the same order
+	// as InsertOrder; the id comes first in SelectOrder and InsertOrder and
+	// last in UpdateOrder.
+	SelectOrder = `SELECT id, customer_first, customer_last, customer_email, customer_phone, loyalty_tier, loyalty_points,
+		street1, street2, city, state, zip, bill_street1, bill_street2, bill_city, bill_state, bill_zip, created_at, updated_at
+		FROM orders WHERE id = ?`
+
+	InsertOrder = `INSERT INTO orders (id, customer_first, customer_last, customer_email, customer_phone, loyalty_tier, loyalty_points,
+		street1, street2, city, state, zip, bill_street1, bill_street2, bill_city, bill_state, bill_zip, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	UpdateOrder = `UPDATE orders SET customer_first = ?, customer_last = ?, customer_email = ?, customer_phone = ?, loyalty_tier = ?, loyalty_points = ?,
+		street1 = ?, street2 = ?, city = ?, state = ?, zip = ?, bill_street1 = ?, bill_street2 = ?, bill_city = ?, bill_state = ?, bill_zip = ?,
+		created_at = ?, updated_at = ?
+		WHERE id = ?`
+
+	SelectOrderIDs = `SELECT id FROM orders`
+
+	SelectItems = `SELECT sku, quantity, price_cents, currency, backorder, digital
+		FROM order_items WHERE order_id = ? ORDER BY line`
+
+	InsertItem = `INSERT INTO order_items (order_id, line, sku, quantity, price_cents, currency, backorder, digital)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	DeleteItems = `DELETE FROM order_items WHERE order_id = ?`
+)
+
+// CreateSchema creates the orders and order_items tables.
+func CreateSchema(db *sql.DB) error {
+	if _, err := db.Exec(CreateOrders); err != nil {
+		return err
+	}
+	_, err := db.Exec(CreateOrderItems)
+	return err
+}
+
+// SelectIDs returns every order ID, for benchmarks to iterate.
+func SelectIDs(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(SelectOrderIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,340 @@
+// Package profile reads the pprof profiles that go test -cpuprofile and
+// -memprofile write, and totals them per function. It decodes the protobuf
+// by hand and keeps only what a per-function summary needs: sample types,
+// sample values and the function names on each stack.
+package profile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ValueType names one of the values each sample carries, such as
+// "alloc_space" in "bytes" or "cpu" in "nanoseconds".
+type ValueType struct {
+	Type, Unit string
+}
+
+// Sample is one stack and its values, one per sample type.
+type Sample struct {
+	Stack  []string // function names, leaf first, inlined calls expanded
+	Values []int64
+}
+
+// Profile is a decoded pprof profile.
+type Profile struct {
+	SampleTypes []ValueType
+	Samples     []Sample
+}
+
+// Totals are a function's flat and cumulative values: flat counts samples
+// where the function is the leaf, cum those where it is anywhere on the
+// stack.
+type Totals struct {
+	Flat, Cum int64
+}
+
+// ReadFile parses the profile at path.
+func ReadFile(path string) (*Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse decodes a profile, gzipped as the runtime writes it or not.
+func Parse(r io.Reader) (*Profile, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// Index returns the position of the named sample type in each sample's
+// values, or -1.
+func (p *Profile) Index(sampleType string) int {
+	for i, vt := range p.SampleTypes {
+		if vt.Type == sampleType {
+			return i
+		}
+	}
+	return -1
+}
+
+// Total sums value i over all samples.
+func (p *Profile) Total(i int) int64 {
+	var t int64
+	for _, s := range p.Samples {
+		t += s.Values[i]
+	}
+	return t
+}
+
+// ByFunction totals value i per function for the functions keep accepts, or
+// all of them if keep is nil. Each sample's value is flat for the innermost
+// kept function on its stack, so time or allocations in code that keep
+// rejects, such as the runtime or encoding/json, are charged to the kept
+// function that called it. A function that appears more than once on a
+// stack, through recursion or inlining, counts once towards cum.
+func (p *Profile) ByFunction(i int, keep func(name string) bool) map[string]Totals {
+	out := make(map[string]Totals)
+	seen := make(map[string]bool)
+	for _, s := range p.Samples {
+		v := s.Values[i]
+		if v == 0 {
+			continue
+		}
+		clear(seen)
+		leaf := true
+		for _, fn := range s.Stack {
+			if seen[fn] || (keep != nil && !keep(fn)) {
+				continue
+			}
+			seen[fn] = true
+			t := out[fn]
+			if leaf {
+				t.Flat += v
+				leaf = false
+			}
+			t.Cum += v
+			out[fn] = t
+		}
+	}
+	return out
+}
+
+// Field numbers from profile.proto.
+const (
+	profSampleType  = 1
+	profSample      = 2
+	profLocation    = 4
+	profFunction    = 5
+	profStringTable = 6
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+
+	functionID   = 1
+	functionName = 2
+)
+
+var errTruncated = errors.New("profile: truncated or malformed protobuf")
+
+type rawSample struct {
+	locs   []uint64
+	values []int64
+}
+
+func decode(data []byte) (*Profile, error) {
+	var (
+		strs      []string
+		types     [][2]int64 // string indexes of type and unit
+		samples   []rawSample
+		locFuncs  = make(map[uint64][]uint64) // location ID to function IDs, leaf first
+		funcNames = make(map[uint64]int64)    // function ID to string index
+	)
+	err := fields(data, func(num int, wire int, v uint64, b []byte) error {
+		switch num {
+		case profStringTable:
+			strs = append(strs, string(b))
+		case profSampleType:
+			var vt [2]int64
+			err := fields(b, func(num, wire int, v uint64, _ []byte) error {
+				switch num {
+				case valueTypeType:
+					vt[0] = int64(v)
+				case valueTypeUnit:
+					vt[1] = int64(v)
+				}
+				return nil
+			})
+			types = append(types, vt)
+			return err
+		case profSample:
+			var s rawSample
+			err := fields(b, func(num, wire int, v uint64, b []byte) error {
+				switch num {
+				case sampleLocationID:
+					return repeated(wire, v, b, func(x uint64) { s.locs = append(s.locs, x) })
+				case sampleValue:
+					return repeated(wire, v, b, func(x uint64) { s.values = append(s.values, int64(x)) })
+				}
+				return nil
+			})
+			samples = append(samples, s)
+			return err
+		case profLocation:
+			var id uint64
+			var funcs []uint64
+			err := fields(b, func(num, wire int, v uint64, b []byte) error {
+				switch num {
+				case locationID:
+					id = v
+				case locationLine:
+					return fields(b, func(num, wire int, v uint64, _ []byte) error {
+						if num == lineFunctionID {
+							funcs = append(funcs, v)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			locFuncs[id] = funcs
+			return err
+		case profFunction:
+			var id uint64
+			var name int64
+			err := fields(b, func(num, wire int, v uint64, _ []byte) error {
+				switch num {
+				case functionID:
+					id = v
+				case functionName:
+					name = int64(v)
+				}
+				return nil
+			})
+			funcNames[id] = name
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	str := func(i int64) (string, error) {
+		if i < 0 || i >= int64(len(strs)) {
+			return "", fmt.Errorf("profile: string index %d out of range", i)
+		}
+		return strs[i], nil
+	}
+	p := &Profile{SampleTypes: make([]ValueType, len(types))}
+	for i, vt := range types {
+		t, err := str(vt[0])
+		if err != nil {
+			return nil, err
+		}
+		u, err := str(vt[1])
+		if err != nil {
+			return nil, err
+		}
+		p.SampleTypes[i] = ValueType{t, u}
+	}
+	p.Samples = make([]Sample, 0, len(samples))
+	for _, rs := range samples {
+		if len(rs.values) != len(types) {
+			return nil, fmt.Errorf("profile: sample has %d values for %d sample types", len(rs.values), len(types))
+		}
+		s := Sample{Values: rs.values}
+		for _, loc := range rs.locs {
+			for _, fn := range locFuncs[loc] {
+				name, err := str(funcNames[fn])
+				if err != nil {
+					return nil, err
+				}
+				s.Stack = append(s.Stack, name)
+			}
+		}
+		p.Samples = append(p.Samples, s)
+	}
+	return p, nil
+}
+
+// fields calls f for each field in the message b. Varints are passed as v,
+// length-delimited fields as b; fixed-width fields are skipped.
+func fields(b []byte, f func(num, wire int, v uint64, b []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		num, wire := int(key>>3), int(key&7)
+		var (
+			v   uint64
+			val []byte
+		)
+		switch wire {
+		case 0:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			b = b[8:]
+			continue
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errTruncated
+			}
+			val, b = b[n:n+int(l)], b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			b = b[4:]
+			continue
+		default:
+			return fmt.Errorf("profile: unsupported wire type %d", wire)
+		}
+		if err := f(num, wire, v, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repeated decodes a repeated varint field, which encoders may write packed
+// or as one field per element.
+func repeated(wire int, v uint64, b []byte, add func(uint64)) error {
+	if wire == 0 {
+		add(v)
+		return nil
+	}
+	for len(b) > 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		add(x)
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+var sink [][]byte
+
+//go:noinline
+func allocate(n int) {
+	for i := 0; i < n; i++ {
+		sink = append(sink, make([]byte, 1024))
+	}
+}
+
+func TestParse_HeapProfile(t *testing.T) {
+	defer func(rate int) { runtime.MemProfileRate = rate }(runtime.MemProfileRate)
+	runtime.MemProfileRate = 1
+	allocate(1000)
+	runtime.GC() // the profile reports allocations as of the last GC
+	var buf bytes.Buffer
+	if err := pprof.Lookup("allocs").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := p.Index("alloc_space")
+	if i < 0 || p.SampleTypes[i].Unit != "bytes" {
+		t.Fatalf("sample types = %v", p.SampleTypes)
+	}
+	var got Totals
+	for name, tot := range p.ByFunction(i, nil) {
+		if strings.HasSuffix(name, "internal/profile.allocate") {
+			got = tot
+		}
+	}
+	if got.Flat < 1000*1024 || got.Cum < got.Flat {
+		t.Fatalf("allocate totals = %+v, want at least %d bytes flat", got, 1000*1024)
+	}
+	if p.Total(i) < got.Cum {
+		t.Fatalf("Total = %d, less than allocate's %d", p.Total(i), got.Cum)
+	}
+}
+
+// enc builds protobuf messages for tests.
+type enc []byte
+
+func (e enc) varint(num int, v uint64) enc {
+	e = binary.AppendUvarint(e, uint64(num)<<3)
+	return binary.AppendUvarint(e, v)
+}
+
+func (e enc) bytes(num int, b []byte) enc {
+	e = binary.AppendUvarint(e, uint64(num)<<3|2)
+	e = binary.AppendUvarint(e, uint64(len(b)))
+	return append(e, b...)
+}
+
+func (e enc) packed(num int, vs ...uint64) enc {
+	var b []byte
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, v)
+	}
+	return e.bytes(num, b)
+}
+
+func TestParse_FlatAndCum(t *testing.T) {
+	// Strings: 0 "", 1 samples, 2 count, 3 main, 4 f, 5 g.
+	var p enc
+	for _, s := range []string{"", "samples", "count", "main", "f", "g"} {
+		p = p.bytes(profStringTable, []byte(s))
+	}
+	p = p.bytes(profSampleType, enc{}.varint(valueTypeType, 1).varint(valueTypeUnit, 2))
+	for id, name := range map[uint64]uint64{1: 3, 2: 4, 3: 5} {
+		p = p.bytes(profFunction, enc{}.varint(functionID, id).varint(functionName, name))
+	}
+	// Location 10 is g inlined into f; location 11 is main; location 12 is f.
+	p = p.bytes(profLocation, enc{}.varint(locationID, 10).
+		bytes(locationLine, enc{}.varint(lineFunctionID, 3)).
+		bytes(locationLine, enc{}.varint(lineFunctionID, 2)))
+	p = p.bytes(profLocation, enc{}.varint(locationID, 11).bytes(locationLine, enc{}.varint(lineFunctionID, 1)))
+	p = p.bytes(profLocation, enc{}.varint(locationID, 12).bytes(locationLine, enc{}.varint(lineFunctionID, 2)))
+	// main -> f -> g (inlined): 5, packed. main -> f -> f -> g: 3, unpacked.
+	p = p.bytes(profSample, enc{}.packed(sampleLocationID, 10, 11).packed(sampleValue, 5))
+	p = p.bytes(profSample, enc{}.varint(sampleLocationID, 10).varint(sampleLocationID, 12).
+		varint(sampleLocationID, 11).varint(sampleValue, 3))
+
+	prof, err := Parse(bytes.NewReader(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := prof.Samples[1].Stack; strings.Join(got, " ") != "g f f main" {
+		t.Fatalf("stack = %v", got)
+	}
+	want := map[string]Totals{"g": {8, 8}, "f": {0, 8}, "main": {0, 8}}
+	check := func(got, want map[string]Totals) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("ByFunction = %v, want %v", got, want)
+		}
+		for fn, w := range want {
+			if got[fn] != w {
+				t.Errorf("%s = %+v, want %+v", fn, got[fn], w)
+			}
+		}
+	}
+	check(prof.ByFunction(0, nil), want)
+	// Without g, its samples are f's own.
+	check(prof.ByFunction(0, func(fn string) bool { return fn != "g" }), map[string]Totals{"f": {8, 8}, "main": {0, 8}})
+
+	// Prefixes that end on a field boundary decode; the rest must fail
+	// rather than panic.
+	for n := range p {
+		Parse(bytes.NewReader(p[:n]))
+	}
+}
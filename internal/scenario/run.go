@@ -0,0 +1,196 @@
+package scenario
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/hist"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
+)
+
+// Target is a repository under test. Update loads, changes and saves an
+// order; i is the operation's sequence number, which implementations may
+// fold into the change.
+type Target interface {
+	Create(o workload.Order) error
+	Read(id string) error
+	Update(id string, i int) error
+	Delete(id string) error
+}
+
+// NewTarget returns the blob repository of the named variant, one of
+// variant.Names, backed by a fresh in-memory store. Orders are stamped by c.
+func NewTarget(name string, c clock.Clock) (Target, error) {
+	switch name {
+	case "direct":
+		return variant.Direct{Repo: direct.NewDirectRepo(direct.WithClock(c)), Clock: c}, nil
+	case "encap":
+		return variant.Encap{Repo: encap.NewRepo(encap.WithClock(c)), Clock: c}, nil
+	case "directflat":
+		return variant.DirectFlat{Repo: directflat.NewRepo(directflat.WithClock(c)), Clock: c}, nil
+	}
+	return nil, fmt.Errorf("scenario: unknown variant %q", name)
+}
+
+// Op is a kind of operation.
+type Op int
+
+const (
+	Read Op = iota
+	Update
+	Create
+	Delete
+	NumOps
+)
+
+func (op Op) String() string {
+	switch op {
+	case Read:
+		return "read"
+	case Update:
+		return "update"
+	case Create:
+		return "create"
+	case Delete:
+		return "delete"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Scenario string
+	Elapsed  time.Duration // measured operations only, excluding preload
+	Latency  [NumOps]hist.Histogram
+	Live     int // orders remaining at the end
+}
+
+// Ops returns the number of measured operations.
+func (r *Report) Ops() uint64 {
+	var n uint64
+	for i := range r.Latency {
+		n += r.Latency[i].Count()
+	}
+	return n
+}
+
+// WriteTable writes one line per operation that ran, with its count and
+// latency percentiles.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "op\tcount\tmean\tp50\tp90\tp99\tp99.9\tmax\t\n")
+	for op := Op(0); op < NumOps; op++ {
+		h := &r.Latency[op]
+		if h.Count() == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%v\t%v\t%v\t%v\t\n", op, h.Count(), h.Mean(),
+			h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), h.Quantile(0.999), h.Max())
+	}
+	return tw.Flush()
+}
+
+// Run preloads t, then performs spec.Ops operations chosen by the mix and
+// times each one. Reads, updates and deletes only target orders that exist;
+// while none do, they run as creates. The first failing operation ends the
+// run.
+func Run(spec Spec, t Target) (*Report, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	items, _ := spec.Items.dist()
+	cfg := workload.Default()
+	cfg.Seed = spec.Seed
+	cfg.Items = items
+	gen := workload.New(cfg)
+
+	live := make([]string, 0, spec.Preload)
+	for i := 0; i < spec.Preload; i++ {
+		o := gen.Order()
+		if err := t.Create(o); err != nil {
+			return nil, fmt.Errorf("scenario: preload: %w", err)
+		}
+		live = append(live, o.ID)
+	}
+
+	r := rand.New(rand.NewPCG(spec.Seed, 1))
+	pick := newPicker(spec.Keys, r)
+	m := spec.Mix
+	cum := [NumOps]float64{m.Read, m.Read + m.Update, m.Read + m.Update + m.Create, m.Read + m.Update + m.Create + m.Delete}
+	rep := &Report{Scenario: spec.Name}
+	start := time.Now()
+	for i := 0; i < spec.Ops; i++ {
+		x := r.Float64() * cum[NumOps-1]
+		op := Read
+		for op < NumOps-1 && x >= cum[op] {
+			op++
+		}
+		if len(live) == 0 {
+			op = Create
+		}
+		var (
+			err error
+			k   int
+			o   workload.Order
+		)
+		if op == Create {
+			o = gen.Order()
+		} else {
+			k = pick(len(live))
+		}
+		began := time.Now()
+		switch op {
+		case Read:
+			err = t.Read(live[k])
+		case Update:
+			err = t.Update(live[k], i)
+		case Create:
+			err = t.Create(o)
+		case Delete:
+			err = t.Delete(live[k])
+		}
+		rep.Latency[op].Record(time.Since(began))
+		if err != nil {
+			return rep, fmt.Errorf("scenario: op %d (%v): %w", i, op, err)
+		}
+		switch op {
+		case Create:
+			live = append(live, o.ID)
+		case Delete:
+			live[k] = live[len(live)-1]
+			live = live[:len(live)-1]
+		}
+	}
+	rep.Elapsed = time.Since(start)
+	rep.Live = len(live)
+	return rep, nil
+}
+
+// newPicker returns a function choosing an index below n.
+func newPicker(k Keys, r *rand.Rand) func(n int) int {
+	if k.Dist != "zipf" {
+		return r.IntN
+	}
+	skew := k.Skew
+	if skew == 0 {
+		skew = 1.1
+	}
+	var (
+		z     *rand.Zipf
+		zipfN int
+	)
+	return func(n int) int {
+		if z == nil || zipfN != n { // the live set grew or shrank
+			z, zipfN = rand.NewZipf(r, skew, 1, uint64(n-1)), n
+		}
+		return int(z.Uint64())
+	}
+}
@@ -0,0 +1,187 @@
+package scenario
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/variant"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, json string
+		wantErr    string
+	}{
+		{"minimal", `{"ops": 10, "mix": {"read": 1}}`, ""},
+		{"full", `{"name": "x", "seed": 3, "preload": 5, "ops": 10, "mix": {"read": 1, "update": 2, "create": 3, "delete": 4},
+			"keys": {"dist": "zipf", "skew": 1.5}, "items": {"dist": "geometric", "min": 1, "mean": 4, "max": 9}}`, ""},
+		{"unknown field", `{"ops": 10, "mix": {"read": 1, "rmw": 1}}`, "unknown field"},
+		{"empty mix", `{"ops": 10, "mix": {}}`, "no operations"},
+		{"negative weight", `{"ops": 10, "mix": {"read": 1, "delete": -1}}`, "non-negative"},
+		{"negative ops", `{"ops": -1, "mix": {"read": 1}}`, "negative"},
+		{"bad keys", `{"ops": 1, "mix": {"read": 1}, "keys": {"dist": "pareto"}}`, "key distribution"},
+		{"flat zipf", `{"ops": 1, "mix": {"read": 1}, "keys": {"dist": "zipf", "skew": 1}}`, "greater than 1"},
+		{"items without dist", `{"ops": 1, "mix": {"read": 1}, "items": {"min": 3}}`, "needs a dist"},
+		{"empty uniform", `{"ops": 1, "mix": {"read": 1}, "items": {"dist": "uniform", "min": 3, "max": 2}}`, "empty"},
+		{"bad geometric", `{"ops": 1, "mix": {"read": 1}, "items": {"dist": "geometric", "min": 3, "mean": 2}}`, "inconsistent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.json))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Parse error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_Scenarios(t *testing.T) {
+	paths, err := filepath.Glob("../../scenarios/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenarios found: %v", err)
+	}
+	for _, p := range paths {
+		if _, err := Load(p); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// fakeTarget records the operations it receives and fails on unknown IDs.
+type fakeTarget struct {
+	orders map[string]int // ID to item count
+	calls  []string
+	failOn Op
+}
+
+func newFake() *fakeTarget { return &fakeTarget{orders: map[string]int{}, failOn: -1} }
+
+func (f *fakeTarget) Create(o workload.Order) error {
+	f.orders[o.ID] = len(o.Items)
+	f.calls = append(f.calls, "create "+o.ID)
+	if f.failOn == Create {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (f *fakeTarget) exists(op, id string) error {
+	f.calls = append(f.calls, op+" "+id)
+	if _, ok := f.orders[id]; !ok {
+		return errors.New(op + " of missing order " + id)
+	}
+	return nil
+}
+
+func (f *fakeTarget) Read(id string) error          { return f.exists("read", id) }
+func (f *fakeTarget) Update(id string, i int) error { return f.exists("update", id) }
+func (f *fakeTarget) Delete(id string) error {
+	err := f.exists("delete", id)
+	delete(f.orders, id)
+	return err
+}
+
+func TestRun_Mix(t *testing.T) {
+	spec := Spec{Seed: 7, Preload: 100, Ops: 20000, Mix: Mix{Read: 6, Update: 2, Create: 1, Delete: 1}}
+	f := newFake()
+	rep, err := Run(spec, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Ops() != uint64(spec.Ops) {
+		t.Fatalf("Ops = %d, want %d", rep.Ops(), spec.Ops)
+	}
+	for op, want := range map[Op]float64{Read: 0.6, Update: 0.2, Create: 0.1, Delete: 0.1} {
+		if got := float64(rep.Latency[op].Count()) / float64(spec.Ops); math.Abs(got-want) > 0.02 {
+			t.Errorf("%v fraction = %.3f, want about %.1f", op, got, want)
+		}
+	}
+	if rep.Live != len(f.orders) {
+		t.Errorf("Live = %d, target holds %d", rep.Live, len(f.orders))
+	}
+}
+
+func TestRun_Deterministic(t *testing.T) {
+	spec := Spec{Seed: 3, Preload: 10, Ops: 500, Mix: Mix{Read: 1, Update: 1, Create: 1, Delete: 1}, Keys: Keys{Dist: "zipf"}}
+	a, b := newFake(), newFake()
+	if _, err := Run(spec, a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(spec, b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(a.calls, "\n") != strings.Join(b.calls, "\n") {
+		t.Fatal("two runs of the same spec issued different operations")
+	}
+}
+
+func TestRun_EmptyBecomesCreate(t *testing.T) {
+	spec := Spec{Ops: 50, Mix: Mix{Delete: 1}}
+	f := newFake()
+	rep, err := Run(spec, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, d := rep.Latency[Create].Count(), rep.Latency[Delete].Count(); c != 25 || d != 25 {
+		t.Fatalf("creates, deletes = %d, %d; want alternating 25, 25", c, d)
+	}
+}
+
+func TestRun_ItemsSize(t *testing.T) {
+	spec := Spec{Preload: 50, Ops: 0, Mix: Mix{Read: 1}, Items: Size{Dist: "const", Min: 7}}
+	f := newFake()
+	if _, err := Run(spec, f); err != nil {
+		t.Fatal(err)
+	}
+	for id, n := range f.orders {
+		if n != 7 {
+			t.Fatalf("order %s has %d items, want 7", id, n)
+		}
+	}
+}
+
+func TestRun_StopsOnError(t *testing.T) {
+	f := newFake()
+	f.failOn = Create
+	_, err := Run(Spec{Ops: 10, Mix: Mix{Create: 1}}, f)
+	if err == nil || !strings.Contains(err.Error(), "op 0 (create)") {
+		t.Fatalf("Run error = %v, want the first create's failure", err)
+	}
+}
+
+func TestRun_Variants(t *testing.T) {
+	spec := Spec{Seed: 1, Preload: 50, Ops: 2000, Mix: Mix{Read: 4, Update: 4, Create: 1, Delete: 1}, Keys: Keys{Dist: "zipf"}}
+	for _, name := range variant.Names {
+		t.Run(name, func(t *testing.T) {
+			target, err := NewTarget(name, clock.NewMonotonicFake(time.Time{}, time.Nanosecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rep, err := Run(spec, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if err := rep.WriteTable(&b); err != nil {
+				t.Fatal(err)
+			}
+			for _, op := range []string{"read", "update", "create", "delete"} {
+				if !strings.Contains(b.String(), op) {
+					t.Errorf("report lacks %s:\n%s", op, b.String())
+				}
+			}
+		})
+	}
+	if _, err := NewTarget("nope", nil); err == nil {
+		t.Error("NewTarget accepted an unknown variant")
+	}
+}
@@ -0,0 +1,144 @@
+// Package scenario describes mixed read/write workloads declaratively and
+// runs them against a repository, recording a latency histogram per
+// operation. A Spec can be written as a Go literal or loaded from JSON:
+//
+//	{
+//	  "name": "read-heavy",
+//	  "preload": 1000,
+//	  "ops": 100000,
+//	  "mix": {"read": 0.8, "update": 0.15, "create": 0.04, "delete": 0.01},
+//	  "keys": {"dist": "zipf", "skew": 1.2},
+//	  "items": {"dist": "geometric", "min": 1, "mean": 3, "max": 50}
+//	}
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
+)
+
+// Spec describes one workload.
+type Spec struct {
+	Name    string `json:"name"`
+	Seed    uint64 `json:"seed"`    // for the orders and the operation sequence
+	Preload int    `json:"preload"` // orders created before measuring
+	Ops     int    `json:"ops"`     // measured operations
+	Mix     Mix    `json:"mix"`
+	Keys    Keys   `json:"keys"`
+	Items   Size   `json:"items"` // line items per created order
+}
+
+// Mix weighs the operations; weights need not sum to 1. Update is a
+// read-modify-write: load, change, save.
+type Mix struct {
+	Read   float64 `json:"read"`
+	Update float64 `json:"update"`
+	Create float64 `json:"create"`
+	Delete float64 `json:"delete"`
+}
+
+// Keys chooses which existing order a read, update or delete targets.
+type Keys struct {
+	// Dist is "uniform" (the default) or "zipf", which makes a few orders
+	// hot. Orders are ranked by creation, oldest first, with deleted orders'
+	// ranks taken over by later ones.
+	Dist string  `json:"dist"`
+	Skew float64 `json:"skew"` // zipf exponent, greater than 1; default 1.1
+}
+
+// Size is a distribution of aggregate sizes. The zero Size uses the
+// workload default.
+type Size struct {
+	Dist string  `json:"dist"` // "const", "uniform" or "geometric"
+	Min  int     `json:"min"`  // the const value, or the lower bound
+	Max  int     `json:"max"`  // upper bound; for geometric, 0 is unbounded
+	Mean float64 `json:"mean"` // geometric only
+}
+
+// Load reads a JSON spec from path.
+func Load(path string) (Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Spec{}, err
+	}
+	defer f.Close()
+	spec, err := Parse(f)
+	if err != nil {
+		return Spec{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// Parse decodes a JSON spec and validates it. Unknown fields are errors, so
+// typos do not silently fall back to defaults.
+func Parse(r io.Reader) (Spec, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var spec Spec
+	if err := dec.Decode(&spec); err != nil {
+		return Spec{}, err
+	}
+	return spec, spec.Validate()
+}
+
+// Validate reports the first problem with the spec.
+func (s Spec) Validate() error {
+	m := s.Mix
+	weights := []float64{m.Read, m.Update, m.Create, m.Delete}
+	sum := 0.0
+	for _, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("scenario: mix weight %v is not a non-negative number", w)
+		}
+		sum += w
+	}
+	switch {
+	case sum == 0:
+		return errors.New("scenario: mix has no operations")
+	case s.Ops < 0 || s.Preload < 0:
+		return errors.New("scenario: ops and preload must not be negative")
+	}
+	switch s.Keys.Dist {
+	case "", "uniform":
+	case "zipf":
+		if s.Keys.Skew != 0 && s.Keys.Skew <= 1 {
+			return fmt.Errorf("scenario: zipf skew %v must be greater than 1", s.Keys.Skew)
+		}
+	default:
+		return fmt.Errorf("scenario: unknown key distribution %q", s.Keys.Dist)
+	}
+	_, err := s.Items.dist()
+	return err
+}
+
+func (s Size) dist() (workload.Dist, error) {
+	switch s.Dist {
+	case "":
+		if s != (Size{}) {
+			return nil, errors.New("scenario: items needs a dist")
+		}
+		return workload.Default().Items, nil
+	case "const":
+		if s.Min < 0 {
+			return nil, fmt.Errorf("scenario: items const %d is negative", s.Min)
+		}
+		return workload.Const(s.Min), nil
+	case "uniform":
+		if s.Min < 0 || s.Max < s.Min {
+			return nil, fmt.Errorf("scenario: items uniform [%d, %d] is empty or negative", s.Min, s.Max)
+		}
+		return workload.Uniform{Min: s.Min, Max: s.Max}, nil
+	case "geometric":
+		if s.Min < 0 || s.Mean < float64(s.Min) || (s.Max != 0 && s.Max < s.Min) {
+			return nil, fmt.Errorf("scenario: items geometric min %d, mean %v, max %d is inconsistent", s.Min, s.Mean, s.Max)
+		}
+		return workload.Geometric{Min: s.Min, Mean: s.Mean, Max: s.Max}, nil
+	}
+	return nil, fmt.Errorf("scenario: unknown items distribution %q", s.Dist)
+}
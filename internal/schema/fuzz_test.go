@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// FuzzUpgrade feeds arbitrary blobs to Unwrap and Upgrade. Either may fail,
+// but must not panic. Unwrap only reports versions from 1 up, and data
+// Upgrade migrated is a JSON object. Whatever Upgrade returns is at the
+// current version: wrapped and upgraded again, it comes back unchanged.
+func FuzzUpgrade(f *testing.F) {
+	for _, blob := range []string{
+		`{"Addr":{"Street":"12 Main St\nApt 4","Zip":"94000"}}`,
+		`{"N":12345678901234567}`,
+		`{"v":2,"data":{"Addr":{"Street1":"a","Street2":"b"}}}`,
+		`{"v":3,"data":{"Phone":"555"}}`,
+		`{"data":{"Phone":"555"},"v":3}`,
+		`{ "v": 2, "data": {"A":1} }`,
+		`{"v":3,"data":}`,
+		`{"v":4,"data":{}}`,
+		`{"v":-1,"data":{}}`,
+		`{"v":2}`,
+		`{"Addr":{"Street":7}}`,
+		`null`,
+		`[1]`,
+		``,
+	} {
+		f.Add([]byte(blob))
+	}
+	f.Fuzz(func(t *testing.T, blob []byte) {
+		if _, v, err := Unwrap(blob); err == nil && v < 1 {
+			t.Fatalf("Unwrap(%q) version = %d, want at least 1", blob, v)
+		}
+		data, from, err := testChain.Upgrade(blob)
+		if err != nil {
+			return
+		}
+		if from < testChain.Current() && !json.Valid(data) {
+			t.Fatalf("Upgrade(%q) from %d = %q, not JSON", blob, from, data)
+		}
+		again, v, err := testChain.Upgrade(testChain.Wrap(nil, data))
+		if err != nil {
+			t.Fatalf("upgrading wrapped %q: %v", data, err)
+		}
+		if v != testChain.Current() || !bytes.Equal(again, data) {
+			t.Fatalf("wrapped %q upgraded to %q from %d, want it unchanged from %d", data, again, v, testChain.Current())
+		}
+	})
+}
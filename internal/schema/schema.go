@@ -0,0 +1,176 @@
+// Package schema versions the blobs written by the JSON repositories. Each
+// blob is wrapped in an envelope that records the version of its data:
+//
+//	{"v":3,"data":{...}}
+//
+// Blobs written before versioning have no envelope and are read as version
+// 1. On read, a Chain of upcasters migrates older data one version at a time
+// until it matches the shape the repository decodes.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownVersion is returned for blobs written by a newer schema than the
+// reader knows.
+var ErrUnknownVersion = errors.New("unknown schema version")
+
+// Envelope is the stored form of a versioned blob.
+type Envelope[T any] struct {
+	V    int `json:"v"`
+	Data T   `json:"data"`
+}
+
+// Upcaster migrates a decoded record from one version to the next in place.
+type Upcaster func(doc map[string]any) error
+
+// Chain migrates records from any earlier version to the current one. The
+// upcaster at index i migrates version i+1 to version i+2, so the current
+// version is one more than the number of upcasters.
+type Chain struct {
+	steps []Upcaster
+}
+
+func NewChain(steps ...Upcaster) *Chain { return &Chain{steps: steps} }
+
+func (c *Chain) Current() int { return len(c.steps) + 1 }
+
+// Wrap appends an envelope holding data at the current version to b.
+func (c *Chain) Wrap(b, data []byte) []byte {
+	b = append(b, `{"v":`...)
+	b = strconv.AppendInt(b, int64(c.Current()), 10)
+	b = append(b, `,"data":`...)
+	b = append(b, data...)
+	return append(b, '}')
+}
+
+// Upgrade returns blob's data migrated to the current version, along with the
+// version it was stored at. Data already at the current version is returned
+// without copying.
+func (c *Chain) Upgrade(blob []byte) (data []byte, from int, err error) {
+	data, from, err = Unwrap(blob)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from == c.Current() {
+		return data, from, nil
+	}
+	if from > c.Current() {
+		return nil, from, fmt.Errorf("schema: %w %d (current is %d)", ErrUnknownVersion, from, c.Current())
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep int64 nanos exact
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, from, err
+	}
+	if doc == nil {
+		return nil, from, fmt.Errorf("schema: version %d data is not an object", from)
+	}
+	for _, step := range c.steps[from-1:] {
+		if err := step(doc); err != nil {
+			return nil, from, err
+		}
+	}
+	data, err = json.Marshal(doc)
+	return data, from, err
+}
+
+// Unwrap splits a blob into its data and version. The envelope written by
+// Wrap or by marshaling an Envelope is recognised without decoding the data,
+// so damage inside it surfaces when the caller decodes it; anything else is
+// decoded to look for an envelope.
+func Unwrap(blob []byte) (data []byte, version int, err error) {
+	if rest, ok := bytes.CutPrefix(blob, []byte(`{"v":`)); ok {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if v, err := strconv.Atoi(string(rest[:i])); err == nil && v > 0 {
+			if data, ok := bytes.CutPrefix(rest[i:], []byte(`,"data":`)); ok && len(data) > 0 && data[len(data)-1] == '}' {
+				return data[:len(data)-1], v, nil
+			}
+		}
+	}
+	var env Envelope[json.RawMessage]
+	if err := json.Unmarshal(blob, &env); err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case env.V == 0:
+		return blob, 1, nil // unversioned
+	case env.V < 0:
+		return nil, env.V, fmt.Errorf("schema: %w %d", ErrUnknownVersion, env.V)
+	case env.Data == nil:
+		return nil, env.V, errors.New("schema: envelope has no data")
+	}
+	return env.Data, env.V, nil
+}
+
+// Steps combines upcasters into one that applies each in turn, for versions
+// that change several fields at once.
+func Steps(steps ...Upcaster) Upcaster {
+	return func(doc map[string]any) error {
+		for _, step := range steps {
+			if err := step(doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// SplitLines replaces the string member at path with members named first and
+// second, holding the text before and after its first newline. Records
+// without the member are left alone.
+func SplitLines(path, first, second string) Upcaster {
+	return func(doc map[string]any) error {
+		obj, name := parent(doc, path)
+		v, ok := obj[name]
+		if !ok {
+			return nil
+		}
+		s, ok := v.(string)
+		if !ok && v != nil {
+			return fmt.Errorf("schema: %s is %T, not a string", path, v)
+		}
+		line1, line2, _ := strings.Cut(s, "\n")
+		delete(obj, name)
+		obj[first], obj[second] = line1, line2
+		return nil
+	}
+}
+
+// Default sets the member at path to v if it is absent.
+func Default(path string, v any) Upcaster {
+	return func(doc map[string]any) error {
+		obj, name := parent(doc, path)
+		if obj == nil {
+			return nil
+		}
+		if _, ok := obj[name]; !ok {
+			obj[name] = v
+		}
+		return nil
+	}
+}
+
+// parent returns the object holding the member at the dot-separated path and
+// the member's name; obj is nil if an enclosing object is missing.
+func parent(doc map[string]any, path string) (obj map[string]any, name string) {
+	obj = doc
+	for {
+		elem, rest, nested := strings.Cut(path, ".")
+		if !nested {
+			return obj, elem
+		}
+		obj, _ = obj[elem].(map[string]any)
+		path = rest
+	}
+}
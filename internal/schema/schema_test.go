@@ -0,0 +1,91 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+)
+
+var testChain = NewChain(
+	SplitLines("Addr.Street", "Street1", "Street2"),
+	Default("Phone", ""),
+)
+
+func TestUnwrap(t *testing.T) {
+	cases := []struct {
+		name     string
+		blob     string
+		wantData string
+		wantV    int
+	}{
+		{"wrapped", `{"v":3,"data":{"A":1}}`, `{"A":1}`, 3},
+		{"reordered", `{"data":{"A":1},"v":2}`, `{"A":1}`, 2},
+		{"spaced", `{ "v": 2, "data": {"A":1} }`, `{"A":1}`, 2},
+		{"unversioned", `{"A":1}`, `{"A":1}`, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, v, err := Unwrap([]byte(tc.blob))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.wantData || v != tc.wantV {
+				t.Fatalf("Unwrap = %s, %d; want %s, %d", data, v, tc.wantData, tc.wantV)
+			}
+		})
+	}
+}
+
+func TestUnwrap_Malformed(t *testing.T) {
+	for _, blob := range []string{``, `{"v":3,"data":`, `{"v":2}`, `{"v":-1,"data":{}}`, `[1]`} {
+		if data, v, err := Unwrap([]byte(blob)); err == nil {
+			t.Errorf("Unwrap(%s) = %s, %d; want error", blob, data, v)
+		}
+	}
+}
+
+func TestChain_Upgrade(t *testing.T) {
+	cases := []struct {
+		name, blob, want string
+		from             int
+	}{
+		{"v1", `{"Addr":{"Street":"12 Main St\nApt 4","Zip":"94000"}}`, `{"Addr":{"Street1":"12 Main St","Street2":"Apt 4","Zip":"94000"},"Phone":""}`, 1},
+		{"v1 single line", `{"Addr":{"Street":"12 Main St"}}`, `{"Addr":{"Street1":"12 Main St","Street2":""},"Phone":""}`, 1},
+		{"v1 without member", `{"N":12345678901234567}`, `{"N":12345678901234567,"Phone":""}`, 1},
+		{"v2", `{"v":2,"data":{"Addr":{"Street1":"a","Street2":"b"}}}`, `{"Addr":{"Street1":"a","Street2":"b"},"Phone":""}`, 2},
+		{"current", `{"v":3,"data":{"Phone":"555"}}`, `{"Phone":"555"}`, 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, from, err := testChain.Upgrade([]byte(tc.blob))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.want || from != tc.from {
+				t.Fatalf("Upgrade = %s, %d; want %s, %d", data, from, tc.want, tc.from)
+			}
+		})
+	}
+}
+
+func TestChain_UpgradeErrors(t *testing.T) {
+	if _, _, err := testChain.Upgrade([]byte(`{"v":4,"data":{}}`)); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("newer version: err = %v, want ErrUnknownVersion", err)
+	}
+	if _, _, err := testChain.Upgrade([]byte(`{"Addr":{"Street":7}}`)); err == nil {
+		t.Error("non-string street: want error")
+	}
+	if _, _, err := testChain.Upgrade([]byte(`null`)); err == nil {
+		t.Error("null record: want error")
+	}
+}
+
+func TestChain_WrapRoundTrip(t *testing.T) {
+	blob := testChain.Wrap(nil, []byte(`{"Phone":"555"}`))
+	if string(blob) != `{"v":3,"data":{"Phone":"555"}}` {
+		t.Fatalf("Wrap = %s", blob)
+	}
+	data, v, err := Unwrap(blob)
+	if err != nil || string(data) != `{"Phone":"555"}` || v != 3 {
+		t.Fatalf("Unwrap(Wrap) = %s, %d, %v", data, v, err)
+	}
+}
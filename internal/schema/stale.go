@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"sync"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+)
+
+// Stale remembers blobs that were read at an old version, so a repository
+// can rewrite them at the current version on its next Save instead of
+// upcasting them on every read or running a separate migration.
+//
+// Stale holds at most MaxPending blobs. Add drops blobs past that; they are
+// still upcast on every read, and are added again by a read once Flush has
+// made room.
+type Stale struct {
+	mu      sync.Mutex
+	pending map[string]staleBlob
+	max     int
+}
+
+// Limits for the Stale a repository keeps, so reading many old blobs
+// between saves neither grows it without bound nor makes one Save rewrite
+// them all.
+const (
+	MaxPending = 4096 // blobs a Stale holds
+	FlushBatch = 64   // blobs a repository's Save rewrites
+)
+
+type staleBlob struct {
+	old      []byte // as read; the rewrite is skipped if the key has moved on
+	upgraded []byte // enveloped at the current version
+}
+
+func NewStale() *Stale { return &Stale{pending: make(map[string]staleBlob), max: MaxPending} }
+
+// Add records that key was read as old and upgrades to upgraded, unless
+// MaxPending other blobs are already waiting.
+func (s *Stale) Add(key string, old, upgraded []byte) {
+	s.mu.Lock()
+	if _, ok := s.pending[key]; ok || len(s.pending) < s.max {
+		s.pending[key] = staleBlob{old: old, upgraded: upgraded}
+	}
+	s.mu.Unlock()
+}
+
+// Forget drops key, for a Save that has just rewritten it anyway.
+func (s *Stale) Forget(key string) {
+	s.mu.Lock()
+	delete(s.pending, key)
+	s.mu.Unlock()
+}
+
+// Len returns the number of blobs waiting to be rewritten.
+func (s *Stale) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Flush writes the upgraded form of up to n pending blobs, or of all of
+// them if n is not positive. Each is written only if it is still stored as
+// it was read, comparing and writing atomically through CompareAndPut so a
+// concurrent Save is never overwritten; blobs overwritten or deleted since
+// are dropped. If a write fails, Flush returns the error and requeues the
+// blobs it has not written, unless they were added again meanwhile.
+func (s *Stale) Flush(store blobstore.Store, n int) error {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	if n <= 0 || n > len(s.pending) {
+		n = len(s.pending)
+	}
+	batch := make([]staleEntry, 0, n)
+	for key, sb := range s.pending {
+		if len(batch) == n {
+			break
+		}
+		batch = append(batch, staleEntry{key: key, staleBlob: sb})
+		delete(s.pending, key)
+	}
+	s.mu.Unlock()
+	for i, e := range batch {
+		if _, err := store.CompareAndPut(e.key, e.old, e.upgraded); err != nil {
+			s.requeue(batch[i:])
+			return err
+		}
+	}
+	return nil
+}
+
+type staleEntry struct {
+	key string
+	staleBlob
+}
+
+// requeue returns blobs a failed Flush did not write to the pending set,
+// keeping any newer Add for the same key. It may take the set past its cap
+// by as many blobs as Flush took out.
+func (s *Stale) requeue(batch []staleEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range batch {
+		if _, ok := s.pending[e.key]; !ok {
+			s.pending[e.key] = e.staleBlob
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+)
+
+func TestStale_FlushRewritesOnlyUnchangedBlobs(t *testing.T) {
+	store := blobstore.NewMemory()
+	_ = store.Put("same", []byte("v1"))
+	_ = store.Put("moved", []byte("v1"))
+	s := NewStale()
+	s.Add("same", []byte("v1"), []byte("v2"))
+	s.Add("moved", []byte("v1"), []byte("v2"))
+	s.Add("deleted", []byte("v1"), []byte("v2"))
+	_ = store.Put("moved", []byte("newer"))
+
+	if err := s.Flush(store, 0); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"same": "v2", "moved": "newer"} {
+		if got, _ := store.Get(key); string(got) != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if _, err := store.Get("deleted"); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("deleted key was recreated: %v", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len = %d after Flush, want 0", s.Len())
+	}
+}
+
+// TestStale_FlushNeverOverwritesConcurrentSave races Flush against writers
+// that replace every stale blob; the writers' blobs must survive.
+func TestStale_FlushNeverOverwritesConcurrentSave(t *testing.T) {
+	const n = 200
+	store := blobstore.NewMemory()
+	s := NewStale()
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint(i)
+		_ = store.Put(key, []byte("old"))
+		s.Add(key, []byte("old"), []byte("upgraded"))
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			_ = store.Put(fmt.Sprint(i), []byte("saved"))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := s.Flush(store, 0); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		if got, _ := store.Get(fmt.Sprint(i)); string(got) != "saved" {
+			t.Fatalf("key %d = %q, want the concurrent save", i, got)
+		}
+	}
+}
+
+// failingStore fails CompareAndPut once it has succeeded ok times.
+type failingStore struct {
+	*blobstore.Memory
+	ok int
+}
+
+func (s *failingStore) CompareAndPut(key string, old, blob []byte) (bool, error) {
+	if s.ok == 0 {
+		return false, errors.New("disk full")
+	}
+	s.ok--
+	return s.Memory.CompareAndPut(key, old, blob)
+}
+
+func TestStale_FlushRequeuesAfterError(t *testing.T) {
+	store := &failingStore{Memory: blobstore.NewMemory(), ok: 1}
+	s := NewStale()
+	for _, key := range []string{"a", "b", "c"} {
+		_ = store.Put(key, []byte("old"))
+		s.Add(key, []byte("old"), []byte("upgraded"))
+	}
+	if err := s.Flush(store, 0); err == nil {
+		t.Fatal("Flush succeeded, want the store's error")
+	}
+	if got := s.Len(); got != 2 {
+		t.Fatalf("Len after failed Flush = %d, want the 2 unwritten blobs", got)
+	}
+
+	store.ok = 2
+	if err := s.Flush(store, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if got, _ := store.Get(key); string(got) != "upgraded" {
+			t.Errorf("%s = %q after retry, want upgraded", key, got)
+		}
+	}
+}
+
+func TestStale_AddDropsPastMaxPending(t *testing.T) {
+	s := NewStale()
+	s.max = 2
+	s.Add("a", []byte("v1"), []byte("v2"))
+	s.Add("b", []byte("v1"), []byte("v2"))
+	s.Add("c", []byte("v1"), []byte("v2"))
+	s.Add("a", []byte("v1"), []byte("v2 again")) // already pending; replaced
+	if got := s.Len(); got != 2 {
+		t.Fatalf("Len = %d, want 2", got)
+	}
+	store := blobstore.NewMemory()
+	for _, key := range []string{"a", "b", "c"} {
+		_ = store.Put(key, []byte("v1"))
+	}
+	if err := s.Flush(store, 0); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"a": "v2 again", "b": "v2", "c": "v1"} {
+		if got, _ := store.Get(key); string(got) != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestStale_FlushWritesAtMostN(t *testing.T) {
+	store := blobstore.NewMemory()
+	s := NewStale()
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprint(i)
+		_ = store.Put(key, []byte("old"))
+		s.Add(key, []byte("old"), []byte("upgraded"))
+	}
+	for _, want := range []int{3, 1, 0} {
+		if err := s.Flush(store, 2); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Len(); got != want {
+			t.Fatalf("Len after Flush = %d, want %d", got, want)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if got, _ := store.Get(fmt.Sprint(i)); string(got) != "upgraded" {
+			t.Errorf("key %d = %q, want upgraded", i, got)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Package sqlfake is a database/sql driver backed by in-memory tables. It
+// understands just enough SQL to store and load the OrderHeader and
+// OrderItemRow shapes (see parse.go for the grammar), so repositories can be
+// benchmarked through database/sql's placeholder binding, driver.Value
+// conversion and sql.Rows scanning without a real database.
+package sqlfake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// Open returns a handle to a new, empty in-memory database.
+//
+// Transactions are serializable in the simplest way: each holds a lock on
+// the whole database from Begin until Commit or Rollback, so statements and
+// transactions on other connections wait for it to end. Read-only
+// transactions share a read lock, and writes in them fail. A goroutine that
+// queries the database outside its own open transaction deadlocks, as does
+// one that writes outside its own read-only transaction.
+func Open() *sql.DB { return sql.OpenDB(&Connector{s: newStore()}) }
+
+// Connector connects to one in-memory database.
+type Connector struct{ s *store }
+
+func (c *Connector) Connect(context.Context) (driver.Conn, error) { return &conn{s: c.s}, nil }
+func (c *Connector) Driver() driver.Driver                        { return drv{} }
+
+type drv struct{}
+
+func (drv) Open(string) (driver.Conn, error) {
+	return nil, errors.New("sqlfake: use sqlfake.Open to create a database")
+}
+
+type conn struct {
+	s  *store
+	tx *tx
+}
+
+var (
+	_ driver.ConnBeginTx       = (*conn)(nil)
+	_ driver.ExecerContext     = (*conn)(nil)
+	_ driver.QueryerContext    = (*conn)(nil)
+	_ driver.StmtExecContext   = (*stmt)(nil)
+	_ driver.StmtQueryContext  = (*stmt)(nil)
+	_ driver.SessionResetter   = (*conn)(nil)
+	_ driver.NamedValueChecker = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	st, err := c.s.parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{c: c, st: st}, nil
+}
+
+func (c *conn) Close() error {
+	if c.tx != nil {
+		return c.tx.Rollback()
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("sqlfake: transaction already in progress")
+	}
+	if opts.ReadOnly {
+		c.s.mu.RLock()
+	} else {
+		c.s.mu.Lock()
+	}
+	c.tx = &tx{c: c, readOnly: opts.ReadOnly}
+	return c.tx, nil
+}
+
+func (c *conn) ResetSession(context.Context) error { return nil }
+
+// CheckNamedValue applies the default conversions (int to int64 and so on).
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	st, err := c.s.parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return c.exec(st, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	st, err := c.s.parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return c.query(st, args)
+}
+
+func (c *conn) exec(st *statement, named []driver.NamedValue) (driver.Result, error) {
+	args, err := values(st, named)
+	if err != nil {
+		return nil, err
+	}
+	var n int64
+	if c.tx != nil {
+		if c.tx.readOnly {
+			return nil, errors.New("sqlfake: write in read-only transaction")
+		}
+		n, err = c.s.exec(st, args, &c.tx.log)
+	} else {
+		c.s.mu.Lock()
+		n, err = c.s.exec(st, args, nil)
+		c.s.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (c *conn) query(st *statement, named []driver.NamedValue) (driver.Rows, error) {
+	args, err := values(st, named)
+	if err != nil {
+		return nil, err
+	}
+	if c.tx == nil {
+		c.s.mu.RLock()
+		defer c.s.mu.RUnlock()
+	}
+	return c.s.query(st, args)
+}
+
+func values(st *statement, named []driver.NamedValue) ([]driver.Value, error) {
+	if len(named) != st.nargs {
+		return nil, errors.New("sqlfake: wrong number of arguments")
+	}
+	args := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, errors.New("sqlfake: named arguments are not supported")
+		}
+		args[i] = nv.Value
+	}
+	return args, nil
+}
+
+type stmt struct {
+	c  *conn
+	st *statement
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return s.st.nargs }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.exec(s.st, toNamed(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.query(s.st, toNamed(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.exec(s.st, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.query(s.st, args)
+}
+
+func toNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// tx holds the store's lock for its lifetime, the read lock if it is
+// read-only, and undoes its writes on rollback.
+type tx struct {
+	c        *conn
+	readOnly bool
+	log      []undo
+}
+
+func (t *tx) Commit() error {
+	if t.c.tx != t {
+		return sql.ErrTxDone
+	}
+	t.end()
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	if t.c.tx != t {
+		return sql.ErrTxDone
+	}
+	rollback(t.log)
+	t.end()
+	return nil
+}
+
+func (t *tx) end() {
+	t.c.tx = nil
+	t.log = nil
+	if t.readOnly {
+		t.c.s.mu.RUnlock()
+	} else {
+		t.c.s.mu.Unlock()
+	}
+}
+
+// rows is a materialized result set; vals holds len(cols) values per row.
+type rows struct {
+	cols []string
+	vals []driver.Value
+	pos  int
+}
+
+func (r *rows) Columns() []string { return r.cols }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.vals) {
+		return io.EOF
+	}
+	r.pos += copy(dest, r.vals[r.pos:r.pos+len(r.cols)])
+	return nil
+}
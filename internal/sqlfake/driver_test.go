@@ -0,0 +1,221 @@
+package sqlfake
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func openTable(t *testing.T) *sql.DB {
+	t.Helper()
+	db := Open()
+	t.Cleanup(func() { db.Close() })
+	mustExec(t, db, "CREATE TABLE kv (k TEXT, n INTEGER, v TEXT, PRIMARY KEY (k, n))")
+	return db
+}
+
+func mustExec(t *testing.T, db interface {
+	Exec(string, ...any) (sql.Result, error)
+}, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// storedValues returns the v column of every row, ordered by primary key.
+func storedValues(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT v FROM kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestTx_CommitAndRollback(t *testing.T) {
+	db := openTable(t)
+	mustExec(t, db, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "a", 1, "kept")
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, tx, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "b", 1, "added")
+	mustExec(t, tx, "UPDATE kv SET v = ? WHERE k = ?", "changed", "a")
+	mustExec(t, tx, "DELETE FROM kv WHERE k = ?", "b")
+	mustExec(t, tx, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "b", 2, "re-added")
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storedValues(t, db), []string{"kept"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after rollback: %q, want %q", got, want)
+	}
+	if err := tx.Commit(); err != sql.ErrTxDone {
+		t.Fatalf("Commit after Rollback = %v, want sql.ErrTxDone", err)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, tx, "UPDATE kv SET v = ? WHERE k = ?", "changed", "a")
+	mustExec(t, tx, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "b", 1, "added")
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storedValues(t, db), []string{"changed", "added"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after commit: %q, want %q", got, want)
+	}
+}
+
+func TestTx_ReadOnly(t *testing.T) {
+	db := openTable(t)
+	mustExec(t, db, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "a", 1, "kept")
+	ctx := context.Background()
+
+	// Read-only transactions share the lock, so two can be open at once.
+	var txs []*sql.Tx
+	for range 2 {
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	for i, tx := range txs {
+		var v string
+		if err := tx.QueryRow("SELECT v FROM kv WHERE k = ?", "a").Scan(&v); err != nil || v != "kept" {
+			t.Errorf("tx %d read %q, %v; want kept", i, v, err)
+		}
+	}
+	_, err := txs[0].Exec("INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "b", 1, "added")
+	if err == nil || !strings.Contains(err.Error(), "read-only transaction") {
+		t.Errorf("write in read-only transaction = %v, want read-only error", err)
+	}
+	if err := txs[0].Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := txs[1].Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both read locks are released.
+	mustExec(t, db, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "b", 1, "added")
+	if got, want := storedValues(t, db), []string{"kept", "added"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestExec_DuplicatePrimaryKey(t *testing.T) {
+	db := openTable(t)
+	mustExec(t, db, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "a", 1, "first")
+	_, err := db.Exec("INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "a", 1, "second")
+	if err == nil || !strings.Contains(err.Error(), "duplicate primary key") {
+		t.Fatalf("duplicate insert = %v, want duplicate primary key error", err)
+	}
+	// The other key column tells rows apart.
+	mustExec(t, db, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "a", 2, "other")
+
+	// A failed insert inside a transaction leaves earlier writes to the
+	// caller's Commit or Rollback.
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, tx, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "b", 1, "in tx")
+	if _, err := tx.Exec("INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "a", 1, "dup"); err == nil {
+		t.Fatal("duplicate insert in transaction succeeded")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storedValues(t, db), []string{"first", "other"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestExec_KeysWithNUL(t *testing.T) {
+	db := Open()
+	defer db.Close()
+	mustExec(t, db, "CREATE TABLE pairs (a TEXT, b TEXT, v TEXT, PRIMARY KEY (a, b))")
+	// Were key parts NUL-terminated, both rows would have the key
+	// "sx\x00sy\x00sz\x00".
+	rows := []struct{ a, b, v string }{
+		{"x\x00sy", "z", "first"},
+		{"x", "y\x00sz", "second"},
+	}
+	for _, r := range rows {
+		mustExec(t, db, "INSERT INTO pairs (a, b, v) VALUES (?, ?, ?)", r.a, r.b, r.v)
+	}
+	for _, r := range rows {
+		var got string
+		if err := db.QueryRow("SELECT v FROM pairs WHERE a = ? AND b = ?", r.a, r.b).Scan(&got); err != nil {
+			t.Fatalf("(%q, %q): %v", r.a, r.b, err)
+		}
+		if got != r.v {
+			t.Errorf("(%q, %q): v = %q, want %q", r.a, r.b, got, r.v)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	db := openTable(t)
+
+	// Arguments bind to placeholders in order, after database/sql's
+	// default conversions (int to int64 here).
+	mustExec(t, db, "INSERT INTO kv (v, n, k) VALUES (?, ?, ?)", "value", 7, "key")
+	var (
+		k string
+		n int64
+	)
+	if err := db.QueryRow("SELECT k, n FROM kv WHERE v = ?", "value").Scan(&k, &n); err != nil {
+		t.Fatal(err)
+	}
+	if k != "key" || n != 7 {
+		t.Fatalf("row = (%q, %d), want (key, 7)", k, n)
+	}
+
+	// Stored byte slices do not alias the caller's.
+	b := []byte("bytes")
+	mustExec(t, db, "INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", "b", 1, b)
+	copy(b, "XXXXX")
+	var v string
+	if err := db.QueryRow("SELECT v FROM kv WHERE k = ?", "b").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != "bytes" {
+		t.Fatalf("v = %q after caller reused its slice, want bytes", v)
+	}
+
+	tests := []struct {
+		name string
+		args []any
+		err  string
+	}{
+		{"too few", []any{"k", 1}, "wrong number of arguments"},
+		{"too many", []any{"k", 1, "v", "extra"}, "wrong number of arguments"},
+		{"named", []any{sql.Named("k", "k"), 1, "v"}, "named arguments are not supported"},
+		{"unsupported type", []any{"k", struct{}{}, "v"}, "unsupported type"},
+	}
+	for _, tt := range tests {
+		_, err := db.Exec("INSERT INTO kv (k, n, v) VALUES (?, ?, ?)", tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want error containing %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package sqlfake
+
+import (
+	"fmt"
+	"strings"
+)
+
+type stmtKind int
+
+const (
+	kindCreate stmtKind = iota
+	kindInsert
+	kindUpdate
+	kindSelect
+	kindDelete
+)
+
+// statement is a parsed SQL statement. Every value is bound through a ?
+// placeholder; literals are not supported.
+//
+//	CREATE TABLE t (c1 TYPE, c2 TYPE, ..., PRIMARY KEY (c1, ...))
+//	INSERT INTO t (c1, c2, ...) VALUES (?, ?, ...)
+//	UPDATE t SET c1 = ?, c2 = ? [WHERE ...]
+//	SELECT c1, c2 FROM t [WHERE ...] [ORDER BY c]
+//	DELETE FROM t [WHERE ...]
+//
+// WHERE clauses are conjunctions of "col op ?" with op one of = < <= > >=.
+type statement struct {
+	kind    stmtKind
+	table   string
+	cols    []string // columns created, inserted, set or selected
+	pk      []string // CREATE TABLE only
+	where   []cond
+	orderBy string
+	nargs   int
+}
+
+type cond struct {
+	col string
+	op  string
+}
+
+func parse(query string) (*statement, error) {
+	p := &parser{toks: tokenize(query), query: query}
+	st, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return st, nil
+}
+
+type parser struct {
+	query string
+	toks  []string
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("sqlfake: %s in %q", fmt.Sprintf(format, args...), p.query)
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.toks) }
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// accept consumes the next token if it matches kw case-insensitively.
+func (p *parser) accept(kw string) bool {
+	if strings.EqualFold(p.peek(), kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kws ...string) error {
+	for _, kw := range kws {
+		if !p.accept(kw) {
+			return p.errorf("expected %s, got %q", kw, p.peek())
+		}
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t == "" || !isIdentStart(t[0]) {
+		return "", p.errorf("expected identifier, got %q", t)
+	}
+	return strings.ToLower(t), nil
+}
+
+func (p *parser) placeholder(st *statement) error {
+	if err := p.expect("?"); err != nil {
+		return err
+	}
+	st.nargs++
+	return nil
+}
+
+func (p *parser) statement() (*statement, error) {
+	switch {
+	case p.accept("CREATE"):
+		return p.create()
+	case p.accept("INSERT"):
+		return p.insert()
+	case p.accept("UPDATE"):
+		return p.update()
+	case p.accept("SELECT"):
+		return p.selectStmt()
+	case p.accept("DELETE"):
+		return p.delete()
+	}
+	return nil, p.errorf("unsupported statement")
+}
+
+func (p *parser) create() (*statement, error) {
+	st := &statement{kind: kindCreate}
+	if err := p.expect("TABLE"); err != nil {
+		return nil, err
+	}
+	var err error
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.accept("PRIMARY") {
+			if err := p.expect("KEY", "("); err != nil {
+				return nil, err
+			}
+			if st.pk, err = p.identList(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		} else {
+			col, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			st.cols = append(st.cols, col)
+			// Column types are accepted for readability but not enforced.
+			for p.peek() != "," && p.peek() != ")" && !p.done() {
+				p.next()
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(st.pk) == 0 {
+		return nil, p.errorf("table %s has no PRIMARY KEY", st.table)
+	}
+	return st, nil
+}
+
+func (p *parser) insert() (*statement, error) {
+	st := &statement{kind: kindInsert}
+	if err := p.expect("INTO"); err != nil {
+		return nil, err
+	}
+	var err error
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if st.cols, err = p.identList(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")", "VALUES", "("); err != nil {
+		return nil, err
+	}
+	for i := range st.cols {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.placeholder(st); err != nil {
+			return nil, err
+		}
+	}
+	return st, p.expect(")")
+}
+
+func (p *parser) update() (*statement, error) {
+	st := &statement{kind: kindUpdate}
+	var err error
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		if err := p.placeholder(st); err != nil {
+			return nil, err
+		}
+		st.cols = append(st.cols, col)
+		if !p.accept(",") {
+			break
+		}
+	}
+	return st, p.where(st)
+}
+
+func (p *parser) selectStmt() (*statement, error) {
+	st := &statement{kind: kindSelect}
+	var err error
+	if st.cols, err = p.identList(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.where(st); err != nil {
+		return nil, err
+	}
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		if st.orderBy, err = p.ident(); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (p *parser) delete() (*statement, error) {
+	st := &statement{kind: kindDelete}
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	return st, p.where(st)
+}
+
+func (p *parser) where(st *statement) error {
+	if !p.accept("WHERE") {
+		return nil
+	}
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return err
+		}
+		op := p.next()
+		switch op {
+		case "=", "<", "<=", ">", ">=":
+		default:
+			return p.errorf("unsupported operator %q", op)
+		}
+		if err := p.placeholder(st); err != nil {
+			return err
+		}
+		st.where = append(st.where, cond{col: col, op: op})
+		if !p.accept("AND") {
+			return nil
+		}
+	}
+}
+
+func (p *parser) identList() ([]string, error) {
+	var ids []string
+	for {
+		id, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		if !p.accept(",") {
+			return ids, nil
+		}
+	}
+}
+
+func tokenize(q string) []string {
+	var toks []string
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(q) && (isIdentStart(q[j]) || (q[j] >= '0' && q[j] <= '9')) {
+				j++
+			}
+			toks = append(toks, q[i:j])
+			i = j
+		case (c == '<' || c == '>') && i+1 < len(q) && q[i+1] == '=':
+			toks = append(toks, q[i:i+2])
+			i += 2
+		default:
+			toks = append(toks, q[i:i+1])
+			i++
+		}
+	}
+	return toks
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,85 @@
+package sqlfake
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  statement
+	}{
+		{
+			query: "CREATE TABLE t (a TEXT, b INTEGER NOT NULL, PRIMARY KEY (a, b))",
+			want:  statement{kind: kindCreate, table: "t", cols: []string{"a", "b"}, pk: []string{"a", "b"}},
+		},
+		{
+			query: "insert into T (A, b) values (?, ?)",
+			want:  statement{kind: kindInsert, table: "t", cols: []string{"a", "b"}, nargs: 2},
+		},
+		{
+			query: "UPDATE t SET b = ?, c = ? WHERE a = ?",
+			want:  statement{kind: kindUpdate, table: "t", cols: []string{"b", "c"}, where: []cond{{"a", "="}}, nargs: 3},
+		},
+		{
+			query: "SELECT a, b FROM t WHERE a = ? AND b >= ? AND b < ? ORDER BY b",
+			want: statement{kind: kindSelect, table: "t", cols: []string{"a", "b"},
+				where: []cond{{"a", "="}, {"b", ">="}, {"b", "<"}}, orderBy: "b", nargs: 3},
+		},
+		{
+			query: "SELECT a FROM t",
+			want:  statement{kind: kindSelect, table: "t", cols: []string{"a"}},
+		},
+		{
+			query: "DELETE FROM t WHERE a <= ?",
+			want:  statement{kind: kindDelete, table: "t", where: []cond{{"a", "<="}}, nargs: 1},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parse(tt.query)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("parse(%q) = %+v, want %+v", tt.query, *got, tt.want)
+		}
+	}
+}
+
+func TestParse_Malformed(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"", "unsupported statement"},
+		{"DROP TABLE t", "unsupported statement"},
+		{"CREATE TABLE t (a TEXT)", "no PRIMARY KEY"},
+		{"CREATE TABLE t (a TEXT, PRIMARY KEY (a)", "expected ),"},
+		{"CREATE t (a TEXT, PRIMARY KEY (a))", "expected TABLE,"},
+		{"INSERT INTO t (a, b) VALUES (?)", "expected ,,"},
+		{"INSERT INTO t (a) VALUES (?, ?)", "expected ),"},
+		{"INSERT INTO t (a) VALUES ('x')", "expected ?,"},
+		{"UPDATE t SET a = 1", "expected ?,"},
+		{"UPDATE t SET WHERE a = ?", "expected =,"},
+		{"SELECT FROM t", "expected FROM,"},
+		{"SELECT a FROM t WHERE a != ?", "unsupported operator"},
+		{"SELECT a FROM t WHERE a = ? OR a = ?", `unexpected "OR"`},
+		{"SELECT a FROM t ORDER a", "expected BY,"},
+		{"SELECT a FROM t ORDER BY 1", "expected identifier"},
+		{"DELETE t", "expected FROM,"},
+		{"DELETE FROM t WHERE", "expected identifier"},
+	}
+	for _, tt := range tests {
+		_, err := parse(tt.query)
+		if err == nil {
+			t.Errorf("parse(%q) succeeded, want error containing %q", tt.query, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parse(%q) = %v, want error containing %q", tt.query, err, tt.err)
+		}
+	}
+}
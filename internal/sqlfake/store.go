@@ -0,0 +1,390 @@
+package sqlfake
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// store holds the tables of one in-memory database. Statements outside a
+// transaction take the lock per statement; a transaction holds the write lock
+// from Begin until Commit or Rollback (see Open).
+type store struct {
+	mu     sync.RWMutex
+	tables map[string]*table
+
+	stmtMu sync.RWMutex
+	stmts  map[string]*statement // parsed statement cache
+}
+
+func newStore() *store {
+	return &store{tables: make(map[string]*table), stmts: make(map[string]*statement)}
+}
+
+func (s *store) parse(query string) (*statement, error) {
+	s.stmtMu.RLock()
+	st, ok := s.stmts[query]
+	s.stmtMu.RUnlock()
+	if ok {
+		return st, nil
+	}
+	st, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	s.stmtMu.Lock()
+	s.stmts[query] = st
+	s.stmtMu.Unlock()
+	return st, nil
+}
+
+type table struct {
+	name   string
+	cols   []string
+	colIdx map[string]int
+	pk     []int
+	rows   map[string][]driver.Value // by encoded primary key
+	// byLead indexes row keys by the encoded value of the first primary key
+	// column, so lookups on a key prefix avoid a full scan.
+	byLead map[string]map[string]struct{}
+}
+
+// undo restores a row to its state before a write in a transaction.
+type undo struct {
+	t   *table
+	key string
+	old []driver.Value // nil if the row did not exist
+}
+
+func (s *store) create(st *statement) error {
+	if _, ok := s.tables[st.table]; ok {
+		return fmt.Errorf("sqlfake: table %s already exists", st.table)
+	}
+	t := &table{name: st.table, cols: st.cols, colIdx: make(map[string]int), rows: make(map[string][]driver.Value), byLead: make(map[string]map[string]struct{})}
+	for i, c := range st.cols {
+		t.colIdx[c] = i
+	}
+	for _, c := range st.pk {
+		i, ok := t.colIdx[c]
+		if !ok {
+			return fmt.Errorf("sqlfake: primary key column %s not in table %s", c, st.table)
+		}
+		t.pk = append(t.pk, i)
+	}
+	s.tables[st.table] = t
+	return nil
+}
+
+func (s *store) table(name string) (*table, error) {
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("sqlfake: no such table %s", name)
+	}
+	return t, nil
+}
+
+func (t *table) col(name string) (int, error) {
+	i, ok := t.colIdx[name]
+	if !ok {
+		return 0, fmt.Errorf("sqlfake: no such column %s.%s", t.name, name)
+	}
+	return i, nil
+}
+
+// exec runs a statement that does not return rows, recording undo entries in
+// log if it is non-nil.
+func (s *store) exec(st *statement, args []driver.Value, log *[]undo) (int64, error) {
+	if st.kind == kindCreate {
+		return 0, s.create(st)
+	}
+	t, err := s.table(st.table)
+	if err != nil {
+		return 0, err
+	}
+	switch st.kind {
+	case kindInsert:
+		row := make([]driver.Value, len(t.cols))
+		for i, c := range st.cols {
+			ci, err := t.col(c)
+			if err != nil {
+				return 0, err
+			}
+			row[ci] = own(args[i])
+		}
+		key := t.key(row)
+		if _, ok := t.rows[key]; ok {
+			return 0, fmt.Errorf("sqlfake: duplicate primary key in %s", t.name)
+		}
+		t.put(key, row, log)
+		return 1, nil
+	case kindUpdate:
+		set := args[:len(st.cols)]
+		keys, err := t.match(st.where, args[len(st.cols):])
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			row := slices.Clone(t.rows[key])
+			for i, c := range st.cols {
+				ci, err := t.col(c)
+				if err != nil {
+					return 0, err
+				}
+				row[ci] = own(set[i])
+			}
+			nk := t.key(row)
+			if nk != key {
+				return 0, fmt.Errorf("sqlfake: updating primary key columns is not supported")
+			}
+			t.put(key, row, log)
+		}
+		return int64(len(keys)), nil
+	case kindDelete:
+		keys, err := t.match(st.where, args)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			t.delete(key, log)
+		}
+		return int64(len(keys)), nil
+	}
+	return 0, fmt.Errorf("sqlfake: statement does not return a result")
+}
+
+// query runs a SELECT and returns copies of the selected columns.
+func (s *store) query(st *statement, args []driver.Value) (*rows, error) {
+	if st.kind != kindSelect {
+		return nil, fmt.Errorf("sqlfake: statement does not return rows")
+	}
+	t, err := s.table(st.table)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := t.match(st.where, args)
+	if err != nil {
+		return nil, err
+	}
+	if st.orderBy != "" {
+		oi, err := t.col(st.orderBy)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			c, _ := compare(t.rows[a][oi], t.rows[b][oi])
+			return c
+		})
+	}
+	idx := make([]int, len(st.cols))
+	for i, c := range st.cols {
+		if idx[i], err = t.col(c); err != nil {
+			return nil, err
+		}
+	}
+	out := &rows{cols: st.cols, vals: make([]driver.Value, 0, len(keys)*len(idx))}
+	for _, key := range keys {
+		row := t.rows[key]
+		for _, ci := range idx {
+			out.vals = append(out.vals, row[ci])
+		}
+	}
+	return out, nil
+}
+
+// match returns the keys of rows satisfying every condition.
+func (t *table) match(where []cond, args []driver.Value) ([]string, error) {
+	idx := make([]int, len(where))
+	lead := -1
+	for i, c := range where {
+		ci, err := t.col(c.col)
+		if err != nil {
+			return nil, err
+		}
+		idx[i] = ci
+		if ci == t.pk[0] && c.op == "=" {
+			lead = i
+		}
+	}
+	var keys []string
+	test := func(key string, row []driver.Value) {
+		for i, c := range where {
+			r, ok := compare(row[idx[i]], args[i])
+			if !ok || !holds(c.op, r) {
+				return
+			}
+		}
+		keys = append(keys, key)
+	}
+	if lead >= 0 {
+		for key := range t.byLead[string(encode(nil, args[lead]))] {
+			test(key, t.rows[key])
+		}
+	} else {
+		for key, row := range t.rows {
+			test(key, row)
+		}
+	}
+	if lead < 0 || len(t.pk) > 1 {
+		slices.Sort(keys) // deterministic order for scans
+	}
+	return keys, nil
+}
+
+func (t *table) key(row []driver.Value) string {
+	var b []byte
+	for _, ci := range t.pk {
+		b = encode(b, row[ci])
+	}
+	return string(b)
+}
+
+func (t *table) put(key string, row []driver.Value, log *[]undo) {
+	old, existed := t.rows[key]
+	if log != nil {
+		*log = append(*log, undo{t: t, key: key, old: old})
+	}
+	t.rows[key] = row
+	if !existed {
+		lead := string(encode(nil, row[t.pk[0]]))
+		set := t.byLead[lead]
+		if set == nil {
+			set = make(map[string]struct{})
+			t.byLead[lead] = set
+		}
+		set[key] = struct{}{}
+	}
+}
+
+func (t *table) delete(key string, log *[]undo) {
+	old, ok := t.rows[key]
+	if !ok {
+		return
+	}
+	if log != nil {
+		*log = append(*log, undo{t: t, key: key, old: old})
+	}
+	delete(t.rows, key)
+	lead := string(encode(nil, old[t.pk[0]]))
+	delete(t.byLead[lead], key)
+	if len(t.byLead[lead]) == 0 {
+		delete(t.byLead, lead)
+	}
+}
+
+// own copies byte slices so stored rows do not alias caller memory.
+func own(v driver.Value) driver.Value {
+	if b, ok := v.([]byte); ok {
+		return slices.Clone(b)
+	}
+	return v
+}
+
+func rollback(log []undo) {
+	for i := len(log) - 1; i >= 0; i-- {
+		u := log[i]
+		if u.old == nil {
+			u.t.delete(u.key, nil)
+		} else {
+			u.t.put(u.key, u.old, nil)
+		}
+	}
+}
+
+// encode appends a type-tagged encoding of v suitable for use in map keys.
+// Strings may hold any byte, including NUL, so they are length-prefixed; the
+// other encodings cannot contain NUL and end with one.
+func encode(b []byte, v driver.Value) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 'n', 0)
+	case int64:
+		b = append(b, 'i')
+		return append(strconv.AppendInt(b, v, 10), 0)
+	case float64:
+		b = append(b, 'f')
+		return append(strconv.AppendFloat(b, v, 'g', -1, 64), 0)
+	case bool:
+		if v {
+			return append(b, 'b', '1', 0)
+		}
+		return append(b, 'b', '0', 0)
+	case string:
+		b = binary.AppendUvarint(append(b, 's'), uint64(len(v)))
+		return append(b, v...)
+	case []byte:
+		b = binary.AppendUvarint(append(b, 's'), uint64(len(v)))
+		return append(b, v...)
+	case time.Time:
+		b = append(b, 't')
+		return append(strconv.AppendInt(b, v.UnixNano(), 10), 0)
+	}
+	return append(b, '?', 0)
+}
+
+// compare orders two values of the same kind; ok is false if they are not comparable.
+func compare(a, b driver.Value) (c int, ok bool) {
+	switch a := a.(type) {
+	case int64:
+		if b, isInt := b.(int64); isInt {
+			return cmp.Compare(a, b), true
+		}
+		if b, isFloat := b.(float64); isFloat {
+			return cmp.Compare(float64(a), b), true
+		}
+	case float64:
+		if b, isFloat := b.(float64); isFloat {
+			return cmp.Compare(a, b), true
+		}
+		if b, isInt := b.(int64); isInt {
+			return cmp.Compare(a, float64(b)), true
+		}
+	case string:
+		switch b := b.(type) {
+		case string:
+			return strings.Compare(a, b), true
+		case []byte:
+			return strings.Compare(a, string(b)), true
+		}
+	case []byte:
+		if b, isString := b.(string); isString {
+			return strings.Compare(string(a), b), true
+		}
+	case bool:
+		if b, isBool := b.(bool); isBool {
+			switch {
+			case a == b:
+				return 0, true
+			case !a:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if b, isTime := b.(time.Time); isTime {
+			return a.Compare(b), true
+		}
+	}
+	return 0, false
+}
+
+func holds(op string, c int) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
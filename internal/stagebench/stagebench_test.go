@@ -0,0 +1,44 @@
+package stagebench
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var sink []byte
+
+func TestRun(t *testing.T) {
+	res := testing.Benchmark(func(b *testing.B) {
+		Run(b,
+			Stage{"none", func(int) error { return nil }},
+			Stage{"alloc", func(int) error { sink = make([]byte, 4096); return nil }},
+			Stage{"sleep", func(int) error { time.Sleep(10 * time.Microsecond); return nil }},
+		)
+	})
+	if res.N == 0 {
+		t.Fatal("benchmark did not run")
+	}
+	for metric, want := range map[string]float64{
+		"none-allocs/op":  0,
+		"alloc-allocs/op": 1,
+		"alloc-B/op":      4096,
+		"sleep-allocs/op": 0,
+	} {
+		if got, ok := res.Extra[metric]; !ok || got != want {
+			t.Errorf("%s = %v (reported %v), want %v", metric, got, ok, want)
+		}
+	}
+	if sleep, none := res.Extra["sleep-ns/op"], res.Extra["none-ns/op"]; sleep < 10e3 || none >= sleep {
+		t.Errorf("sleep-ns/op = %v, none-ns/op = %v", sleep, none)
+	}
+}
+
+func TestRun_StageError(t *testing.T) {
+	res := testing.Benchmark(func(b *testing.B) {
+		Run(b, Stage{"fail", func(int) error { return errors.New("boom") }})
+	})
+	if res.N != 0 {
+		t.Fatalf("benchmark with a failing stage reported %d iterations", res.N)
+	}
+}
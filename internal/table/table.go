@@ -0,0 +1,208 @@
+// Package table is an in-process stand-in for a row-oriented storage engine.
+// A DB holds typed tables keyed by primary key, tables may carry secondary
+// indexes, and writes to any number of tables commit together in a
+// transaction.
+//
+// Rows are stored by value, so writing a row copies it into the table and
+// reading copies it out, much like a driver scanning a result set.
+package table
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// DB groups tables that share a lock and commit together.
+type DB struct {
+	mu sync.RWMutex
+
+	puts    atomic.Int64
+	deletes atomic.Int64
+}
+
+func NewDB() *DB { return &DB{} }
+
+// View runs fn in a read-only transaction.
+func (db *DB) View(fn func(tx *Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(&Tx{})
+}
+
+// Update runs fn in a read-write transaction. Writes are buffered and applied
+// to every table at once when fn returns nil, or discarded if it returns an
+// error. Reads within fn see the state as of the start of the transaction,
+// not the transaction's own pending writes.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tx := Tx{writable: true}
+	if err := fn(&tx); err != nil {
+		return err
+	}
+	for _, w := range tx.writes {
+		w.apply(db)
+	}
+	return nil
+}
+
+// Stats reports the number of row puts and deletes committed so far. Deletes
+// of rows that did not exist are not counted.
+func (db *DB) Stats() (puts, deletes int64) { return db.puts.Load(), db.deletes.Load() }
+
+// Tx is a transaction passed to View and Update callbacks.
+type Tx struct {
+	writable bool
+	writes   []write
+}
+
+// write is a buffered row change; apply makes it and counts it in db's stats.
+type write interface{ apply(db *DB) }
+
+// Table holds rows of type R keyed by a primary key of type K. Scans return
+// rows in primary key order as defined by the table's compare function.
+type Table[K comparable, R any] struct {
+	db      *DB
+	name    string
+	compare func(a, b K) int
+	rows    map[K]R
+	indexes []*Index[K, R]
+}
+
+func NewTable[K comparable, R any](db *DB, name string, compare func(a, b K) int) *Table[K, R] {
+	return &Table[K, R]{db: db, name: name, compare: compare, rows: make(map[K]R)}
+}
+
+func (t *Table[K, R]) Name() string { return t.name }
+
+// Get returns the row with the given primary key.
+func (t *Table[K, R]) Get(tx *Tx, key K) (R, bool) {
+	row, ok := t.rows[key]
+	return row, ok
+}
+
+// Put inserts or replaces the row with the given primary key when tx commits.
+func (t *Table[K, R]) Put(tx *Tx, key K, row R) {
+	mustWritable(tx)
+	tx.writes = append(tx.writes, &put[K, R]{t: t, key: key, row: row})
+}
+
+// Delete removes the row with the given primary key, if any, when tx commits.
+func (t *Table[K, R]) Delete(tx *Tx, key K) {
+	mustWritable(tx)
+	tx.writes = append(tx.writes, &del[K, R]{t: t, key: key})
+}
+
+// Keys returns the table's primary keys in order.
+func (t *Table[K, R]) Keys(tx *Tx) []K {
+	keys := make([]K, 0, len(t.rows))
+	for k := range t.rows {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, t.compare)
+	return keys
+}
+
+func mustWritable(tx *Tx) {
+	if !tx.writable {
+		panic("table: write in read-only transaction")
+	}
+}
+
+type put[K comparable, R any] struct {
+	t   *Table[K, R]
+	key K
+	row R
+}
+
+func (p *put[K, R]) apply(db *DB) {
+	old, existed := p.t.rows[p.key]
+	p.t.rows[p.key] = p.row
+	for _, ix := range p.t.indexes {
+		nv := ix.value(&p.row)
+		if existed {
+			if ov := ix.value(&old); ov != nv {
+				ix.remove(ov, p.key)
+			} else {
+				continue
+			}
+		}
+		ix.insert(nv, p.key)
+	}
+	db.puts.Add(1)
+}
+
+type del[K comparable, R any] struct {
+	t   *Table[K, R]
+	key K
+}
+
+func (d *del[K, R]) apply(db *DB) {
+	old, ok := d.t.rows[d.key]
+	if !ok {
+		return
+	}
+	delete(d.t.rows, d.key)
+	for _, ix := range d.t.indexes {
+		ix.remove(ix.value(&old), d.key)
+	}
+	db.deletes.Add(1)
+}
+
+// Index is a non-unique secondary index on a string column of a table.
+type Index[K comparable, R any] struct {
+	t       *Table[K, R]
+	name    string
+	value   func(row *R) string
+	entries map[string][]K // primary keys per indexed value, in key order
+}
+
+// AddIndex adds a secondary index on the column returned by value. Indexes
+// must be added before any rows are written.
+func (t *Table[K, R]) AddIndex(name string, value func(row *R) string) *Index[K, R] {
+	ix := &Index[K, R]{t: t, name: name, value: value, entries: make(map[string][]K)}
+	t.indexes = append(t.indexes, ix)
+	return ix
+}
+
+// AppendRows appends the rows whose indexed column equals v to dst in primary
+// key order and returns the extended slice.
+func (ix *Index[K, R]) AppendRows(tx *Tx, dst []R, v string) []R {
+	for _, k := range ix.entries[v] {
+		dst = append(dst, ix.t.rows[k])
+	}
+	return dst
+}
+
+// AppendKeys appends the primary keys of the rows whose indexed column equals
+// v to dst in order and returns the extended slice.
+func (ix *Index[K, R]) AppendKeys(tx *Tx, dst []K, v string) []K {
+	return append(dst, ix.entries[v]...)
+}
+
+// Count returns the number of rows whose indexed column equals v.
+func (ix *Index[K, R]) Count(tx *Tx, v string) int { return len(ix.entries[v]) }
+
+func (ix *Index[K, R]) insert(v string, key K) {
+	keys := ix.entries[v]
+	i, found := slices.BinarySearchFunc(keys, key, ix.t.compare)
+	if found {
+		return
+	}
+	ix.entries[v] = slices.Insert(keys, i, key)
+}
+
+func (ix *Index[K, R]) remove(v string, key K) {
+	keys := ix.entries[v]
+	i, found := slices.BinarySearchFunc(keys, key, ix.t.compare)
+	if !found {
+		return
+	}
+	keys = slices.Delete(keys, i, i+1)
+	if len(keys) == 0 {
+		delete(ix.entries, v)
+		return
+	}
+	ix.entries[v] = keys
+}
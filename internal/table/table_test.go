@@ -0,0 +1,130 @@
+package table
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type row struct {
+	Group string
+	N     int
+}
+
+func newTable() (*DB, *Table[string, row], *Index[string, row]) {
+	db := NewDB()
+	t := NewTable[string, row](db, "rows", strings.Compare)
+	ix := t.AddIndex("rows_group", func(r *row) string { return r.Group })
+	return db, t, ix
+}
+
+func update(t *testing.T, db *DB, fn func(tx *Tx)) {
+	t.Helper()
+	if err := db.Update(func(tx *Tx) error { fn(tx); return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// indexed returns the keys and rows ix lists under each of groups.
+func indexed(db *DB, ix *Index[string, row], groups ...string) map[string][]string {
+	got := make(map[string][]string)
+	_ = db.View(func(tx *Tx) error {
+		for _, g := range groups {
+			keys := ix.AppendKeys(tx, nil, g)
+			rows := ix.AppendRows(tx, nil, g)
+			if len(keys) != len(rows) || ix.Count(tx, g) != len(keys) {
+				panic("index keys, rows and count disagree")
+			}
+			if len(keys) > 0 {
+				got[g] = keys
+			}
+		}
+		return nil
+	})
+	return got
+}
+
+func TestIndex_FollowsWrites(t *testing.T) {
+	db, tbl, ix := newTable()
+	update(t, db, func(tx *Tx) {
+		tbl.Put(tx, "c", row{Group: "x"})
+		tbl.Put(tx, "a", row{Group: "x"})
+		tbl.Put(tx, "b", row{Group: "y"})
+	})
+	check := func(what string, want map[string][]string) {
+		t.Helper()
+		got := indexed(db, ix, "x", "y")
+		if len(got) != len(want) {
+			t.Fatalf("%s: index = %v, want %v", what, got, want)
+		}
+		for g, keys := range want {
+			if !slices.Equal(got[g], keys) {
+				t.Fatalf("%s: index = %v, want %v", what, got, want)
+			}
+		}
+	}
+	check("insert", map[string][]string{"x": {"a", "c"}, "y": {"b"}})
+
+	update(t, db, func(tx *Tx) { tbl.Put(tx, "a", row{Group: "x", N: 1}) })
+	check("update keeping the group", map[string][]string{"x": {"a", "c"}, "y": {"b"}})
+
+	update(t, db, func(tx *Tx) { tbl.Put(tx, "c", row{Group: "y"}) })
+	check("update moving the group", map[string][]string{"x": {"a"}, "y": {"b", "c"}})
+
+	update(t, db, func(tx *Tx) { tbl.Delete(tx, "a") })
+	check("delete", map[string][]string{"y": {"b", "c"}})
+
+	err := db.Update(func(tx *Tx) error {
+		tbl.Put(tx, "d", row{Group: "x"})
+		tbl.Put(tx, "b", row{Group: "x"})
+		tbl.Delete(tx, "c")
+		return errors.New("rollback")
+	})
+	if err == nil || err.Error() != "rollback" {
+		t.Fatalf("Update = %v, want the callback's error", err)
+	}
+	check("rollback", map[string][]string{"y": {"b", "c"}})
+	_ = db.View(func(tx *Tx) error {
+		if _, ok := tbl.Get(tx, "d"); ok {
+			t.Error("rolled back insert is visible")
+		}
+		if keys := tbl.Keys(tx); !slices.Equal(keys, []string{"b", "c"}) {
+			t.Errorf("Keys = %q, want [b c]", keys)
+		}
+		return nil
+	})
+}
+
+func TestDB_StatsCountOnlyRowsDeleted(t *testing.T) {
+	db, tbl, _ := newTable()
+	update(t, db, func(tx *Tx) {
+		tbl.Put(tx, "a", row{})
+		tbl.Put(tx, "a", row{N: 1})
+		tbl.Delete(tx, "missing")
+	})
+	update(t, db, func(tx *Tx) {
+		tbl.Delete(tx, "a")
+		tbl.Delete(tx, "a")
+	})
+	_ = db.Update(func(tx *Tx) error {
+		tbl.Put(tx, "b", row{})
+		return errors.New("rollback")
+	})
+	if puts, deletes := db.Stats(); puts != 2 || deletes != 1 {
+		t.Errorf("Stats = %d puts, %d deletes; want 2, 1", puts, deletes)
+	}
+}
+
+func TestView_RejectsWrites(t *testing.T) {
+	db, tbl, _ := newTable()
+	defer func() {
+		if recover() == nil {
+			t.Error("Put in a read-only transaction did not panic")
+		}
+	}()
+	_ = db.View(func(tx *Tx) error {
+		tbl.Put(tx, "a", row{})
+		return nil
+	})
+}
@@ -0,0 +1,178 @@
+// Package variant builds each model variant's aggregate from a workload
+// order and wraps each variant's blob repository in the same four
+// operations, so load generators can drive direct, encap and directflat
+// identically.
+package variant
+
+import (
+	"errors"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/clock"
+	"github.com/alechenninger/go-ddd-bench/internal/workload"
+)
+
+// Names lists the variants in the order reports show them.
+var Names = []string{"direct", "encap", "directflat"}
+
+func NewDirectOrder(c clock.Clock, w workload.Order) *direct.Order {
+	o := &direct.Order{
+		ID: w.ID,
+		Customer: direct.Customer{
+			Name:    direct.Name{First: w.Customer.First, Last: w.Customer.Last},
+			Email:   w.Customer.Email,
+			Phone:   w.Customer.Phone,
+			Loyalty: direct.Loyalty{Tier: w.Customer.LoyaltyTier, Points: w.Customer.LoyaltyPoints},
+		},
+		Shipping:  direct.Address(w.Shipping),
+		Billing:   direct.Address(w.Billing),
+		CreatedAt: clock.Now(c),
+		UpdatedAt: clock.Now(c),
+		Clock:     c,
+	}
+	for _, it := range w.Items {
+		if err := o.AddItem(it.SKU, it.Quantity, it.PriceCents, it.Currency, direct.ItemFlags{Backorder: it.Backorder, Digital: it.Digital}); err != nil {
+			panic(err) // workload quantities are at least 1
+		}
+	}
+	return o
+}
+
+func NewEncapOrder(c clock.Clock, w workload.Order) *encap.Order {
+	cust := encap.SnapshotCustomer{
+		Name:    encap.SnapshotName{First: w.Customer.First, Last: w.Customer.Last},
+		Email:   w.Customer.Email,
+		Phone:   w.Customer.Phone,
+		Loyalty: encap.SnapshotLoyalty{Tier: w.Customer.LoyaltyTier, Points: w.Customer.LoyaltyPoints},
+	}
+	o := encap.NewOrder(c, w.ID, cust, encap.SnapshotAddress(w.Shipping), encap.SnapshotAddress(w.Billing))
+	for _, it := range w.Items {
+		if err := o.AddItem(it.SKU, it.Quantity, it.PriceCents, it.Currency, encap.SnapshotItemFlags{Backorder: it.Backorder, Digital: it.Digital}); err != nil {
+			panic(err) // workload quantities are at least 1
+		}
+	}
+	return o
+}
+
+func NewDirectFlatRecord(c clock.Clock, w workload.Order) *directflat.OrderRecord {
+	cu := w.Customer
+	rec := directflat.NewOrderRecord(c, w.ID, cu.First, cu.Last, cu.Email, cu.LoyaltyTier, cu.LoyaltyPoints)
+	rec.Header.CustomerPhone = cu.Phone
+	s, bl := w.Shipping, w.Billing
+	rec.UpdateShipping(s.Street1, s.Street2, s.City, s.State, s.Zip)
+	rec.UpdateBilling(bl.Street1, bl.Street2, bl.City, bl.State, bl.Zip)
+	for _, it := range w.Items {
+		if err := rec.AddItem(it.SKU, it.Quantity, it.PriceCents, it.Currency, it.Backorder, it.Digital); err != nil {
+			panic(err) // workload quantities are at least 1
+		}
+	}
+	return rec
+}
+
+// toggleSKU is the line item Update adds to an order that lacks it and
+// removes from one that has it, so repeated updates keep aggregates at
+// their generated size.
+const toggleSKU = "UPDATE-TOGGLE"
+
+// Direct drives a direct.DirectRepo.
+type Direct struct {
+	Repo  *direct.DirectRepo
+	Clock clock.Clock
+}
+
+func (d Direct) Create(w workload.Order) error { return d.Repo.Save(NewDirectOrder(d.Clock, w)) }
+
+func (d Direct) Read(id string) error {
+	_, err := d.Repo.FindByID(id)
+	return err
+}
+
+// Update loads the order, toggles a line item, sets the loyalty points to
+// i, and saves it.
+func (d Direct) Update(id string, i int) error {
+	o, err := d.Repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := o.RemoveItem(toggleSKU); errors.Is(err, direct.ErrItemNotFound) {
+		if err := o.AddItem(toggleSKU, 1, 99, "USD", direct.ItemFlags{Digital: true}); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	o.UpdateLoyaltyPoints(i)
+	return d.Repo.Save(o)
+}
+
+func (d Direct) Delete(id string) error { return d.Repo.Delete(id) }
+
+// Encap drives an encap.Repo.
+type Encap struct {
+	Repo  *encap.Repo
+	Clock clock.Clock
+}
+
+func (e Encap) Create(w workload.Order) error { return e.Repo.Save(NewEncapOrder(e.Clock, w)) }
+
+func (e Encap) Read(id string) error {
+	_, err := e.Repo.FindByID(id)
+	return err
+}
+
+// Update loads the order, toggles a line item, sets the loyalty points to
+// i, and saves it.
+func (e Encap) Update(id string, i int) error {
+	o, err := e.Repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := o.RemoveItem(toggleSKU); errors.Is(err, encap.ErrItemNotFound) {
+		if err := o.AddItem(toggleSKU, 1, 99, "USD", encap.SnapshotItemFlags{Digital: true}); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	o.UpdateLoyaltyPoints(i)
+	return e.Repo.Save(o)
+}
+
+func (e Encap) Delete(id string) error { return e.Repo.Delete(id) }
+
+// DirectFlat drives a directflat.Repo.
+type DirectFlat struct {
+	Repo  *directflat.Repo
+	Clock clock.Clock
+}
+
+func (f DirectFlat) Create(w workload.Order) error {
+	return f.Repo.Save(NewDirectFlatRecord(f.Clock, w))
+}
+
+func (f DirectFlat) Read(id string) error {
+	_, err := f.Repo.FindByID(id)
+	return err
+}
+
+// Update loads the record, toggles an item row, sets the loyalty points to
+// i, and saves it.
+func (f DirectFlat) Update(id string, i int) error {
+	rec, err := f.Repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := rec.RemoveItem(toggleSKU); errors.Is(err, directflat.ErrItemNotFound) {
+		if err := rec.AddItem(toggleSKU, 1, 99, "USD", false, true); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	rec.UpdateLoyaltyPoints(i)
+	return f.Repo.Save(rec)
+}
+
+func (f DirectFlat) Delete(id string) error { return f.Repo.Delete(id) }
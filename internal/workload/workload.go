@@ -0,0 +1,299 @@
+// Package workload generates reproducible benchmark data. A Generator is
+// driven by a seeded PRNG, so the same Config yields the same IDs and orders
+// on every run, and its orders vary the way production data does: names,
+// streets and SKUs of differing lengths, optional second address lines and
+// phone numbers, and item counts, quantities and prices drawn from
+// configurable distributions.
+//
+// Orders are expressed in a variant-neutral shape without timestamps; each
+// benchmark builds its variant's aggregate from them through the aggregate's
+// own constructor and clock.
+package workload
+
+import (
+	"encoding/hex"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// Order is the business content of one generated order.
+type Order struct {
+	ID                string
+	Customer          Customer
+	Shipping, Billing Address
+	Items             []Item
+}
+
+type Customer struct {
+	First, Last, Email, Phone string
+	LoyaltyTier               string
+	LoyaltyPoints             int
+}
+
+type Address struct{ Street1, Street2, City, State, Zip string }
+
+type Item struct {
+	SKU                string
+	Quantity           int
+	PriceCents         int64
+	Currency           string
+	Backorder, Digital bool
+}
+
+// Dist is a distribution of non-negative integers.
+type Dist interface {
+	Int(r *rand.Rand) int
+}
+
+// Const always returns its value.
+type Const int
+
+func (c Const) Int(*rand.Rand) int { return int(c) }
+
+// Uniform returns values in [Min, Max]. A Max below Min is clamped to Min.
+type Uniform struct{ Min, Max int }
+
+func (u Uniform) Int(r *rand.Rand) int {
+	if u.Max < u.Min {
+		return u.Min
+	}
+	return u.Min + r.IntN(u.Max-u.Min+1)
+}
+
+// Geometric returns Min plus a geometrically distributed count, so small
+// values dominate with a long tail; the mean is Mean. Values above Max are
+// clamped if Max is positive.
+type Geometric struct {
+	Min  int
+	Mean float64
+	Max  int
+}
+
+func (g Geometric) Int(r *rand.Rand) int {
+	extra := g.Mean - float64(g.Min)
+	n := g.Min
+	if extra > 0 {
+		p := 1 / (extra + 1) // success probability for a mean of extra failures
+		n += int(math.Floor(math.Log(1-r.Float64()) / math.Log(1-p)))
+	}
+	if g.Max > 0 && n > g.Max {
+		n = g.Max
+	}
+	return n
+}
+
+// LogNormal returns values whose logarithm is normally distributed around
+// log(Median), the usual shape of prices. Results are at least 1.
+type LogNormal struct {
+	Median float64
+	Sigma  float64
+}
+
+func (l LogNormal) Int(r *rand.Rand) int {
+	v := math.Round(l.Median * math.Exp(l.Sigma*r.NormFloat64()))
+	return int(max(v, 1))
+}
+
+// Config controls what a Generator produces. Probabilities are in [0, 1].
+type Config struct {
+	Seed uint64
+
+	Items      Dist // line items per order
+	Quantity   Dist // units per line item
+	PriceCents Dist // unit price per line item
+	Catalog    int  // number of distinct SKUs, drawn with a popularity skew
+
+	Street2     float64 // an address has a second line
+	SameBilling float64 // billing repeats the shipping address
+	Phone       float64 // the customer gave a phone number
+	Backorder   float64
+	Digital     float64
+	ForeignCCY  float64 // an item is priced in EUR or GBP rather than USD
+}
+
+// Default returns the configuration benchmarks use unless they study a
+// particular shape: a few items per order, mostly single units, prices
+// around $25, and a catalog of 10,000 SKUs.
+func Default() Config {
+	return Config{
+		Seed:        1,
+		Items:       Geometric{Min: 1, Mean: 3, Max: 50},
+		Quantity:    Geometric{Min: 1, Mean: 1.5, Max: 20},
+		PriceCents:  LogNormal{Median: 2500, Sigma: 1},
+		Catalog:     10000,
+		Street2:     0.3,
+		SameBilling: 0.7,
+		Phone:       0.6,
+		Backorder:   0.05,
+		Digital:     0.15,
+		ForeignCCY:  0.02,
+	}
+}
+
+// Generator produces IDs and orders from a Config. It is not safe for
+// concurrent use.
+type Generator struct {
+	cfg  Config
+	r    *rand.Rand
+	skus *rand.Zipf
+}
+
+func New(cfg Config) *Generator {
+	r := rand.New(rand.NewPCG(cfg.Seed, 0x9e3779b97f4a7c15))
+	catalog := max(cfg.Catalog, 1)
+	return &Generator{cfg: cfg, r: r, skus: rand.NewZipf(r, 1.1, 1, uint64(catalog-1))}
+}
+
+// ID returns a random 32-character hex ID, the shape of a UUID without
+// dashes.
+func (g *Generator) ID() string {
+	var b [16]byte
+	for i := 0; i < len(b); i += 8 {
+		v := g.r.Uint64()
+		for j := 0; j < 8; j++ {
+			b[i+j] = byte(v >> (8 * j))
+		}
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Order returns a new order with a fresh ID.
+func (g *Generator) Order() Order {
+	o := Order{ID: g.ID(), Customer: g.customer(), Shipping: g.address()}
+	o.Billing = o.Shipping
+	if !g.chance(g.cfg.SameBilling) {
+		o.Billing = g.address()
+	}
+	if n := g.cfg.Items.Int(g.r); n > 0 {
+		o.Items = make([]Item, n)
+		for i := range o.Items {
+			o.Items[i] = g.item()
+		}
+	}
+	return o
+}
+
+// Orders returns n new orders.
+func (g *Generator) Orders(n int) []Order {
+	orders := make([]Order, n)
+	for i := range orders {
+		orders[i] = g.Order()
+	}
+	return orders
+}
+
+func (g *Generator) chance(p float64) bool { return g.r.Float64() < p }
+
+func pick[T any](r *rand.Rand, s []T) T { return s[r.IntN(len(s))] }
+
+func (g *Generator) customer() Customer {
+	c := Customer{First: pick(g.r, firstNames), Last: pick(g.r, lastNames)}
+	local := asciiLower(c.First)
+	switch g.r.IntN(3) {
+	case 0:
+		local += "." + asciiLower(c.Last)
+	case 1:
+		local = local[:min(1, len(local))] + asciiLower(c.Last)
+	}
+	if g.chance(0.4) {
+		local += strconv.Itoa(g.r.IntN(1000))
+	}
+	c.Email = local + "@" + pick(g.r, emailDomains)
+	if g.chance(g.cfg.Phone) {
+		c.Phone = g.phone()
+	}
+	t := tiers[g.r.IntN(len(tiers))]
+	c.LoyaltyTier = t.name
+	c.LoyaltyPoints = t.minPoints + g.r.IntN(t.minPoints+500)
+	return c
+}
+
+func (g *Generator) phone() string {
+	area, line := 200+g.r.IntN(800), g.r.IntN(10000)
+	switch g.r.IntN(3) {
+	case 0:
+		return "+1 " + strconv.Itoa(area) + " 555 " + pad4(line)
+	case 1:
+		return "(" + strconv.Itoa(area) + ") 555-" + pad4(line)
+	}
+	return strconv.Itoa(area) + "555" + pad4(line)
+}
+
+func pad4(n int) string {
+	s := strconv.Itoa(n)
+	return strings.Repeat("0", 4-len(s)) + s
+}
+
+func (g *Generator) address() Address {
+	place := pick(g.r, places)
+	a := Address{
+		Street1: strconv.Itoa(1+g.r.IntN(9999)) + " " + pick(g.r, streetNames) + " " + pick(g.r, streetSuffixes),
+		City:    place.city,
+		State:   place.state,
+		Zip:     place.zip3 + pad4(g.r.IntN(100))[2:],
+	}
+	if g.chance(g.cfg.Street2) {
+		a.Street2 = pick(g.r, unitKinds) + " " + strconv.Itoa(1+g.r.IntN(2000))
+	}
+	return a
+}
+
+func (g *Generator) item() Item {
+	n := g.skus.Uint64()
+	it := Item{
+		SKU:        skuPrefixes[n%uint64(len(skuPrefixes))] + "-" + strconv.FormatUint(n*2654435761%1_000_000_007, 36),
+		Quantity:   max(g.cfg.Quantity.Int(g.r), 1),
+		PriceCents: int64(g.cfg.PriceCents.Int(g.r)),
+		Currency:   "USD",
+		Backorder:  g.chance(g.cfg.Backorder),
+		Digital:    g.chance(g.cfg.Digital),
+	}
+	if g.chance(g.cfg.ForeignCCY) {
+		it.Currency = pick(g.r, []string{"EUR", "GBP"})
+	}
+	return it
+}
+
+// asciiLower lowercases s and drops anything but ASCII letters, for email
+// local parts.
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, s)
+}
+
+var (
+	firstNames = []string{
+		"Ada", "Al", "Bo", "Charlotte", "Chen", "Dmitri", "Eve", "Fatima", "Giovanni", "Hannah",
+		"Ines", "Jo", "José", "Kwame", "Li", "Maximilian", "Noor", "Olga", "Priya", "Quinn",
+		"Rafael", "Siobhán", "Takumi", "Ulrike", "Valentina", "Wei", "Xavier", "Yusuf", "Zoë", "Alexandria",
+	}
+	lastNames = []string{
+		"Ng", "Li", "Smith", "Johnson", "García", "Müller", "O'Brien", "Nguyễn", "Kowalczyk", "Okonkwo",
+		"Papadopoulos", "Rossi", "Santos", "Tanaka", "Van der Berg", "Wójcik", "Yilmaz", "Zhang", "Abernathy-Whitfield", "Ivanova",
+	}
+	emailDomains   = []string{"example.com", "mail.example.org", "corp.example.net", "example.co.uk", "students.university.example.edu"}
+	streetNames    = []string{"Main", "Oak", "Elm", "Martin Luther King Jr", "1st", "Pine", "Washington", "El Camino Real", "Lake", "Hill", "Sunset", "Cedar Creek"}
+	streetSuffixes = []string{"St", "Ave", "Blvd", "Rd", "Ln", "Dr", "Way", "Court", "Parkway"}
+	unitKinds      = []string{"Apt", "Suite", "Unit", "#", "Floor", "Building"}
+	places         = []struct{ city, state, zip3 string }{
+		{"Springfield", "IL", "627"}, {"Portland", "OR", "972"}, {"Austin", "TX", "787"}, {"New York", "NY", "100"},
+		{"San Francisco", "CA", "941"}, {"Boise", "ID", "837"}, {"Salt Lake City", "UT", "841"}, {"Ely", "NV", "893"},
+		{"Raleigh", "NC", "276"}, {"Truth or Consequences", "NM", "879"}, {"Boston", "MA", "021"}, {"Miami", "FL", "331"},
+	}
+	skuPrefixes = []string{"BK", "EL", "HOME", "TOY", "APP", "GRO", "DIGI", "SPORT"}
+	tiers       = []struct {
+		name      string
+		minPoints int
+	}{
+		{"bronze", 0}, {"bronze", 0}, {"bronze", 0}, {"silver", 1000}, {"silver", 1000}, {"gold", 5000}, {"platinum", 20000},
+	}
+)
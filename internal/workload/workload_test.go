@@ -0,0 +1,113 @@
+package workload
+
+import (
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGenerator_Deterministic(t *testing.T) {
+	a := New(Default()).Orders(200)
+	b := New(Default()).Orders(200)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("two generators with the same config produced different orders")
+	}
+	cfg := Default()
+	cfg.Seed = 2
+	if c := New(cfg).Orders(200); reflect.DeepEqual(a, c) {
+		t.Fatal("generators with different seeds produced the same orders")
+	}
+}
+
+func TestGenerator_Orders(t *testing.T) {
+	const n = 5000
+	orders := New(Default()).Orders(n)
+	ids := make(map[string]bool, n)
+	lengths := make(map[int]bool)
+	var items, street2, phones int
+	for i, o := range orders {
+		if len(o.ID) != 32 || ids[o.ID] {
+			t.Fatalf("order %d: ID %q is malformed or repeated", i, o.ID)
+		}
+		ids[o.ID] = true
+		c := o.Customer
+		for _, s := range []string{c.First, c.Last, c.Email, o.Shipping.Street1, o.Shipping.City, o.Shipping.Zip} {
+			if s == "" || !utf8.ValidString(s) {
+				t.Fatalf("order %d: empty or invalid field in %+v", i, o)
+			}
+		}
+		if at := strings.IndexByte(c.Email, '@'); at < 1 || strings.ContainsFunc(c.Email, func(r rune) bool { return r >= utf8.RuneSelf }) {
+			t.Fatalf("order %d: email %q", i, c.Email)
+		}
+		if len(o.Shipping.Zip) != 5 {
+			t.Fatalf("order %d: zip %q", i, o.Shipping.Zip)
+		}
+		if len(o.Items) == 0 || len(o.Items) > 50 {
+			t.Fatalf("order %d: %d items", i, len(o.Items))
+		}
+		for _, it := range o.Items {
+			if it.Quantity < 1 || it.PriceCents < 1 || it.SKU == "" || it.Currency == "" {
+				t.Fatalf("order %d: item %+v", i, it)
+			}
+		}
+		items += len(o.Items)
+		lengths[len(c.First)+len(c.Last)+len(o.Shipping.Street1)] = true
+		if o.Shipping.Street2 != "" {
+			street2++
+		}
+		if c.Phone != "" {
+			phones++
+		}
+	}
+	if mean := float64(items) / n; math.Abs(mean-3) > 0.3 {
+		t.Errorf("mean items per order = %.2f, want about 3", mean)
+	}
+	if len(lengths) < 20 {
+		t.Errorf("only %d distinct string lengths; want varied data", len(lengths))
+	}
+	for _, c := range []struct {
+		name string
+		got  int
+		p    float64
+	}{{"Street2", street2, Default().Street2}, {"Phone", phones, Default().Phone}} {
+		if frac := float64(c.got) / n; math.Abs(frac-c.p) > 0.05 {
+			t.Errorf("%s present in %.2f of orders, want about %.2f", c.name, frac, c.p)
+		}
+	}
+}
+
+func TestDists(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	tests := []struct {
+		name     string
+		d        Dist
+		min, max int
+		mean     float64
+	}{
+		{"Const", Const(7), 7, 7, 7},
+		{"Uniform", Uniform{Min: 2, Max: 4}, 2, 4, 3},
+		{"Uniform Max below Min", Uniform{Min: 5, Max: 1}, 5, 5, 5},
+		{"Geometric", Geometric{Min: 1, Mean: 3}, 1, math.MaxInt, 3},
+		{"Geometric clamped", Geometric{Min: 0, Mean: 10, Max: 5}, 0, 5, -1},
+		{"LogNormal", LogNormal{Median: 100, Sigma: 0.5}, 1, math.MaxInt, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const n = 20000
+			sum := 0
+			for i := 0; i < n; i++ {
+				v := tt.d.Int(r)
+				if v < tt.min || v > tt.max {
+					t.Fatalf("Int = %d, want in [%d, %d]", v, tt.min, tt.max)
+				}
+				sum += v
+			}
+			if mean := float64(sum) / n; tt.mean >= 0 && math.Abs(mean-tt.mean) > tt.mean*0.05 {
+				t.Errorf("mean = %.3f, want about %v", mean, tt.mean)
+			}
+		})
+	}
+}
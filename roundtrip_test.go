@@ -0,0 +1,422 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/directflat"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/kv"
+	"github.com/alechenninger/go-ddd-bench/internal/ordergen"
+	"github.com/alechenninger/go-ddd-bench/internal/table"
+)
+
+// TestRepos_RoundTrip saves random orders through every repository of every
+// variant and checks that each loads back the same business state, so all
+// variants agree with each other after conversion.
+
+const roundTripRuns = 200
+
+// roundTripper saves g through one repository and loads it back.
+type roundTripper struct {
+	name  string
+	times timeForm
+	run   func(t *testing.T, g ordergen.Order) (ordergen.Order, error)
+}
+
+// timeForm is the form in which a repository returns the instants it keeps.
+// None of them keep a monotonic reading.
+type timeForm int
+
+const (
+	timesNanos  timeForm = iota // int64 nanoseconds, left to the caller to convert
+	timesOffset                 // at the saved zone offset, as JSON keeps it
+	timesLocal                  // in time.Local, from int64 nanoseconds
+)
+
+// checkTimes describes how a time in got is not in form, or returns "".
+// Instants are left to ordergen.Diff.
+func checkTimes(form timeForm, want, got ordergen.Order) string {
+	for _, tt := range []struct {
+		field     string
+		want, got time.Time
+	}{
+		{"CreatedAt", want.CreatedAt, got.CreatedAt},
+		{"UpdatedAt", want.UpdatedAt, got.UpdatedAt},
+	} {
+		if tt.got != tt.got.Round(0) {
+			return tt.field + " kept a monotonic reading"
+		}
+		switch form {
+		case timesOffset:
+			_, wantOff := tt.want.Zone()
+			if _, off := tt.got.Zone(); off != wantOff {
+				return fmt.Sprintf("%s offset = %ds, want %ds", tt.field, off, wantOff)
+			}
+		case timesLocal:
+			if !tt.got.IsZero() && tt.got.Location() != time.Local {
+				return fmt.Sprintf("%s location = %v, want Local", tt.field, tt.got.Location())
+			}
+		}
+	}
+	return ""
+}
+
+func roundTrippers() []roundTripper {
+	// The LoadInto round trips load every order into the same aggregate.
+	var (
+		directDst     direct.Order
+		encapDst      encap.Order
+		directFlatDst directflat.OrderRecord
+	)
+	return []roundTripper{
+		{"direct/blob", timesOffset, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := direct.NewDirectRepo()
+			if err := repo.Save(directFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return directToGen(o), err
+		}},
+		{"direct/blob-loadinto", timesOffset, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := direct.NewDirectRepo()
+			if err := repo.Save(directFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			err := repo.LoadInto(g.ID, &directDst)
+			return directToGen(&directDst), err
+		}},
+		{"direct/blob-pooled", timesOffset, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := direct.NewDirectRepo(direct.WithPooling())
+			if err := repo.Save(directFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return directToGen(o), err
+		}},
+		{"direct/sql", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := direct.NewSQLRepo(openSQL(t), nil)
+			if err := repo.Save(directFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return directToGen(o), err
+		}},
+		{"direct/partial", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := direct.NewPartialRepo(kv.New(), nil)
+			if err := repo.Save(directFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return directToGen(o), err
+		}},
+		{"direct/rows", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := direct.NewRowRepo(table.NewDB(), nil)
+			if err := repo.Save(directFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return directToGen(o), err
+		}},
+		{"encap/blob", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := encap.NewRepo()
+			if err := repo.Save(encapFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return encapToGen(o), err
+		}},
+		{"encap/blob-loadinto", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := encap.NewRepo()
+			if err := repo.Save(encapFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			err := repo.LoadInto(g.ID, &encapDst)
+			return encapToGen(&encapDst), err
+		}},
+		{"encap/blob-pooled", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := encap.NewRepo(encap.WithPooling())
+			if err := repo.Save(encapFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			err := repo.LoadInto(g.ID, &encapDst)
+			return encapToGen(&encapDst), err
+		}},
+		{"encap/sql", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := encap.NewSQLRepo(openSQL(t), nil)
+			if err := repo.Save(encapFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return encapToGen(o), err
+		}},
+		{"encap/partial", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := encap.NewPartialRepo(kv.New(), nil)
+			if err := repo.Save(encapFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return encapToGen(o), err
+		}},
+		{"encap/rows", timesLocal, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := encap.NewRowRepo(table.NewDB(), nil)
+			if err := repo.Save(encapFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			o, err := repo.FindByID(g.ID)
+			return encapToGen(o), err
+		}},
+		{"directflat/blob", timesNanos, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := directflat.NewRepo()
+			if err := repo.Save(directFlatFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			rec, err := repo.FindByID(g.ID)
+			return directFlatToGen(rec), err
+		}},
+		{"directflat/blob-loadinto", timesNanos, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := directflat.NewRepo()
+			if err := repo.Save(directFlatFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			err := repo.LoadInto(g.ID, &directFlatDst)
+			return directFlatToGen(&directFlatDst), err
+		}},
+		{"directflat/blob-pooled", timesNanos, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := directflat.NewRepo(directflat.WithPooling())
+			if err := repo.Save(directFlatFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			rec, err := repo.FindByID(g.ID)
+			return directFlatToGen(rec), err
+		}},
+		{"directflat/sql", timesNanos, func(t *testing.T, g ordergen.Order) (ordergen.Order, error) {
+			repo := directflat.NewSQLRepo(openSQL(t), nil)
+			if err := repo.Save(directFlatFromGen(g)); err != nil {
+				return ordergen.Order{}, err
+			}
+			rec, err := repo.FindByID(g.ID)
+			return directFlatToGen(rec), err
+		}},
+	}
+}
+
+func TestRepos_RoundTrip(t *testing.T) {
+	for _, rt := range roundTrippers() {
+		t.Run(rt.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(34, 0)) // the same orders for every repo
+			for i := 0; i < roundTripRuns; i++ {
+				g := ordergen.New(r)
+				got, err := rt.run(t, g)
+				if err != nil {
+					t.Fatalf("order %d: %v", i, err)
+				}
+				if d := ordergen.Diff(g, got); d != "" {
+					t.Fatalf("order %d: %s", i, d)
+				}
+				if d := checkTimes(rt.times, g, got); d != "" {
+					t.Fatalf("order %d: %s", i, d)
+				}
+			}
+		})
+	}
+}
+
+// TestRepos_PooledBlobs checks that pooling changes how each blob
+// repository encodes, not what it stores.
+func TestRepos_PooledBlobs(t *testing.T) {
+	r := rand.New(rand.NewPCG(35, 0))
+	stores := [2]*blobstore.Memory{blobstore.NewMemory(), blobstore.NewMemory()}
+	directs := [2]*direct.DirectRepo{direct.NewDirectRepo(direct.WithStore(stores[0])), direct.NewDirectRepo(direct.WithStore(stores[1]), direct.WithPooling())}
+	encaps := [2]*encap.Repo{encap.NewRepo(encap.WithStore(stores[0])), encap.NewRepo(encap.WithStore(stores[1]), encap.WithPooling())}
+	flats := [2]*directflat.Repo{directflat.NewRepo(directflat.WithStore(stores[0])), directflat.NewRepo(directflat.WithStore(stores[1]), directflat.WithPooling())}
+	for i := 0; i < roundTripRuns; i++ {
+		g := ordergen.New(r)
+		for j := range stores {
+			id := g.ID
+			for k, save := range []func() error{
+				func() error { return directs[j].Save(directFromGen(g)) },
+				func() error { return encaps[j].Save(encapFromGen(g)) },
+				func() error { return flats[j].Save(directFlatFromGen(g)) },
+			} {
+				g.ID = id + "/" + strconv.Itoa(k) // one key per variant
+				if err := save(); err != nil {
+					t.Fatalf("order %d, variant %d: %v", i, k, err)
+				}
+			}
+			g.ID = id
+		}
+	}
+	keys := stores[0].Keys()
+	if len(keys) == 0 {
+		t.Fatal("nothing stored")
+	}
+	for _, key := range keys {
+		want, _ := stores[0].Get(key)
+		got, err := stores[1].Get(key)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%q: pooled blob %.80s (%v), want %.80s", key, got, err, want)
+		}
+	}
+}
+
+func directFromGen(g ordergen.Order) *direct.Order {
+	o := &direct.Order{
+		ID: g.ID,
+		Customer: direct.Customer{
+			Name:    direct.Name{First: g.First, Last: g.Last},
+			Email:   g.Email,
+			Phone:   g.Phone,
+			Loyalty: direct.Loyalty{Tier: g.LoyaltyTier, Points: g.LoyaltyPoints},
+		},
+		Shipping:  direct.Address(g.Shipping),
+		Billing:   direct.Address(g.Billing),
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+	}
+	for _, it := range g.Items {
+		o.Items = append(o.Items, direct.LineItem{SKU: it.SKU, Quantity: it.Quantity, Price: direct.Money{Cents: it.PriceCents, Currency: it.Currency}, Flags: direct.ItemFlags{Backorder: it.Backorder, Digital: it.Digital}})
+	}
+	return o
+}
+
+func directToGen(o *direct.Order) ordergen.Order {
+	if o == nil {
+		return ordergen.Order{}
+	}
+	g := ordergen.Order{
+		ID:            o.ID,
+		First:         o.Customer.Name.First,
+		Last:          o.Customer.Name.Last,
+		Email:         o.Customer.Email,
+		Phone:         o.Customer.Phone,
+		LoyaltyTier:   o.Customer.Loyalty.Tier,
+		LoyaltyPoints: o.Customer.Loyalty.Points,
+		Shipping:      ordergen.Address(o.Shipping),
+		Billing:       ordergen.Address(o.Billing),
+		CreatedAt:     o.CreatedAt,
+		UpdatedAt:     o.UpdatedAt,
+	}
+	for _, it := range o.Items {
+		g.Items = append(g.Items, ordergen.Item{SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.Price.Cents, Currency: it.Price.Currency, Backorder: it.Flags.Backorder, Digital: it.Flags.Digital})
+	}
+	return g
+}
+
+func encapFromGen(g ordergen.Order) *encap.Order {
+	s := encap.Snapshot{
+		ID: g.ID,
+		Customer: encap.SnapshotCustomer{
+			Name:    encap.SnapshotName{First: g.First, Last: g.Last},
+			Email:   g.Email,
+			Phone:   g.Phone,
+			Loyalty: encap.SnapshotLoyalty{Tier: g.LoyaltyTier, Points: g.LoyaltyPoints},
+		},
+		Shipping:  encap.SnapshotAddress(g.Shipping),
+		Billing:   encap.SnapshotAddress(g.Billing),
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+	}
+	for _, it := range g.Items {
+		s.Items = append(s.Items, encap.SnapshotLineItem{SKU: it.SKU, Quantity: it.Quantity, Price: encap.SnapshotMoney{Cents: it.PriceCents, Currency: it.Currency}, Flags: encap.SnapshotItemFlags{Backorder: it.Backorder, Digital: it.Digital}})
+	}
+	return encap.FromSnapshot(s)
+}
+
+func encapToGen(o *encap.Order) ordergen.Order {
+	if o == nil {
+		return ordergen.Order{}
+	}
+	s := o.ToSnapshot()
+	g := ordergen.Order{
+		ID:            s.ID,
+		First:         s.Customer.Name.First,
+		Last:          s.Customer.Name.Last,
+		Email:         s.Customer.Email,
+		Phone:         s.Customer.Phone,
+		LoyaltyTier:   s.Customer.Loyalty.Tier,
+		LoyaltyPoints: s.Customer.Loyalty.Points,
+		Shipping:      ordergen.Address(s.Shipping),
+		Billing:       ordergen.Address(s.Billing),
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+	}
+	for _, it := range s.Items {
+		g.Items = append(g.Items, ordergen.Item{SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.Price.Cents, Currency: it.Price.Currency, Backorder: it.Flags.Backorder, Digital: it.Flags.Digital})
+	}
+	return g
+}
+
+// directFlatFromGen stores times as Unix nanoseconds with 0 for the zero
+// time, the same convention the other variants use in their persistence rows.
+func directFlatFromGen(g ordergen.Order) *directflat.OrderRecord {
+	rec := &directflat.OrderRecord{Header: directflat.OrderHeader{
+		ID:            g.ID,
+		CustomerFirst: g.First,
+		CustomerLast:  g.Last,
+		CustomerEmail: g.Email,
+		CustomerPhone: g.Phone,
+		LoyaltyTier:   g.LoyaltyTier,
+		LoyaltyPoints: g.LoyaltyPoints,
+		Street1:       g.Shipping.Street1,
+		Street2:       g.Shipping.Street2,
+		City:          g.Shipping.City,
+		State:         g.Shipping.State,
+		Zip:           g.Shipping.Zip,
+		BillStreet1:   g.Billing.Street1,
+		BillStreet2:   g.Billing.Street2,
+		BillCity:      g.Billing.City,
+		BillState:     g.Billing.State,
+		BillZip:       g.Billing.Zip,
+		CreatedAt:     unixNanos(g.CreatedAt),
+		UpdatedAt:     unixNanos(g.UpdatedAt),
+	}}
+	for _, it := range g.Items {
+		rec.Items = append(rec.Items, directflat.OrderItemRow{OrderID: g.ID, SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.PriceCents, Currency: it.Currency, Backorder: it.Backorder, Digital: it.Digital})
+	}
+	return rec
+}
+
+func directFlatToGen(rec *directflat.OrderRecord) ordergen.Order {
+	if rec == nil {
+		return ordergen.Order{}
+	}
+	h := &rec.Header
+	g := ordergen.Order{
+		ID:            h.ID,
+		First:         h.CustomerFirst,
+		Last:          h.CustomerLast,
+		Email:         h.CustomerEmail,
+		Phone:         h.CustomerPhone,
+		LoyaltyTier:   h.LoyaltyTier,
+		LoyaltyPoints: h.LoyaltyPoints,
+		Shipping:      ordergen.Address{Street1: h.Street1, Street2: h.Street2, City: h.City, State: h.State, Zip: h.Zip},
+		Billing:       ordergen.Address{Street1: h.BillStreet1, Street2: h.BillStreet2, City: h.BillCity, State: h.BillState, Zip: h.BillZip},
+		CreatedAt:     fromUnixNanos(h.CreatedAt),
+		UpdatedAt:     fromUnixNanos(h.UpdatedAt),
+	}
+	for _, it := range rec.Items {
+		g.Items = append(g.Items, ordergen.Item{SKU: it.SKU, Quantity: it.Quantity, PriceCents: it.PriceCents, Currency: it.Currency, Backorder: it.Backorder, Digital: it.Digital})
+	}
+	return g
+}
+
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,200 @@
+package bench
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/direct"
+	"github.com/alechenninger/go-ddd-bench/encap"
+	"github.com/alechenninger/go-ddd-bench/internal/kv"
+	"github.com/alechenninger/go-ddd-bench/internal/table"
+)
+
+// rowRepo is a repository that writes each row separately, taking and
+// returning its variant's orders as commandTargets, with its store's count
+// of row puts and deletes.
+type rowRepo struct {
+	save  func(commandTarget) error
+	find  func(id string) (commandTarget, error)
+	stats func() (puts, deletes int64)
+}
+
+func directRowRepo(save func(*direct.Order) error, find func(string) (*direct.Order, error), stats func() (int64, int64)) rowRepo {
+	return rowRepo{
+		save: func(t commandTarget) error { return save(t.(directTarget).o) },
+		find: func(id string) (commandTarget, error) {
+			o, err := find(id)
+			if err != nil {
+				return nil, err
+			}
+			return directTarget{o}, nil
+		},
+		stats: stats,
+	}
+}
+
+func encapRowRepo(save func(*encap.Order) error, find func(string) (*encap.Order, error), stats func() (int64, int64)) rowRepo {
+	return rowRepo{
+		save: func(t commandTarget) error { return save(t.(encapTarget).o) },
+		find: func(id string) (commandTarget, error) {
+			o, err := find(id)
+			if err != nil {
+				return nil, err
+			}
+			return encapTarget{o}, nil
+		},
+		stats: stats,
+	}
+}
+
+// rowRepos are the PartialRepo and RowRepo of each variant, with a new empty
+// order "o" of that variant.
+var rowRepos = []struct {
+	variant, name string
+	newOrder      func() commandTarget
+	new           func() rowRepo
+}{
+	{"direct", "partial", newDirectTarget, func() rowRepo {
+		store := kv.New()
+		r := direct.NewPartialRepo(store, nil)
+		return directRowRepo(r.Save, r.FindByID, store.Stats)
+	}},
+	{"direct", "rows", newDirectTarget, func() rowRepo {
+		db := table.NewDB()
+		r := direct.NewRowRepo(db, nil)
+		return directRowRepo(r.Save, r.FindByID, db.Stats)
+	}},
+	{"encap", "partial", newEncapTarget, func() rowRepo {
+		store := kv.New()
+		r := encap.NewPartialRepo(store, nil)
+		return encapRowRepo(r.Save, r.FindByID, store.Stats)
+	}},
+	{"encap", "rows", newEncapTarget, func() rowRepo {
+		db := table.NewDB()
+		r := encap.NewRowRepo(db, nil)
+		return encapRowRepo(r.Save, r.FindByID, db.Stats)
+	}},
+}
+
+// newABC returns order "o" from newOrder with one each of items A, B and C.
+func newABC(t *testing.T, newOrder func() commandTarget) commandTarget {
+	t.Helper()
+	o := newOrder()
+	for _, sku := range []string{"A", "B", "C"} {
+		if err := o.AddItem(sku, 1, 1000, "USD"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return o
+}
+
+func itemStrings(t *testing.T, o commandTarget) []string {
+	t.Helper()
+	s, err := o.state()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Items
+}
+
+// saveCounting saves o and returns the row puts and deletes it took.
+func saveCounting(t *testing.T, r rowRepo, o commandTarget) (puts, deletes int64) {
+	t.Helper()
+	p0, d0 := r.stats()
+	if err := r.save(o); err != nil {
+		t.Fatal(err)
+	}
+	p1, d1 := r.stats()
+	return p1 - p0, d1 - d0
+}
+
+// Each command writes the header, which it touches, plus only the item rows
+// it changed.
+func TestRowRepos_WritesOnlyChangedRows(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		cmds          []command
+		puts, deletes int64
+	}{
+		{"unchanged", nil, 0, 0},
+		{"AddItem", []command{add("D", 1, 1, "USD", nil)}, 2, 0},
+		{"ChangeQuantity", []command{changeQty("B", 5, nil)}, 2, 0},
+		{"RemoveItem first", []command{remove("A", nil)}, 1, 1},
+		{"RemoveItem middle", []command{remove("B", nil)}, 1, 1},
+		{"RemoveItem last", []command{remove("C", nil)}, 1, 1},
+		{"ApplyDiscount", []command{discount(10, nil)}, 4, 0},
+		{"UpdateLoyaltyPoints", []command{points(7)}, 1, 0},
+		{"re-add removed", []command{remove("A", nil), add("A", 2, 1000, "USD", nil)}, 2, 1},
+		{"add and remove unsaved", []command{add("D", 1, 1, "USD", nil), remove("D", nil)}, 1, 0},
+	} {
+		for _, rr := range rowRepos {
+			t.Run(rr.variant+"/"+rr.name+"/"+tc.name, func(t *testing.T) {
+				r := rr.new()
+				if err := r.save(newABC(t, rr.newOrder)); err != nil {
+					t.Fatal(err)
+				}
+				o, err := r.find("o")
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, c := range tc.cmds {
+					if err := c.run(o); err != nil {
+						t.Fatalf("%s: %v", c.name, err)
+					}
+				}
+				if puts, deletes := saveCounting(t, r, o); puts != tc.puts || deletes != tc.deletes {
+					t.Errorf("Save wrote %d puts, %d deletes; want %d, %d", puts, deletes, tc.puts, tc.deletes)
+				}
+				o.UpdateLoyaltyPoints(99)
+				if puts, deletes := saveCounting(t, r, o); puts != 1 || deletes != 0 {
+					t.Errorf("second Save wrote %d puts, %d deletes; want only the header", puts, deletes)
+				}
+				got, err := r.find("o")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if g, w := itemStrings(t, got), itemStrings(t, o); !slices.Equal(g, w) {
+					t.Errorf("reloaded items = %q, want %q", g, w)
+				}
+			})
+		}
+	}
+}
+
+// An order loaded from one repository has no diff against another, which
+// may store a different version, so saving it there writes every row and
+// removes the rows it lacks.
+func TestRowRepos_SaveToOtherRepoWritesInFull(t *testing.T) {
+	for _, from := range rowRepos {
+		for _, to := range rowRepos {
+			if from.variant != to.variant {
+				continue
+			}
+			t.Run(from.variant+"/"+from.name+"->"+to.name, func(t *testing.T) {
+				src, dst := from.new(), to.new()
+				for _, r := range []rowRepo{src, dst} {
+					if err := r.save(newABC(t, from.newOrder)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				o, err := src.find("o")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := o.RemoveItem("A"); err != nil {
+					t.Fatal(err)
+				}
+				if puts, deletes := saveCounting(t, dst, o); puts != 3 || deletes != 1 {
+					t.Errorf("Save wrote %d puts, %d deletes; want 3, 1", puts, deletes)
+				}
+				got, err := dst.find("o")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if g, w := itemStrings(t, got), []string{"B 1@10.00 USD", "C 1@10.00 USD"}; !slices.Equal(g, w) {
+					t.Errorf("reloaded items = %q, want %q", g, w)
+				}
+			})
+		}
+	}
+}
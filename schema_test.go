@@ -0,0 +1,163 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alechenninger/go-ddd-bench/internal/blobstore"
+	"github.com/alechenninger/go-ddd-bench/internal/schema"
+)
+
+// downgrade rewrites a current blob as version v of fv's schema would have
+// stored it: version 1 has no envelope and a single newline-separated street
+// member, version 2 has no phone.
+func downgrade(tb testing.TB, fv blobVariant, blob []byte, v int) []byte {
+	tb.Helper()
+	data, _, err := schema.Unwrap(blob)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		tb.Fatal(err)
+	}
+	if v < 3 {
+		obj, name := member(doc, fv.phonePath)
+		delete(obj, name)
+	}
+	if v < 2 {
+		for _, path := range fv.streetPaths {
+			obj, name := member(doc, path)
+			street, _ := obj[name+"1"].(string)
+			if line2, _ := obj[name+"2"].(string); line2 != "" {
+				street += "\n" + line2
+			}
+			delete(obj, name+"1")
+			delete(obj, name+"2")
+			obj[name] = street
+		}
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if v == 1 {
+		return out
+	}
+	out, err = json.Marshal(schema.Envelope[json.RawMessage]{V: v, Data: out})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return out
+}
+
+// member returns the object holding the member at path, and its name.
+func member(doc map[string]any, path string) (map[string]any, string) {
+	elems := strings.Split(path, ".")
+	for _, e := range elems[:len(elems)-1] {
+		doc = doc[e].(map[string]any)
+	}
+	return doc, elems[len(elems)-1]
+}
+
+func downgradeStored(tb testing.TB, fv blobVariant, s blobstore.Store, id string, v int) {
+	tb.Helper()
+	blob, err := s.Get(id)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := s.Put(id, downgrade(tb, fv, blob, v)); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func storedVersion(tb testing.TB, s blobstore.Store, id string) int {
+	tb.Helper()
+	blob, err := s.Get(id)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	_, v, err := schema.Unwrap(blob)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return v
+}
+
+func TestFindByID_Upcasts(t *testing.T) {
+	want := intactView
+	want.Phone = "" // added at version 3
+	for _, fv := range blobVariants {
+		for _, v := range []int{1, 2} {
+			t.Run(fmt.Sprintf("%s/v%d", fv.name, v), func(t *testing.T) {
+				store := blobstore.NewMemory()
+				repo := fv.open(t, store, false)
+				downgradeStored(t, fv, store, faultOrderID, v)
+
+				got, err := repo.load(faultOrderID)
+				if err != nil {
+					t.Fatalf("FindByID: %v", err)
+				}
+				if !equalViews(got, want) {
+					t.Fatalf("FindByID = %+v, want %+v", got, want)
+				}
+				if sv := storedVersion(t, store, faultOrderID); sv != v {
+					t.Fatalf("stored version after read = %d, want %d", sv, v)
+				}
+				if err := repo.resave(faultOrderID); err != nil {
+					t.Fatal(err)
+				}
+				if sv := storedVersion(t, store, faultOrderID); sv != 3 {
+					t.Fatalf("stored version after Save = %d, want 3", sv)
+				}
+			})
+		}
+	}
+}
+
+func TestFindByID_NewerVersion(t *testing.T) {
+	for _, fv := range blobVariants {
+		t.Run(fv.name, func(t *testing.T) {
+			store := blobstore.NewMemory()
+			repo := fv.open(t, store, false)
+			if err := store.Put(faultOrderID, []byte(`{"v":4,"data":{}}`)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.load(faultOrderID); !errors.Is(err, schema.ErrUnknownVersion) {
+				t.Fatalf("FindByID err = %v, want ErrUnknownVersion", err)
+			}
+		})
+	}
+}
+
+func TestSave_LazyRewrite(t *testing.T) {
+	for _, fv := range blobVariants {
+		for _, lazy := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/lazy=%t", fv.name, lazy), func(t *testing.T) {
+				store := blobstore.NewMemory()
+				repo := fv.open(t, store, lazy)
+				downgradeStored(t, fv, store, faultOrderID, 1)
+
+				if _, err := repo.load(faultOrderID); err != nil {
+					t.Fatal(err)
+				}
+				if err := repo.resave(otherOrderID); err != nil {
+					t.Fatal(err)
+				}
+				want := 1
+				if lazy {
+					want = 3
+				}
+				if sv := storedVersion(t, store, faultOrderID); sv != want {
+					t.Fatalf("stored version after another order's Save = %d, want %d", sv, want)
+				}
+			})
+		}
+	}
+}